@@ -0,0 +1,115 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// HubDataAnnotation stores the v1beta1 spec on a v1alpha1 object when the spec
+	// cannot be expressed in v1alpha1, so that it survives a round trip.
+	HubDataAnnotation = "conversion.secrets.oleksiyp.dev/v1beta1-spec"
+
+	// SpokeDataAnnotation stores v1alpha1 fields that have no v1beta1 representation
+	// on the v1beta1 object, so that they survive a round trip.
+	SpokeDataAnnotation = "conversion.secrets.oleksiyp.dev/v1alpha1-data"
+
+	// HubStatusAnnotation stores the v1beta1 status on a v1alpha1 object when the status
+	// cannot be expressed in v1alpha1, so that it survives a round trip.
+	HubStatusAnnotation = "conversion.secrets.oleksiyp.dev/v1beta1-status"
+)
+
+// storeConversionData marshals data into the given annotation of obj.
+func storeConversionData(obj metav1.Object, annotation string, data any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal conversion data: %w", err)
+	}
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[annotation] = string(raw)
+	obj.SetAnnotations(annotations)
+	return nil
+}
+
+// loadConversionData unmarshals the given annotation of obj into data and removes
+// the annotation. It returns false if the annotation is not present.
+func loadConversionData(obj metav1.Object, annotation string, data any) (bool, error) {
+	annotations := obj.GetAnnotations()
+	raw, ok := annotations[annotation]
+	if !ok {
+		return false, nil
+	}
+	dropConversionData(obj, annotation)
+	if err := json.Unmarshal([]byte(raw), data); err != nil {
+		return false, fmt.Errorf("failed to unmarshal conversion data: %w", err)
+	}
+	return true, nil
+}
+
+// dropConversionData removes the given annotation from obj.
+func dropConversionData(obj metav1.Object, annotation string) {
+	annotations := obj.GetAnnotations()
+	if _, ok := annotations[annotation]; !ok {
+		return
+	}
+	delete(annotations, annotation)
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+	obj.SetAnnotations(annotations)
+}
+
+// readyFromConditions returns status.ready from the Ready condition, or the stashed value
+// if the object has no Ready condition yet.
+func readyFromConditions(conditions []metav1.Condition, stashed bool) bool {
+	if condition := meta.FindStatusCondition(conditions, "Ready"); condition != nil {
+		return condition.Status == metav1.ConditionTrue
+	}
+	return stashed
+}
+
+// copyMap returns a shallow copy of m, preserving nil.
+func copyMap[K comparable, V any](m map[K]V) map[K]V {
+	if m == nil {
+		return nil
+	}
+	out := make(map[K]V, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+// copyConditions returns a deep copy of conditions, preserving nil.
+func copyConditions(conditions []metav1.Condition) []metav1.Condition {
+	if conditions == nil {
+		return nil
+	}
+	out := make([]metav1.Condition, len(conditions))
+	for i := range conditions {
+		conditions[i].DeepCopyInto(&out[i])
+	}
+	return out
+}
@@ -0,0 +1,607 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/oleksiyp/derived-secret-operator/api/v1beta1"
+)
+
+func readyConditions(status metav1.ConditionStatus) []metav1.Condition {
+	return []metav1.Condition{{
+		Type:               "Ready",
+		Status:             status,
+		Reason:             "SecretReady",
+		LastTransitionTime: metav1.Unix(1700000000, 0),
+	}}
+}
+
+func TestDerivedSecretRoundTripFromSpoke(t *testing.T) {
+	tests := []struct {
+		name string
+		in   DerivedSecret
+	}{
+		{
+			name: "single master password",
+			in: DerivedSecret{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "ns"},
+				Spec: DerivedSecretSpec{
+					Type:   "Opaque",
+					Labels: map[string]string{"app": "test"},
+					Keys: map[string]DerivedKeySpec{
+						"password": {Type: SecretTypePassword, MasterPassword: "default"},
+						"token":    {Type: SecretTypeCustom, MasterPassword: "default", Length: 64},
+					},
+				},
+				Status: DerivedSecretStatus{
+					SecretName:      "app",
+					Ready:           true,
+					KeyFingerprints: map[string]string{"password": "hmac-sha256:00112233445566778899aabbccddeeff"},
+					Conditions:      readyConditions(metav1.ConditionTrue),
+				},
+			},
+		},
+		{
+			name: "mixed master passwords",
+			in: DerivedSecret{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "ns"},
+				Spec: DerivedSecretSpec{
+					Keys: map[string]DerivedKeySpec{
+						"a": {Type: SecretTypePassword, MasterPassword: "first"},
+						"b": {Type: SecretTypePassword, MasterPassword: "second"},
+						"c": {Type: SecretTypeEncryptionKey, MasterPassword: "second"},
+					},
+				},
+			},
+		},
+		{
+			name: "length on non-custom key",
+			in: DerivedSecret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "app",
+					Namespace:   "ns",
+					Annotations: map[string]string{"keep": "me"},
+				},
+				Spec: DerivedSecretSpec{
+					Keys: map[string]DerivedKeySpec{
+						"password": {Type: SecretTypePassword, MasterPassword: "default", Length: 40},
+					},
+				},
+				Status: DerivedSecretStatus{Conditions: readyConditions(metav1.ConditionFalse)},
+			},
+		},
+		{
+			name: "ready without conditions",
+			in: DerivedSecret{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "ns"},
+				Spec: DerivedSecretSpec{
+					Keys: map[string]DerivedKeySpec{
+						"password": {Type: SecretTypePassword, MasterPassword: "default"},
+					},
+				},
+				Status: DerivedSecretStatus{Ready: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := &v1beta1.DerivedSecret{}
+			if err := tt.in.DeepCopy().ConvertTo(hub); err != nil {
+				t.Fatalf("ConvertTo() error = %v", err)
+			}
+			for name, key := range hub.Spec.Keys {
+				if key.Length != 0 && key.Type != v1beta1.SecretTypeCustom {
+					t.Errorf("ConvertTo() kept length on non-custom key %s", name)
+				}
+			}
+
+			got := &DerivedSecret{}
+			if err := got.ConvertFrom(hub); err != nil {
+				t.Fatalf("ConvertFrom() error = %v", err)
+			}
+			if !apiequality.Semantic.DeepEqual(got, &tt.in) {
+				t.Errorf("round trip mismatch:\n got = %+v\nwant = %+v", got, &tt.in)
+			}
+		})
+	}
+}
+
+func TestDerivedSecretRoundTripFromHub(t *testing.T) {
+	tests := []struct {
+		name string
+		in   v1beta1.DerivedSecret
+	}{
+		{
+			name: "secret level master password",
+			in: v1beta1.DerivedSecret{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "ns"},
+				Spec: v1beta1.DerivedSecretSpec{
+					MasterPassword: "team",
+					Keys: map[string]v1beta1.DerivedKeySpec{
+						"password": {Type: v1beta1.SecretTypePassword},
+					},
+				},
+				Status: v1beta1.DerivedSecretStatus{Conditions: readyConditions(metav1.ConditionTrue)},
+			},
+		},
+		{
+			name: "redundant key overrides",
+			in: v1beta1.DerivedSecret{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "ns"},
+				Spec: v1beta1.DerivedSecretSpec{
+					MasterPassword: "default",
+					Keys: map[string]v1beta1.DerivedKeySpec{
+						"a": {Type: v1beta1.SecretTypePassword, MasterPassword: "other"},
+						"b": {Type: v1beta1.SecretTypePassword, MasterPassword: "other"},
+					},
+				},
+			},
+		},
+		{
+			name: "v1beta1 status",
+			in: v1beta1.DerivedSecret{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "ns"},
+				Spec: v1beta1.DerivedSecretSpec{
+					Keys: map[string]v1beta1.DerivedKeySpec{
+						"password": {Type: v1beta1.SecretTypePassword, Version: 2},
+					},
+				},
+				Status: hubDerivedSecretStatus(),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spoke := &DerivedSecret{}
+			if err := spoke.ConvertFrom(tt.in.DeepCopy()); err != nil {
+				t.Fatalf("ConvertFrom() error = %v", err)
+			}
+			for name, key := range spoke.Spec.Keys {
+				if want := tt.in.Spec.MasterPasswordFor(tt.in.Spec.Keys[name]); key.MasterPassword != want {
+					t.Errorf("ConvertFrom() key %s master password = %s, want %s", name, key.MasterPassword, want)
+				}
+			}
+
+			got := &v1beta1.DerivedSecret{}
+			if err := spoke.ConvertTo(got); err != nil {
+				t.Fatalf("ConvertTo() error = %v", err)
+			}
+			if !apiequality.Semantic.DeepEqual(got, &tt.in) {
+				t.Errorf("round trip mismatch:\n got = %+v\nwant = %+v", got, &tt.in)
+			}
+		})
+	}
+}
+
+func hubDerivedSecretStatus() v1beta1.DerivedSecretStatus {
+	return v1beta1.DerivedSecretStatus{
+		Targets: []v1beta1.TargetStatus{
+			{Name: "app", Ready: true},
+			{Name: "app-copy", Ready: false, Reason: "SecretConflict", Message: "not owned"},
+		},
+		KeyFingerprints:           map[string]string{"password": "hmac-sha256:00112233445566778899aabbccddeeff"},
+		MasterPasswordGenerations: map[string]int{"default": 3},
+		KeyVersions: map[string][]v1beta1.KeyVersionStatus{
+			"password": {{Version: 1}, {Version: 2, Fingerprint: "hmac-sha256:00112233445566778899aabbccddeeff"}},
+		},
+		StagedRotation: &v1beta1.StagedRotationStatus{
+			Phase:        v1beta1.StagedRotationStaged,
+			FromVersions: map[string]int{"password": 1},
+		},
+		Conditions: readyConditions(metav1.ConditionTrue),
+	}
+}
+
+func TestDerivedSecretSpokeStatusEditKeepsHubStatus(t *testing.T) {
+	hub := &v1beta1.DerivedSecret{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "ns"},
+		Spec: v1beta1.DerivedSecretSpec{
+			Keys: map[string]v1beta1.DerivedKeySpec{"password": {Type: v1beta1.SecretTypePassword}},
+		},
+		Status: hubDerivedSecretStatus(),
+	}
+	spoke := &DerivedSecret{}
+	if err := spoke.ConvertFrom(hub.DeepCopy()); err != nil {
+		t.Fatalf("ConvertFrom() error = %v", err)
+	}
+	if _, ok := spoke.Annotations[HubStatusAnnotation]; !ok {
+		t.Fatalf("ConvertFrom() did not stash the hub status")
+	}
+
+	spoke.Status.KeyFingerprints = map[string]string{"password": "hmac-sha256:ffeeddccbbaa99887766554433221100"}
+	got := &v1beta1.DerivedSecret{}
+	if err := spoke.ConvertTo(got); err != nil {
+		t.Fatalf("ConvertTo() error = %v", err)
+	}
+	want := hub.Status.DeepCopy()
+	want.KeyFingerprints = spoke.Status.KeyFingerprints
+	want.Targets[0] = v1beta1.TargetStatus{Name: "app", Ready: true}
+	if !apiequality.Semantic.DeepEqual(got.Status, *want) {
+		t.Errorf("ConvertTo() status =\n%+v\nwant\n%+v", got.Status, *want)
+	}
+	if _, ok := got.Annotations[HubStatusAnnotation]; ok {
+		t.Errorf("ConvertTo() kept the stashed status annotation")
+	}
+}
+
+func TestDerivedSecretSpokeEditDropsStashedSpec(t *testing.T) {
+	hub := &v1beta1.DerivedSecret{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "ns"},
+		Spec: v1beta1.DerivedSecretSpec{
+			MasterPassword: "default",
+			Keys: map[string]v1beta1.DerivedKeySpec{
+				"a": {Type: v1beta1.SecretTypePassword, MasterPassword: "other"},
+			},
+		},
+	}
+
+	spoke := &DerivedSecret{}
+	if err := spoke.ConvertFrom(hub); err != nil {
+		t.Fatalf("ConvertFrom() error = %v", err)
+	}
+	if _, ok := spoke.Annotations[HubDataAnnotation]; !ok {
+		t.Fatalf("ConvertFrom() did not stash the hub spec")
+	}
+
+	spoke.Spec.Keys["b"] = DerivedKeySpec{Type: SecretTypePassword, MasterPassword: "default"}
+
+	got := &v1beta1.DerivedSecret{}
+	if err := spoke.ConvertTo(got); err != nil {
+		t.Fatalf("ConvertTo() error = %v", err)
+	}
+	if _, ok := got.Annotations[HubDataAnnotation]; ok {
+		t.Errorf("ConvertTo() leaked the stash annotation")
+	}
+	if got.Spec.MasterPasswordFor(got.Spec.Keys["a"]) != "other" {
+		t.Errorf("ConvertTo() key a master password = %s, want other", got.Spec.MasterPasswordFor(got.Spec.Keys["a"]))
+	}
+	if got.Spec.MasterPasswordFor(got.Spec.Keys["b"]) != "default" {
+		t.Errorf("ConvertTo() key b master password = %s, want default", got.Spec.MasterPasswordFor(got.Spec.Keys["b"]))
+	}
+}
+
+func TestDerivedSecretSpokeEditKeepsMasterPasswordKinds(t *testing.T) {
+	hub := &v1beta1.DerivedSecret{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "ns"},
+		Spec: v1beta1.DerivedSecretSpec{
+			MasterPassword:           "tenant",
+			MasterPasswordKind:       v1beta1.KindNamespaceMasterPassword,
+			AdoptionPolicy:           v1beta1.AdoptionPolicyIfUnowned,
+			DerivationID:             "app-v1",
+			MasterPasswordGeneration: 2,
+			Targets: []v1beta1.DerivedSecretTarget{
+				{Name: "app-db", MergeMode: v1beta1.MergeModeMerge},
+				{Name: "migrator-db", Template: &v1beta1.SecretTemplate{Immutable: true}},
+			},
+			Keys: map[string]v1beta1.DerivedKeySpec{
+				"a": {
+					Type:            v1beta1.SecretTypePassword,
+					ContextOverride: "ns/legacy/a",
+					Version:         2,
+					Rotation:        &v1beta1.RotationSpec{Interval: metav1.Duration{Duration: 720 * time.Hour}},
+				},
+				"b": {Type: v1beta1.SecretTypePassword, MasterPassword: "default", MasterPasswordKind: v1beta1.KindMasterPassword},
+			},
+		},
+	}
+
+	spoke := &DerivedSecret{}
+	if err := spoke.ConvertFrom(hub.DeepCopy()); err != nil {
+		t.Fatalf("ConvertFrom() error = %v", err)
+	}
+
+	got := &v1beta1.DerivedSecret{}
+	if err := spoke.DeepCopy().ConvertTo(got); err != nil {
+		t.Fatalf("ConvertTo() error = %v", err)
+	}
+	if !apiequality.Semantic.DeepEqual(got, hub) {
+		t.Errorf("round trip mismatch:\n got = %+v\nwant = %+v", got, hub)
+	}
+
+	spoke.Spec.Keys["c"] = DerivedKeySpec{Type: SecretTypePassword, MasterPassword: "tenant"}
+	got = &v1beta1.DerivedSecret{}
+	if err := spoke.ConvertTo(got); err != nil {
+		t.Fatalf("ConvertTo() error = %v", err)
+	}
+	want := map[string]v1beta1.MasterPasswordReference{
+		"a": {Kind: v1beta1.KindNamespaceMasterPassword, Name: "tenant"},
+		"b": {Kind: v1beta1.KindMasterPassword, Name: "default"},
+		"c": {Kind: v1beta1.KindMasterPassword, Name: "tenant"},
+	}
+	for name, ref := range want {
+		if got := got.Spec.MasterPasswordRefFor(got.Spec.Keys[name]); got != ref {
+			t.Errorf("ConvertTo() key %s master password = %+v, want %+v", name, got, ref)
+		}
+	}
+	if got.Spec.AdoptionPolicy != v1beta1.AdoptionPolicyIfUnowned {
+		t.Errorf("ConvertTo() adoptionPolicy = %q, want %q", got.Spec.AdoptionPolicy, v1beta1.AdoptionPolicyIfUnowned)
+	}
+	if got.Spec.Keys["a"].Version != 2 {
+		t.Errorf("ConvertTo() key a version = %d, want 2", got.Spec.Keys["a"].Version)
+	}
+	if got.Spec.Keys["a"].Rotation == nil {
+		t.Errorf("ConvertTo() key a rotation = nil, want the stashed schedule")
+	}
+	if got.Spec.DerivationID != "app-v1" || got.Spec.Keys["a"].ContextOverride != "ns/legacy/a" {
+		t.Errorf("ConvertTo() derivation context = %q, %q, want the stashed context",
+			got.Spec.DerivationID, got.Spec.Keys["a"].ContextOverride)
+	}
+	if got.Spec.MasterPasswordGeneration != 2 {
+		t.Errorf("ConvertTo() masterPasswordGeneration = %d, want 2", got.Spec.MasterPasswordGeneration)
+	}
+	if len(got.Spec.Targets) != 2 || !got.Spec.Targets[0].Merges() {
+		t.Errorf("ConvertTo() targets = %+v, want the stashed targets", got.Spec.Targets)
+	}
+}
+
+func TestMasterPasswordRoundTrip(t *testing.T) {
+	in := &MasterPassword{
+		ObjectMeta: metav1.ObjectMeta{Name: "default"},
+		Spec: MasterPasswordSpec{
+			Length:      86,
+			Secret:      &SecretReference{Name: "imported", Create: false},
+			Annotations: map[string]string{"team": "ops"},
+		},
+		Status: MasterPasswordStatus{
+			SecretName:          "imported",
+			SecretNamespace:     "operator",
+			Ready:               true,
+			DependentSecrets:    3,
+			PasswordFingerprint: "hmac-sha256:ffeeddccbbaa99887766554433221100",
+			Conditions:          readyConditions(metav1.ConditionTrue),
+		},
+	}
+
+	hub := &v1beta1.MasterPassword{}
+	if err := in.DeepCopy().ConvertTo(hub); err != nil {
+		t.Fatalf("ConvertTo() error = %v", err)
+	}
+	got := &MasterPassword{}
+	if err := got.ConvertFrom(hub); err != nil {
+		t.Fatalf("ConvertFrom() error = %v", err)
+	}
+	if !apiequality.Semantic.DeepEqual(got, in) {
+		t.Errorf("round trip mismatch:\n got = %+v\nwant = %+v", got, in)
+	}
+}
+
+func TestMasterPasswordRoundTripKeepsReadyWithoutConditions(t *testing.T) {
+	in := &MasterPassword{
+		ObjectMeta: metav1.ObjectMeta{Name: "default"},
+		Spec:       MasterPasswordSpec{Length: 86},
+		Status:     MasterPasswordStatus{SecretName: "default", Ready: true},
+	}
+
+	hub := &v1beta1.MasterPassword{}
+	if err := in.DeepCopy().ConvertTo(hub); err != nil {
+		t.Fatalf("ConvertTo() error = %v", err)
+	}
+	got := &MasterPassword{}
+	if err := got.ConvertFrom(hub); err != nil {
+		t.Fatalf("ConvertFrom() error = %v", err)
+	}
+	if !apiequality.Semantic.DeepEqual(got, in) {
+		t.Errorf("round trip mismatch:\n got = %+v\nwant = %+v", got, in)
+	}
+
+	// A Ready condition set by the operator wins over the stashed value
+	hub.Status.Conditions = readyConditions(metav1.ConditionFalse)
+	got = &MasterPassword{}
+	if err := got.ConvertFrom(hub); err != nil {
+		t.Fatalf("ConvertFrom() error = %v", err)
+	}
+	if got.Status.Ready {
+		t.Errorf("ConvertFrom() kept ready although the Ready condition is False")
+	}
+}
+
+func TestMasterPasswordKeepsHubStatus(t *testing.T) {
+	hub := &v1beta1.MasterPassword{
+		ObjectMeta: metav1.ObjectMeta{Name: "default"},
+		Spec:       v1beta1.MasterPasswordSpec{Length: 86},
+		Status: v1beta1.MasterPasswordStatus{
+			SecretName:          "default",
+			SecretNamespace:     "operator",
+			DependentSecrets:    2,
+			PasswordFingerprint: "hmac-sha256:ffeeddccbbaa99887766554433221100",
+			PrimaryGeneration:   3,
+			PreviousGeneration:  2,
+			UnsealVerifier:      "verifier",
+			SharesPresent:       2,
+			HistoryGenerations:  []int{1, 2, 3},
+			Migration: &v1beta1.MigrationStatus{
+				FromGeneration:     2,
+				ToGeneration:       3,
+				MigratedNamespaces: []string{"team-a"},
+				TotalNamespaces:    2,
+			},
+			Conditions: readyConditions(metav1.ConditionTrue),
+		},
+	}
+
+	spoke := &MasterPassword{}
+	if err := spoke.ConvertFrom(hub.DeepCopy()); err != nil {
+		t.Fatalf("ConvertFrom() error = %v", err)
+	}
+	got := &v1beta1.MasterPassword{}
+	if err := spoke.DeepCopy().ConvertTo(got); err != nil {
+		t.Fatalf("ConvertTo() error = %v", err)
+	}
+	if !apiequality.Semantic.DeepEqual(got, hub) {
+		t.Errorf("round trip mismatch:\n got = %+v\nwant = %+v", got, hub)
+	}
+
+	// Editing the status through v1alpha1 must not drop the unseal verifier or the generations
+	spoke.Status.DependentSecrets = 5
+	got = &v1beta1.MasterPassword{}
+	if err := spoke.ConvertTo(got); err != nil {
+		t.Fatalf("ConvertTo() error = %v", err)
+	}
+	want := hub.Status.DeepCopy()
+	want.DependentSecrets = 5
+	if !apiequality.Semantic.DeepEqual(got.Status, *want) {
+		t.Errorf("ConvertTo() status =\n%+v\nwant\n%+v", got.Status, *want)
+	}
+}
+
+func TestMasterPasswordKeepsAllowedNamespaces(t *testing.T) {
+	hub := &v1beta1.MasterPassword{
+		ObjectMeta: metav1.ObjectMeta{Name: "team"},
+		Spec: v1beta1.MasterPasswordSpec{
+			Length: 86,
+			AllowedNamespaces: &v1beta1.AllowedNamespaces{
+				Names: []string{"team-a"},
+				NamespaceSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"team": "a"},
+				},
+			},
+			AllowCrossNamespaceContexts: true,
+			Migration: &v1beta1.MigrationSpec{
+				BatchSize: 5,
+				Interval:  metav1.Duration{Duration: time.Hour},
+			},
+			HistoryLimit: 10,
+			SealedValue:  "c2VhbGVk",
+			Storage:      v1beta1.MasterPasswordStorageSecret,
+			Shamir: &v1beta1.ShamirSpec{
+				Threshold: 2,
+				Shares: []v1beta1.ShareReference{
+					{Name: "share-a", Namespace: "team-a"},
+					{Name: "share-b", Namespace: "team-b", Key: "value"},
+				},
+			},
+			Format: v1beta1.MasterPasswordFormatMnemonic,
+			Source: &v1beta1.MasterPasswordSource{Env: "ROOT_PASSWORD"},
+			Validation: &v1beta1.MasterPasswordValidation{
+				MinLength:       32,
+				MinEntropyBits:  128,
+				BannedPasswords: []string{"platform-root"},
+			},
+			Encryption: v1beta1.MasterPasswordEncryptionKMS,
+			Deriver: &v1beta1.DeriverSpec{
+				Vault: &v1beta1.VaultTransitDeriver{
+					Address:        "https://vault.example.com:8200",
+					Key:            "derived-secrets",
+					TokenSecretRef: v1beta1.SecretKeyReference{Name: "vault-token", Namespace: "vault", Key: "token"},
+				},
+			},
+		},
+	}
+
+	spoke := &MasterPassword{}
+	if err := spoke.ConvertFrom(hub.DeepCopy()); err != nil {
+		t.Fatalf("ConvertFrom() error = %v", err)
+	}
+	if _, ok := spoke.Annotations[HubDataAnnotation]; !ok {
+		t.Fatalf("ConvertFrom() did not stash the hub spec")
+	}
+
+	got := &v1beta1.MasterPassword{}
+	if err := spoke.DeepCopy().ConvertTo(got); err != nil {
+		t.Fatalf("ConvertTo() error = %v", err)
+	}
+	if !apiequality.Semantic.DeepEqual(got, hub) {
+		t.Errorf("round trip mismatch:\n got = %+v\nwant = %+v", got, hub)
+	}
+
+	// Editing through v1alpha1 must not drop the namespace restriction
+	spoke.Spec.Length = 100
+	got = &v1beta1.MasterPassword{}
+	if err := spoke.ConvertTo(got); err != nil {
+		t.Fatalf("ConvertTo() error = %v", err)
+	}
+	if got.Spec.Length != 100 {
+		t.Errorf("ConvertTo() length = %d, want 100", got.Spec.Length)
+	}
+	if !apiequality.Semantic.DeepEqual(got.Spec.AllowedNamespaces, hub.Spec.AllowedNamespaces) {
+		t.Errorf("ConvertTo() allowedNamespaces = %+v, want %+v", got.Spec.AllowedNamespaces, hub.Spec.AllowedNamespaces)
+	}
+	if !got.Spec.AllowCrossNamespaceContexts {
+		t.Errorf("ConvertTo() dropped allowCrossNamespaceContexts")
+	}
+	if got.Spec.Migration == nil || got.Spec.Migration.BatchSize != 5 {
+		t.Errorf("ConvertTo() migration = %+v, want the stashed migration", got.Spec.Migration)
+	}
+	if got.Spec.HistoryLimit != 10 {
+		t.Errorf("ConvertTo() historyLimit = %d, want 10", got.Spec.HistoryLimit)
+	}
+	if got.Spec.SealedValue != hub.Spec.SealedValue {
+		t.Errorf("ConvertTo() sealedValue = %q, want %q", got.Spec.SealedValue, hub.Spec.SealedValue)
+	}
+	if got.Spec.Storage != hub.Spec.Storage {
+		t.Errorf("ConvertTo() storage = %q, want %q", got.Spec.Storage, hub.Spec.Storage)
+	}
+	if !apiequality.Semantic.DeepEqual(got.Spec.Shamir, hub.Spec.Shamir) {
+		t.Errorf("ConvertTo() shamir = %+v, want %+v", got.Spec.Shamir, hub.Spec.Shamir)
+	}
+	if got.Spec.Format != hub.Spec.Format {
+		t.Errorf("ConvertTo() format = %q, want %q", got.Spec.Format, hub.Spec.Format)
+	}
+	if !apiequality.Semantic.DeepEqual(got.Spec.Source, hub.Spec.Source) {
+		t.Errorf("ConvertTo() source = %+v, want %+v", got.Spec.Source, hub.Spec.Source)
+	}
+	if !apiequality.Semantic.DeepEqual(got.Spec.Validation, hub.Spec.Validation) {
+		t.Errorf("ConvertTo() validation = %+v, want %+v", got.Spec.Validation, hub.Spec.Validation)
+	}
+	if got.Spec.Encryption != hub.Spec.Encryption {
+		t.Errorf("ConvertTo() encryption = %q, want %q", got.Spec.Encryption, hub.Spec.Encryption)
+	}
+	if !apiequality.Semantic.DeepEqual(got.Spec.Deriver, hub.Spec.Deriver) {
+		t.Errorf("ConvertTo() deriver = %+v, want %+v", got.Spec.Deriver, hub.Spec.Deriver)
+	}
+}
+
+func TestMasterPasswordSpokeEditKeepsSecretLocation(t *testing.T) {
+	hub := &v1beta1.MasterPassword{
+		ObjectMeta: metav1.ObjectMeta{Name: "imported"},
+		Spec: v1beta1.MasterPasswordSpec{
+			Length: 86,
+			Secret: &v1beta1.SecretReference{Name: "root", Namespace: "secrets-root", Key: "value"},
+		},
+	}
+
+	spoke := &MasterPassword{}
+	if err := spoke.ConvertFrom(hub.DeepCopy()); err != nil {
+		t.Fatalf("ConvertFrom() error = %v", err)
+	}
+
+	spoke.Spec.Annotations = map[string]string{"team": "ops"}
+	got := &v1beta1.MasterPassword{}
+	if err := spoke.DeepCopy().ConvertTo(got); err != nil {
+		t.Fatalf("ConvertTo() error = %v", err)
+	}
+	if !apiequality.Semantic.DeepEqual(got.Spec.Secret, hub.Spec.Secret) {
+		t.Errorf("ConvertTo() secret = %+v, want %+v", got.Spec.Secret, hub.Spec.Secret)
+	}
+
+	// Pointing v1alpha1 at another secret drops the location of the old one
+	spoke.Spec.Secret.Name = "other"
+	got = &v1beta1.MasterPassword{}
+	if err := spoke.ConvertTo(got); err != nil {
+		t.Fatalf("ConvertTo() error = %v", err)
+	}
+	if got.Spec.Secret.Namespace != "" || got.Spec.Secret.Key != "" {
+		t.Errorf("ConvertTo() secret = %+v, want only name other", got.Spec.Secret)
+	}
+}
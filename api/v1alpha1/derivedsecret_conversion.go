@@ -0,0 +1,283 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"sort"
+
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/oleksiyp/derived-secret-operator/api/v1beta1"
+)
+
+// derivedSecretSpokeData holds DerivedSecret fields that v1beta1 cannot represent
+type derivedSecretSpokeData struct {
+	// Lengths keeps lengths set on non-custom keys, which v1alpha1 ignores and v1beta1 rejects
+	Lengths map[string]int `json:"lengths,omitempty"`
+	// Ready keeps status.ready when there is no Ready condition to derive it from
+	Ready bool `json:"ready,omitempty"`
+}
+
+// ConvertTo converts this DerivedSecret to the Hub version (v1beta1).
+func (src *DerivedSecret) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta1.DerivedSecret)
+
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	spec, spokeData := convertDerivedSecretSpecToHub(&src.Spec)
+	dst.Spec = spec
+
+	stashed := v1beta1.DerivedSecretSpec{}
+	ok, err := loadConversionData(dst, HubDataAnnotation, &stashed)
+	if err != nil {
+		return err
+	}
+	// Restore the original spec unless it was changed through v1alpha1
+	if ok {
+		original := convertDerivedSecretSpecFromHub(&stashed, spokeData.Lengths)
+		if apiequality.Semantic.DeepEqual(original, src.Spec) {
+			dst.Spec = stashed
+		} else {
+			restoreMasterPasswordKinds(&dst.Spec, &stashed)
+			restoreKeyDerivation(&dst.Spec, &stashed)
+			dst.Spec.DerivationID = stashed.DerivationID
+			dst.Spec.MasterPasswordGeneration = stashed.MasterPasswordGeneration
+			dst.Spec.AdoptionPolicy = stashed.AdoptionPolicy
+			dst.Spec.Target = stashed.Target
+			dst.Spec.Targets = stashed.Targets
+		}
+	}
+
+	spokeData.Ready = src.Status.Ready && meta.FindStatusCondition(src.Status.Conditions, "Ready") == nil
+	dropConversionData(dst, SpokeDataAnnotation)
+	if len(spokeData.Lengths) > 0 || spokeData.Ready {
+		if err := storeConversionData(dst, SpokeDataAnnotation, spokeData); err != nil {
+			return err
+		}
+	}
+
+	dst.Status = convertDerivedSecretStatusToHub(&src.Status)
+	stashedStatus := v1beta1.DerivedSecretStatus{}
+	ok, err = loadConversionData(dst, HubStatusAnnotation, &stashedStatus)
+	if err != nil {
+		return err
+	}
+	// Restore the original status unless it was changed through v1alpha1
+	if ok {
+		original := convertDerivedSecretStatusFromHub(&stashedStatus, spokeData.Ready)
+		if apiequality.Semantic.DeepEqual(original, src.Status) {
+			dst.Status = stashedStatus
+		} else {
+			restoreDerivedSecretStatus(&dst.Status, &stashedStatus)
+		}
+	}
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1beta1) to this version.
+func (dst *DerivedSecret) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1beta1.DerivedSecret)
+
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	spokeData := derivedSecretSpokeData{}
+	if _, err := loadConversionData(dst, SpokeDataAnnotation, &spokeData); err != nil {
+		return err
+	}
+	dst.Spec = convertDerivedSecretSpecFromHub(&src.Spec, spokeData.Lengths)
+
+	// Keep the hub spec if converting back would not reproduce it
+	dropConversionData(dst, HubDataAnnotation)
+	roundTripped, _ := convertDerivedSecretSpecToHub(&dst.Spec)
+	if !apiequality.Semantic.DeepEqual(roundTripped, src.Spec) {
+		if err := storeConversionData(dst, HubDataAnnotation, src.Spec); err != nil {
+			return err
+		}
+	}
+
+	// Keep the hub status if converting back would not reproduce it
+	dropConversionData(dst, HubStatusAnnotation)
+	dst.Status = convertDerivedSecretStatusFromHub(&src.Status, spokeData.Ready)
+	roundTrippedStatus := convertDerivedSecretStatusToHub(&dst.Status)
+	if !apiequality.Semantic.DeepEqual(roundTrippedStatus, src.Status) {
+		if err := storeConversionData(dst, HubStatusAnnotation, src.Status); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// convertDerivedSecretStatusToHub converts a v1alpha1 status to v1beta1
+func convertDerivedSecretStatusToHub(in *DerivedSecretStatus) v1beta1.DerivedSecretStatus {
+	out := v1beta1.DerivedSecretStatus{
+		LastUpdated:     in.LastUpdated.DeepCopy(),
+		KeyFingerprints: copyMap(in.KeyFingerprints),
+		Conditions:      copyConditions(in.Conditions),
+	}
+	if in.SecretName != "" {
+		out.Targets = []v1beta1.TargetStatus{{Name: in.SecretName, Ready: in.Ready}}
+	}
+	return out
+}
+
+// convertDerivedSecretStatusFromHub converts a v1beta1 status to v1alpha1, taking status.ready
+// from the Ready condition, or from stashedReady without one
+func convertDerivedSecretStatusFromHub(in *v1beta1.DerivedSecretStatus, stashedReady bool) DerivedSecretStatus {
+	out := DerivedSecretStatus{
+		Ready:           readyFromConditions(in.Conditions, stashedReady),
+		LastUpdated:     in.LastUpdated.DeepCopy(),
+		KeyFingerprints: copyMap(in.KeyFingerprints),
+		Conditions:      copyConditions(in.Conditions),
+	}
+	// v1alpha1 reports a single secret
+	if len(in.Targets) > 0 {
+		out.SecretName = in.Targets[0].Name
+	}
+	return out
+}
+
+// restoreDerivedSecretStatus keeps the status fields v1alpha1 cannot express after a v1alpha1
+// edit of the status, and the other targets while the first one is unchanged
+func restoreDerivedSecretStatus(status, stashed *v1beta1.DerivedSecretStatus) {
+	status.MasterPasswordGenerations = stashed.MasterPasswordGenerations
+	status.KeyVersions = stashed.KeyVersions
+	status.StagedRotation = stashed.StagedRotation
+	if len(status.Targets) == 1 && len(stashed.Targets) > 1 && status.Targets[0].Name == stashed.Targets[0].Name {
+		status.Targets = append(status.Targets, stashed.Targets[1:]...)
+	}
+}
+
+// restoreMasterPasswordKinds keeps the master password kind of keys that still use the
+// same master password after a v1alpha1 edit, since v1alpha1 cannot express kinds
+func restoreMasterPasswordKinds(spec, stashed *v1beta1.DerivedSecretSpec) {
+	for name, key := range spec.Keys {
+		stashedKey, ok := stashed.Keys[name]
+		if !ok || stashed.MasterPasswordFor(stashedKey) != spec.MasterPasswordFor(key) {
+			continue
+		}
+		if kind := stashed.MasterPasswordKindFor(stashedKey); kind != spec.MasterPasswordKindFor(key) {
+			key.MasterPasswordKind = kind
+			spec.Keys[name] = key
+		}
+	}
+}
+
+// restoreKeyDerivation keeps the derivation context, version and rotation schedule of keys
+// that still exist after a v1alpha1 edit, since changing them would change their values
+func restoreKeyDerivation(spec, stashed *v1beta1.DerivedSecretSpec) {
+	for name, key := range spec.Keys {
+		if stashedKey, ok := stashed.Keys[name]; ok {
+			key.ContextOverride = stashedKey.ContextOverride
+			key.Version = stashedKey.Version
+			key.Rotation = stashedKey.Rotation
+			spec.Keys[name] = key
+		}
+	}
+}
+
+// convertDerivedSecretSpecToHub converts a v1alpha1 spec to v1beta1. The most used
+// master password is hoisted to the secret level and the others become key overrides.
+func convertDerivedSecretSpecToHub(in *DerivedSecretSpec) (v1beta1.DerivedSecretSpec, derivedSecretSpokeData) {
+	out := v1beta1.DerivedSecretSpec{
+		MasterPassword: hoistMasterPassword(in.Keys),
+		Type:           in.Type,
+		Annotations:    copyMap(in.Annotations),
+		Labels:         copyMap(in.Labels),
+	}
+	spokeData := derivedSecretSpokeData{}
+
+	if in.Keys != nil {
+		out.Keys = make(map[string]v1beta1.DerivedKeySpec, len(in.Keys))
+	}
+	for name, key := range in.Keys {
+		outKey := v1beta1.DerivedKeySpec{
+			Type: v1beta1.SecretType(key.Type),
+		}
+		if key.Type == SecretTypeCustom {
+			outKey.Length = key.Length
+		} else if key.Length != 0 {
+			if spokeData.Lengths == nil {
+				spokeData.Lengths = make(map[string]int)
+			}
+			spokeData.Lengths[name] = key.Length
+		}
+		if mp := masterPasswordOrDefault(key.MasterPassword); mp != out.MasterPassword {
+			outKey.MasterPassword = mp
+		}
+		out.Keys[name] = outKey
+	}
+	return out, spokeData
+}
+
+// convertDerivedSecretSpecFromHub converts a v1beta1 spec to v1alpha1, restoring
+// lengths of non-custom keys from lengths.
+func convertDerivedSecretSpecFromHub(in *v1beta1.DerivedSecretSpec, lengths map[string]int) DerivedSecretSpec {
+	out := DerivedSecretSpec{
+		Type:        in.Type,
+		Annotations: copyMap(in.Annotations),
+		Labels:      copyMap(in.Labels),
+	}
+	if in.Keys != nil {
+		out.Keys = make(map[string]DerivedKeySpec, len(in.Keys))
+	}
+	for name, key := range in.Keys {
+		outKey := DerivedKeySpec{
+			Type:           SecretType(key.Type),
+			MasterPassword: in.MasterPasswordFor(key),
+			Length:         key.Length,
+		}
+		if length, ok := lengths[name]; ok && key.Type != v1beta1.SecretTypeCustom {
+			outKey.Length = length
+		}
+		out.Keys[name] = outKey
+	}
+	return out
+}
+
+// hoistMasterPassword returns the master password referenced by most keys,
+// breaking ties by name.
+func hoistMasterPassword(keys map[string]DerivedKeySpec) string {
+	counts := make(map[string]int)
+	for _, key := range keys {
+		counts[masterPasswordOrDefault(key.MasterPassword)]++
+	}
+	if len(counts) == 0 {
+		return v1beta1.DefaultMasterPasswordName
+	}
+
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	best := names[0]
+	for _, name := range names[1:] {
+		if counts[name] > counts[best] {
+			best = name
+		}
+	}
+	return best
+}
+
+// masterPasswordOrDefault returns name, or the default MasterPassword name if name is empty
+func masterPasswordOrDefault(name string) string {
+	if name == "" {
+		return v1beta1.DefaultMasterPasswordName
+	}
+	return name
+}
@@ -90,10 +90,16 @@ type DerivedSecretStatus struct {
 	// +optional
 	LastUpdated *metav1.Time `json:"lastUpdated,omitempty"`
 
-	// KeyHashes contains hash values (0-999) for each derived key to track updates without revealing passwords
+	// KeyHashes contains hash values (0-999) for each derived key to track updates without revealing passwords.
+	// Deprecated: no longer recorded, see KeyFingerprints
 	// +optional
 	KeyHashes map[string]int32 `json:"keyHashes,omitempty"`
 
+	// KeyFingerprints maps every derived key to an HMAC fingerprint of its value, keyed from the
+	// master password
+	// +optional
+	KeyFingerprints map[string]string `json:"keyFingerprints,omitempty"`
+
 	// Conditions represent the current state of the DerivedSecret resource.
 	// +listType=map
 	// +listMapKey=type
@@ -0,0 +1,188 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/oleksiyp/derived-secret-operator/api/v1beta1"
+)
+
+// masterPasswordSpokeData holds MasterPassword fields that v1beta1 cannot represent
+type masterPasswordSpokeData struct {
+	// Ready keeps status.ready when there is no Ready condition to derive it from
+	Ready bool `json:"ready,omitempty"`
+}
+
+// ConvertTo converts this MasterPassword to the Hub version (v1beta1).
+func (src *MasterPassword) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta1.MasterPassword)
+
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	dst.Spec = convertMasterPasswordSpecToHub(&src.Spec)
+
+	stashed := v1beta1.MasterPasswordSpec{}
+	ok, err := loadConversionData(dst, HubDataAnnotation, &stashed)
+	if err != nil {
+		return err
+	}
+	if ok {
+		if apiequality.Semantic.DeepEqual(convertMasterPasswordSpecFromHub(&stashed), src.Spec) {
+			dst.Spec = stashed
+		} else {
+			// Fields edited through v1alpha1 win, but v1beta1-only fields must not be lost
+			dst.Spec.AllowedNamespaces = stashed.AllowedNamespaces
+			dst.Spec.AllowCrossNamespaceContexts = stashed.AllowCrossNamespaceContexts
+			dst.Spec.Migration = stashed.Migration
+			dst.Spec.HistoryLimit = stashed.HistoryLimit
+			dst.Spec.SealedValue = stashed.SealedValue
+			dst.Spec.Storage = stashed.Storage
+			dst.Spec.Shamir = stashed.Shamir
+			dst.Spec.Format = stashed.Format
+			dst.Spec.Source = stashed.Source
+			dst.Spec.Validation = stashed.Validation
+			dst.Spec.Encryption = stashed.Encryption
+			dst.Spec.Deriver = stashed.Deriver
+			if dst.Spec.Secret != nil && stashed.Secret != nil && dst.Spec.Secret.Name == stashed.Secret.Name {
+				dst.Spec.Secret.Namespace = stashed.Secret.Namespace
+				dst.Spec.Secret.Key = stashed.Secret.Key
+			}
+		}
+	}
+
+	spokeData := masterPasswordSpokeData{
+		Ready: src.Status.Ready && meta.FindStatusCondition(src.Status.Conditions, "Ready") == nil,
+	}
+	dropConversionData(dst, SpokeDataAnnotation)
+	if spokeData.Ready {
+		if err := storeConversionData(dst, SpokeDataAnnotation, spokeData); err != nil {
+			return err
+		}
+	}
+
+	dst.Status = convertMasterPasswordStatusToHub(&src.Status)
+	stashedStatus := v1beta1.MasterPasswordStatus{}
+	ok, err = loadConversionData(dst, HubStatusAnnotation, &stashedStatus)
+	if err != nil {
+		return err
+	}
+	// Restore the original status unless it was changed through v1alpha1
+	if ok {
+		if apiequality.Semantic.DeepEqual(convertMasterPasswordStatusFromHub(&stashedStatus, spokeData.Ready),
+			src.Status) {
+			dst.Status = stashedStatus
+		} else {
+			// Losing the unseal verifier would let a different master password be unsealed
+			dst.Status.PrimaryGeneration = stashedStatus.PrimaryGeneration
+			dst.Status.PreviousGeneration = stashedStatus.PreviousGeneration
+			dst.Status.UnsealVerifier = stashedStatus.UnsealVerifier
+			dst.Status.SharesPresent = stashedStatus.SharesPresent
+			dst.Status.HistoryGenerations = stashedStatus.HistoryGenerations
+			dst.Status.Migration = stashedStatus.Migration
+		}
+	}
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1beta1) to this version.
+func (dst *MasterPassword) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1beta1.MasterPassword)
+
+	dst.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	spokeData := masterPasswordSpokeData{}
+	if _, err := loadConversionData(dst, SpokeDataAnnotation, &spokeData); err != nil {
+		return err
+	}
+	dst.Spec = convertMasterPasswordSpecFromHub(&src.Spec)
+
+	// Keep the hub spec if converting back would not reproduce it
+	dropConversionData(dst, HubDataAnnotation)
+	roundTripped := convertMasterPasswordSpecToHub(&dst.Spec)
+	if !apiequality.Semantic.DeepEqual(roundTripped, src.Spec) {
+		if err := storeConversionData(dst, HubDataAnnotation, src.Spec); err != nil {
+			return err
+		}
+	}
+
+	// Keep the hub status if converting back would not reproduce it
+	dropConversionData(dst, HubStatusAnnotation)
+	dst.Status = convertMasterPasswordStatusFromHub(&src.Status, spokeData.Ready)
+	roundTrippedStatus := convertMasterPasswordStatusToHub(&dst.Status)
+	if !apiequality.Semantic.DeepEqual(roundTrippedStatus, src.Status) {
+		if err := storeConversionData(dst, HubStatusAnnotation, src.Status); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// convertMasterPasswordStatusToHub converts a v1alpha1 status to v1beta1
+func convertMasterPasswordStatusToHub(in *MasterPasswordStatus) v1beta1.MasterPasswordStatus {
+	return v1beta1.MasterPasswordStatus{
+		SecretName:          in.SecretName,
+		SecretNamespace:     in.SecretNamespace,
+		DependentSecrets:    in.DependentSecrets,
+		PasswordFingerprint: in.PasswordFingerprint,
+		Conditions:          copyConditions(in.Conditions),
+	}
+}
+
+// convertMasterPasswordStatusFromHub converts a v1beta1 status to v1alpha1, taking status.ready
+// from the Ready condition, or from stashedReady without one
+func convertMasterPasswordStatusFromHub(in *v1beta1.MasterPasswordStatus, stashedReady bool) MasterPasswordStatus {
+	return MasterPasswordStatus{
+		SecretName:          in.SecretName,
+		SecretNamespace:     in.SecretNamespace,
+		Ready:               readyFromConditions(in.Conditions, stashedReady),
+		DependentSecrets:    in.DependentSecrets,
+		PasswordFingerprint: in.PasswordFingerprint,
+		Conditions:          copyConditions(in.Conditions),
+	}
+}
+
+// convertMasterPasswordSpecToHub converts a v1alpha1 spec to v1beta1
+func convertMasterPasswordSpecToHub(in *MasterPasswordSpec) v1beta1.MasterPasswordSpec {
+	out := v1beta1.MasterPasswordSpec{
+		Length:      in.Length,
+		Annotations: copyMap(in.Annotations),
+	}
+	if in.Secret != nil {
+		out.Secret = &v1beta1.SecretReference{
+			Name:   in.Secret.Name,
+			Create: in.Secret.Create,
+		}
+	}
+	return out
+}
+
+// convertMasterPasswordSpecFromHub converts a v1beta1 spec to v1alpha1, dropping
+// fields that only exist in v1beta1
+func convertMasterPasswordSpecFromHub(in *v1beta1.MasterPasswordSpec) MasterPasswordSpec {
+	out := MasterPasswordSpec{
+		Length:      in.Length,
+		Annotations: copyMap(in.Annotations),
+	}
+	if in.Secret != nil {
+		out.Secret = &SecretReference{
+			Name:   in.Secret.Name,
+			Create: in.Secret.Create,
+		}
+	}
+	return out
+}
@@ -72,10 +72,16 @@ type MasterPasswordStatus struct {
 	// +optional
 	DependentSecrets int `json:"dependentSecrets,omitempty"`
 
-	// PasswordHash is a hash value (0-999) of the master password to track changes without revealing the password
+	// PasswordHash is a hash value (0-999) of the master password to track changes without revealing the password.
+	// Deprecated: no longer recorded, see PasswordFingerprint
 	// +optional
 	PasswordHash int32 `json:"passwordHash,omitempty"`
 
+	// PasswordFingerprint is an HMAC fingerprint of the master password, keyed from the master
+	// password itself
+	// +optional
+	PasswordFingerprint string `json:"passwordFingerprint,omitempty"`
+
 	// Conditions represent the current state of the MasterPassword resource.
 	// +listType=map
 	// +listMapKey=type
@@ -142,6 +142,20 @@ func (in *DerivedSecretStatus) DeepCopyInto(out *DerivedSecretStatus) {
 		in, out := &in.LastUpdated, &out.LastUpdated
 		*out = (*in).DeepCopy()
 	}
+	if in.KeyHashes != nil {
+		in, out := &in.KeyHashes, &out.KeyHashes
+		*out = make(map[string]int32, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.KeyFingerprints != nil {
+		in, out := &in.KeyFingerprints, &out.KeyFingerprints
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make([]v1.Condition, len(*in))
@@ -0,0 +1,153 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterDerivedSecretSpec defines the desired state of ClusterDerivedSecret
+type ClusterDerivedSecretSpec struct {
+	// NamespaceSelector selects the namespaces the derived secret is written to.
+	// An empty selector matches every namespace.
+	// +kubebuilder:validation:Required
+	NamespaceSelector metav1.LabelSelector `json:"namespaceSelector"`
+
+	// Template is the DerivedSecret spec applied in every selected namespace.
+	// Keys are derived from the context <namespace>/cluster/<derivationId or name>/<key>, so
+	// every namespace gets distinct values that no namespaced DerivedSecret can derive.
+	// Staged rotations are not supported; bump key versions or use a rotation schedule instead
+	// +kubebuilder:validation:Required
+	Template DerivedSecretSpec `json:"template"`
+}
+
+// NamespaceStatus reports the derived secret written to a single namespace
+type NamespaceStatus struct {
+	// Namespace is the name of the namespace
+	Namespace string `json:"namespace"`
+
+	// Ready indicates whether every target Secret in the namespace holds the derived keys
+	Ready bool `json:"ready"`
+
+	// Reason is a machine-readable reason the namespace is not ready
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// Message explains why the namespace is not ready
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Targets reports the Secrets written to the namespace
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Targets []TargetStatus `json:"targets,omitempty"`
+
+	// KeyFingerprints maps every derived key to an HMAC fingerprint of its value in the namespace
+	// +optional
+	KeyFingerprints map[string]string `json:"keyFingerprints,omitempty"`
+
+	// KeyVersions lists, per key, the versions it has had in the namespace, the current one last.
+	// At most MaxKeyVersionHistory versions are kept per key
+	// +optional
+	KeyVersions map[string][]KeyVersionStatus `json:"keyVersions,omitempty"`
+}
+
+// ClusterDerivationPrefix starts the derivationId a ClusterDerivedSecret derives with in every
+// namespace. A namespaced DerivedSecret cannot use it, since its derivationId has no slash
+const ClusterDerivationPrefix = "cluster/"
+
+// ClusterDerivedSecretStatus defines the observed state of ClusterDerivedSecret.
+type ClusterDerivedSecretStatus struct {
+	// Namespaces reports the derived secret of every selected namespace
+	// +listType=map
+	// +listMapKey=namespace
+	// +optional
+	Namespaces []NamespaceStatus `json:"namespaces,omitempty"`
+
+	// LastUpdated is the last time the secrets were updated
+	// +optional
+	LastUpdated *metav1.Time `json:"lastUpdated,omitempty"`
+
+	// Conditions represent the current state of the ClusterDerivedSecret resource.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,shortName=cds
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Namespaces",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].message`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ClusterDerivedSecret is the Schema for the clusterderivedsecrets API.
+// It writes a derived secret into every namespace matching its namespaceSelector.
+type ClusterDerivedSecret struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty,omitzero"`
+
+	// spec defines the desired state of ClusterDerivedSecret
+	// +required
+	Spec ClusterDerivedSecretSpec `json:"spec"`
+
+	// status defines the observed state of ClusterDerivedSecret
+	// +optional
+	Status ClusterDerivedSecretStatus `json:"status,omitempty,omitzero"`
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterDerivedSecretList contains a list of ClusterDerivedSecret
+type ClusterDerivedSecretList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterDerivedSecret `json:"items"`
+}
+
+// DerivedSecretFor returns the DerivedSecret the ClusterDerivedSecret stands for in the given
+// namespace, carrying the previous status of that namespace. Its derivationId is prefixed with
+// ClusterDerivationPrefix, so its values differ from those of any namespaced DerivedSecret
+func (cds *ClusterDerivedSecret) DerivedSecretFor(namespace string) *DerivedSecret {
+	ds := &DerivedSecret{
+		ObjectMeta: metav1.ObjectMeta{Name: cds.Name, Namespace: namespace},
+		Spec:       *cds.Spec.Template.DeepCopy(),
+	}
+	id := cds.Name
+	if ds.Spec.DerivationID != "" {
+		id = ds.Spec.DerivationID
+	}
+	ds.Spec.DerivationID = ClusterDerivationPrefix + id
+	for _, status := range cds.Status.Namespaces {
+		if status.Namespace == namespace {
+			status := status.DeepCopy()
+			ds.Status.Targets = status.Targets
+			ds.Status.KeyFingerprints = status.KeyFingerprints
+			ds.Status.KeyVersions = status.KeyVersions
+		}
+	}
+	return ds
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterDerivedSecret{}, &ClusterDerivedSecretList{})
+}
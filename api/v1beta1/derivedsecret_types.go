@@ -0,0 +1,501 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SecretType is the type of derived secret
+// +kubebuilder:validation:Enum=password;encryption-key;custom
+type SecretType string
+
+const (
+	// SecretTypePassword generates a 26-character password
+	SecretTypePassword SecretType = "password"
+	// SecretTypeEncryptionKey generates a 48-character encryption key
+	SecretTypeEncryptionKey SecretType = "encryption-key"
+	// SecretTypeCustom generates a secret of custom length
+	SecretTypeCustom SecretType = "custom"
+)
+
+// DefaultMasterPasswordName is the MasterPassword used when none is referenced
+const DefaultMasterPasswordName = "default"
+
+// MasterPasswordKind is the kind of master password a key is derived from
+// +kubebuilder:validation:Enum=MasterPassword;NamespaceMasterPassword
+type MasterPasswordKind string
+
+const (
+	// KindMasterPassword refers to a cluster-scoped MasterPassword
+	KindMasterPassword MasterPasswordKind = "MasterPassword"
+	// KindNamespaceMasterPassword refers to a NamespaceMasterPassword in the DerivedSecret's namespace
+	KindNamespaceMasterPassword MasterPasswordKind = "NamespaceMasterPassword"
+)
+
+// AdoptionPolicy controls whether a DerivedSecret takes over an existing Secret it does not own
+// +kubebuilder:validation:Enum=Never;IfUnowned;Force
+type AdoptionPolicy string
+
+const (
+	// AdoptionPolicyNever never takes over an existing Secret
+	AdoptionPolicyNever AdoptionPolicy = "Never"
+	// AdoptionPolicyIfUnowned takes over an existing Secret that has no controller
+	AdoptionPolicyIfUnowned AdoptionPolicy = "IfUnowned"
+	// AdoptionPolicyForce takes over an existing Secret even if another controller owns it
+	AdoptionPolicyForce AdoptionPolicy = "Force"
+)
+
+// MergeMode controls how derived keys are written into the target Secret
+// +kubebuilder:validation:Enum=Replace;Merge
+type MergeMode string
+
+const (
+	// MergeModeReplace makes the derived keys the only data of the Secret
+	MergeModeReplace MergeMode = "Replace"
+	// MergeModeMerge only manages the derived keys and keeps any other data of the Secret
+	MergeModeMerge MergeMode = "Merge"
+)
+
+// SecretTemplateMetadata defines labels and annotations of a target Secret
+type SecretTemplateMetadata struct {
+	// Labels to apply to the Secret, merged over spec.labels
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations to apply to the Secret, merged over spec.annotations
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// SecretTemplate defines how a target Secret is created
+type SecretTemplate struct {
+	// Metadata to apply to the Secret
+	// +optional
+	Metadata SecretTemplateMetadata `json:"metadata,omitempty"`
+
+	// Immutable marks the Secret as immutable. When its data has to change,
+	// a Secret owned by the DerivedSecret is deleted and created again
+	// +optional
+	Immutable bool `json:"immutable,omitempty"`
+}
+
+// DerivedSecretTarget configures a Secret the derived keys are written to
+type DerivedSecretTarget struct {
+	// Name is the name of the Secret. If not specified, defaults to the DerivedSecret name
+	// +optional
+	// +kubebuilder:validation:MaxLength=253
+	Name string `json:"name,omitempty"`
+
+	// Template defines the metadata and immutability of the Secret
+	// +optional
+	Template *SecretTemplate `json:"template,omitempty"`
+
+	// MergeMode controls whether the derived keys replace the Secret data or are merged into it.
+	// In Merge mode only the keys listed in spec.keys are managed, tracked in the managed-keys
+	// annotation, and other keys, labels and annotations are kept. If not specified, defaults to Replace
+	// +optional
+	MergeMode MergeMode `json:"mergeMode,omitempty"`
+}
+
+// Merges reports whether the derived keys are merged into the target Secret
+func (t *DerivedSecretTarget) Merges() bool {
+	return t != nil && t.MergeMode == MergeModeMerge
+}
+
+// MasterPasswordReference identifies the master password a key is derived from
+type MasterPasswordReference struct {
+	Kind MasterPasswordKind
+	Name string
+}
+
+// AuthorizedUserAnnotation records the user whose "use" permission on the referenced
+// MasterPasswords was checked when the DerivedSecret spec was last changed
+const AuthorizedUserAnnotation = "secrets.oleksiyp.dev/authorized-user"
+
+// PinnedGenerationAnnotation pins the keys of a DerivedSecret derived from MasterPasswords
+// to the primary or previous generation of their master password, unless the spec pins one
+const PinnedGenerationAnnotation = "secrets.oleksiyp.dev/master-password-generation"
+
+// ManagedKeysAnnotation lists the comma-separated data keys the operator manages in a Secret
+// written in Merge mode, so that keys removed from the spec can be removed from the Secret
+const ManagedKeysAnnotation = "secrets.oleksiyp.dev/managed-keys"
+
+// RotationAnnotation requests the next step of a staged rotation of a DerivedSecret.
+// Each step is applied once; set the annotation to the next step to continue
+const RotationAnnotation = "secrets.oleksiyp.dev/rotation"
+
+// RotationKeysAnnotation lists the comma-separated keys a staged rotation applies to.
+// If not set, all keys without a rotation schedule are rotated
+const RotationKeysAnnotation = "secrets.oleksiyp.dev/rotation-keys"
+
+// RotationAction is a step of a staged rotation
+type RotationAction string
+
+const (
+	// RotationActionStage publishes the next value of every rotated key as <key>.next
+	RotationActionStage RotationAction = "stage"
+	// RotationActionPromote bumps the version of every rotated key, so the next value becomes
+	// current and the old one is published as <key>.previous
+	RotationActionPromote RotationAction = "promote"
+	// RotationActionFinalize drops the old values and completes the rotation
+	RotationActionFinalize RotationAction = "finalize"
+)
+
+// NextKeySuffix is appended to a key name to publish its staged value
+const NextKeySuffix = ".next"
+
+// DerivedKeySpec defines how to derive a single key
+// +kubebuilder:validation:XValidation:rule="!has(self.length) || self.type == 'custom'",message="length may only be set for custom keys"
+type DerivedKeySpec struct {
+	// Type is the type of secret to generate
+	// +kubebuilder:validation:Required
+	Type SecretType `json:"type"`
+
+	// Length is the length of the generated secret.
+	// Only allowed for the custom type, which defaults to 26 characters.
+	// +optional
+	// +kubebuilder:validation:Minimum=22
+	// +kubebuilder:validation:Maximum=256
+	Length int `json:"length,omitempty"`
+
+	// MasterPassword overrides spec.masterPassword for this key
+	// +optional
+	MasterPassword string `json:"masterPassword,omitempty"`
+
+	// MasterPasswordKind overrides spec.masterPasswordKind for this key
+	// +optional
+	MasterPasswordKind MasterPasswordKind `json:"masterPasswordKind,omitempty"`
+
+	// ContextOverride replaces the derivation context of this key, which defaults to
+	// <namespace>/<derivationId or name>/<key>. Setting it to the previous context keeps
+	// the value when a DerivedSecret is renamed, split or moved. A context outside the
+	// DerivedSecret's namespace requires a MasterPassword with allowCrossNamespaceContexts.
+	// It may not hold @ or #, which separate the key version and rotation epoch
+	// +optional
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:Pattern=`^[^@#]+$`
+	ContextOverride string `json:"contextOverride,omitempty"`
+
+	// Version is mixed into the derivation context. Bumping it rotates only this key,
+	// and setting it back restores the previous value. Version 0 is the original value
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	Version int `json:"version,omitempty"`
+
+	// Rotation rotates the key on a fixed schedule
+	// +optional
+	Rotation *RotationSpec `json:"rotation,omitempty"`
+}
+
+// PreviousKeySuffix is appended to a key name to publish its previous value during a rotation overlap
+const PreviousKeySuffix = ".previous"
+
+// RotationSpec rotates a key at every interval boundary, counted from the Unix epoch.
+// The value only depends on the epoch bucket, so every replica agrees on it.
+// +kubebuilder:validation:XValidation:rule="duration(self.interval) >= duration('1h')",message="interval must be at least 1h"
+// +kubebuilder:validation:XValidation:rule="!has(self.overlap) || duration(self.overlap) < duration(self.interval)",message="overlap must be shorter than interval"
+type RotationSpec struct {
+	// Interval between rotations, e.g. 2160h for 90 days
+	// +kubebuilder:validation:Required
+	Interval metav1.Duration `json:"interval"`
+
+	// Overlap is how long after a rotation the previous value is still published
+	// as <key>.previous. If not specified, the previous value is not published
+	// +optional
+	Overlap *metav1.Duration `json:"overlap,omitempty"`
+}
+
+// OverlapDuration returns the overlap window, or 0 if none is set
+func (r *RotationSpec) OverlapDuration() time.Duration {
+	if r.Overlap == nil {
+		return 0
+	}
+	return r.Overlap.Duration
+}
+
+// DerivedSecretSpec defines the desired state of DerivedSecret
+// +kubebuilder:validation:XValidation:rule="!(has(self.target) && has(self.targets))",message="target and targets are mutually exclusive"
+type DerivedSecretSpec struct {
+	// MasterPassword is the name of the MasterPassword used to derive all keys
+	// +optional
+	// +kubebuilder:default="default"
+	MasterPassword string `json:"masterPassword,omitempty"`
+
+	// MasterPasswordKind is the kind of master password referenced by masterPassword.
+	// A NamespaceMasterPassword is looked up in the DerivedSecret's namespace.
+	// If not specified, defaults to MasterPassword
+	// +optional
+	MasterPasswordKind MasterPasswordKind `json:"masterPasswordKind,omitempty"`
+
+	// DerivationID replaces the DerivedSecret name in the derivation context of its keys,
+	// so that renaming the DerivedSecret keeps the derived values. It may not hold /, @ or #
+	// +optional
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:Pattern=`^[^/@#]+$`
+	DerivationID string `json:"derivationId,omitempty"`
+
+	// MasterPasswordGeneration pins the keys derived from MasterPasswords to the given
+	// generation of their master password, which must be its primary or previous one.
+	// If not specified, the PinnedGenerationAnnotation or the MasterPassword's migration
+	// decides the generation
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MasterPasswordGeneration int `json:"masterPasswordGeneration,omitempty"`
+
+	// Type is the type of secret to create
+	// +optional
+	// +kubebuilder:default=Opaque
+	Type corev1.SecretType `json:"type,omitempty"`
+
+	// Annotations to apply to the generated secret
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Labels to apply to the generated secret
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// AdoptionPolicy controls what happens when a Secret with the DerivedSecret's name
+	// already exists and is not owned by it. Secrets holding a master password are
+	// never taken over. If not specified, defaults to Never
+	// +optional
+	AdoptionPolicy AdoptionPolicy `json:"adoptionPolicy,omitempty"`
+
+	// Target configures the Secret the derived keys are written to
+	// +optional
+	Target *DerivedSecretTarget `json:"target,omitempty"`
+
+	// Targets writes the derived keys to several Secrets, each with its own name.
+	// Mutually exclusive with target
+	// +optional
+	// +listType=atomic
+	// +kubebuilder:validation:MaxItems=16
+	// +kubebuilder:validation:XValidation:rule="self.all(t, has(t.name))",message="targets must have a name"
+	// +kubebuilder:validation:XValidation:rule="self.all(t, self.exists_one(u, has(u.name) && has(t.name) && u.name == t.name))",message="target names must be unique"
+	Targets []DerivedSecretTarget `json:"targets,omitempty"`
+
+	// Keys is a map of key names to their derivation specifications. Names ending in .previous or
+	// .next are reserved for the values published during rotations
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinProperties=1
+	// +kubebuilder:validation:XValidation:rule="self.all(k, !k.endsWith('.previous') && !k.endsWith('.next'))",message="key names ending in .previous or .next are reserved for rotations"
+	Keys map[string]DerivedKeySpec `json:"keys"`
+}
+
+// TargetSecrets returns the Secrets the derived keys are written to, with names
+// defaulted to the DerivedSecret name
+func (ds *DerivedSecret) TargetSecrets() []DerivedSecretTarget {
+	targets := ds.Spec.Targets
+	if len(targets) == 0 {
+		target := DerivedSecretTarget{}
+		if ds.Spec.Target != nil {
+			target = *ds.Spec.Target
+		}
+		targets = []DerivedSecretTarget{target}
+	}
+
+	out := make([]DerivedSecretTarget, 0, len(targets))
+	for _, target := range targets {
+		if target.Name == "" {
+			target.Name = ds.Name
+		}
+		out = append(out, target)
+	}
+	return out
+}
+
+// PinnedGeneration returns the master password generation the DerivedSecret is pinned to
+// by its spec or the PinnedGenerationAnnotation, or 0 if it is not pinned
+func (ds *DerivedSecret) PinnedGeneration() int {
+	if ds.Spec.MasterPasswordGeneration != 0 {
+		return ds.Spec.MasterPasswordGeneration
+	}
+	generation, err := strconv.Atoi(ds.Annotations[PinnedGenerationAnnotation])
+	if err != nil || generation < 0 {
+		return 0
+	}
+	return generation
+}
+
+// MasterPasswordFor returns the name of the MasterPassword the given key is derived from
+func (s *DerivedSecretSpec) MasterPasswordFor(keySpec DerivedKeySpec) string {
+	if keySpec.MasterPassword != "" {
+		return keySpec.MasterPassword
+	}
+	if s.MasterPassword != "" {
+		return s.MasterPassword
+	}
+	return DefaultMasterPasswordName
+}
+
+// MasterPasswordKindFor returns the kind of master password the given key is derived from
+func (s *DerivedSecretSpec) MasterPasswordKindFor(keySpec DerivedKeySpec) MasterPasswordKind {
+	if keySpec.MasterPasswordKind != "" {
+		return keySpec.MasterPasswordKind
+	}
+	if s.MasterPasswordKind != "" {
+		return s.MasterPasswordKind
+	}
+	return KindMasterPassword
+}
+
+// MasterPasswordRefFor returns the master password the given key is derived from
+func (s *DerivedSecretSpec) MasterPasswordRefFor(keySpec DerivedKeySpec) MasterPasswordReference {
+	return MasterPasswordReference{
+		Kind: s.MasterPasswordKindFor(keySpec),
+		Name: s.MasterPasswordFor(keySpec),
+	}
+}
+
+// MaxKeyVersionHistory is the number of versions kept per key in the status history
+const MaxKeyVersionHistory = 10
+
+// KeyVersionStatus records a version a key has been derived with
+type KeyVersionStatus struct {
+	// Version is the key version
+	Version int `json:"version"`
+
+	// Fingerprint is the HMAC fingerprint of the value derived for the version
+	// +optional
+	Fingerprint string `json:"fingerprint,omitempty"`
+
+	// Epoch is the rotation epoch of the value, for keys with a rotation schedule
+	// +optional
+	Epoch int64 `json:"epoch,omitempty"`
+
+	// AppliedAt is when the version was written to the secret
+	AppliedAt metav1.Time `json:"appliedAt"`
+}
+
+// TargetStatus reports the state of a Secret written by a DerivedSecret
+type TargetStatus struct {
+	// Name is the name of the Secret
+	Name string `json:"name"`
+
+	// Ready indicates whether the Secret holds the derived keys
+	Ready bool `json:"ready"`
+
+	// Reason is a machine-readable reason the Secret is not ready
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// Message explains why the Secret is not ready
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// DerivedSecretStatus defines the observed state of DerivedSecret.
+type DerivedSecretStatus struct {
+	// Targets reports the Secrets written by the DerivedSecret
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Targets []TargetStatus `json:"targets,omitempty"`
+
+	// LastUpdated is the last time the secret was updated
+	// +optional
+	LastUpdated *metav1.Time `json:"lastUpdated,omitempty"`
+
+	// KeyFingerprints maps every derived key to an HMAC fingerprint of its value, keyed from the
+	// master password. They track updates without revealing the values, and dsctl verify checks
+	// a Secret against them
+	// +optional
+	KeyFingerprints map[string]string `json:"keyFingerprints,omitempty"`
+
+	// MasterPasswordGenerations maps every MasterPassword the keys are derived from to the
+	// generation of its master password they were derived with
+	// +optional
+	MasterPasswordGenerations map[string]int `json:"masterPasswordGenerations,omitempty"`
+
+	// KeyVersions lists, per key, the versions it has had, the current one last.
+	// At most MaxKeyVersionHistory versions are kept per key
+	// +optional
+	KeyVersions map[string][]KeyVersionStatus `json:"keyVersions,omitempty"`
+
+	// StagedRotation reports a staged rotation in progress
+	// +optional
+	StagedRotation *StagedRotationStatus `json:"stagedRotation,omitempty"`
+
+	// Conditions represent the current state of the DerivedSecret resource.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// StagedRotationPhase is the phase of a staged rotation in progress
+// +kubebuilder:validation:Enum=Staged;Promoted
+type StagedRotationPhase string
+
+const (
+	// StagedRotationStaged means the next values are published as <key>.next
+	StagedRotationStaged StagedRotationPhase = "Staged"
+	// StagedRotationPromoted means the next values are current and the old values are
+	// published as <key>.previous
+	StagedRotationPromoted StagedRotationPhase = "Promoted"
+)
+
+// StagedRotationStatus reports a staged rotation in progress
+type StagedRotationStatus struct {
+	// Phase of the rotation
+	Phase StagedRotationPhase `json:"phase"`
+
+	// FromVersions maps every rotated key to the version it is rotated from
+	FromVersions map[string]int `json:"fromVersions"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:storageversion
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Secret",type=string,JSONPath=`.status.targets[*].name`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// DerivedSecret is the Schema for the derivedsecrets API
+type DerivedSecret struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty,omitzero"`
+
+	// spec defines the desired state of DerivedSecret
+	// +required
+	Spec DerivedSecretSpec `json:"spec"`
+
+	// status defines the observed state of DerivedSecret
+	// +optional
+	Status DerivedSecretStatus `json:"status,omitempty,omitzero"`
+}
+
+// +kubebuilder:object:root=true
+
+// DerivedSecretList contains a list of DerivedSecret
+type DerivedSecretList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DerivedSecret `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DerivedSecret{}, &DerivedSecretList{})
+}
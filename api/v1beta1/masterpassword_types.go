@@ -0,0 +1,591 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"fmt"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// MasterPasswordGenerationAnnotation records the generation of the primary master password
+// in its secret. A secret without it holds generation 1
+const MasterPasswordGenerationAnnotation = "secrets.oleksiyp.dev/generation"
+
+// PreviousMasterPasswordSuffix is appended to the data key of a master password secret to hold
+// the previous generation of the master password
+const PreviousMasterPasswordSuffix = ".previous"
+
+// RegenerateAnnotation requests a new random master password for a MasterPassword generated by
+// the operator. Each new value of the annotation regenerates it once
+const RegenerateAnnotation = "secrets.oleksiyp.dev/regenerate"
+
+// RollbackAnnotation requests restoring the given generation of the master password from the
+// history of a MasterPassword. The restored value becomes a new primary generation
+const RollbackAnnotation = "secrets.oleksiyp.dev/rollback-to"
+
+// DefaultHistoryLimit is the number of master password generations kept in the history secret
+// when none is set
+const DefaultHistoryLimit = 5
+
+// DefaultShareKey is the secret data key holding a Shamir share when none is set
+const DefaultShareKey = "share"
+
+// DefaultMasterPasswordKey is the secret data key holding the master password when none is set
+const DefaultMasterPasswordKey = "masterPassword"
+
+// SecretReference defines the secret where the master password is stored
+type SecretReference struct {
+	// Name is the name of the secret
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// Namespace is the namespace of the secret.
+	// If not specified, defaults to the operator namespace
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Key is the secret data key holding the master password.
+	// If not specified, defaults to masterPassword
+	// +optional
+	Key string `json:"key,omitempty"`
+
+	// Create indicates whether to create the secret if it doesn't exist
+	// +optional
+	// +kubebuilder:default=true
+	Create bool `json:"create,omitempty"`
+}
+
+// DataKey returns the secret data key holding the master password
+func (r *SecretReference) DataKey() string {
+	if r == nil || r.Key == "" {
+		return DefaultMasterPasswordKey
+	}
+	return r.Key
+}
+
+// AllowedNamespaces restricts which namespaces may derive secrets from a MasterPassword.
+// A namespace is allowed if it is listed in Names or matches NamespaceSelector.
+type AllowedNamespaces struct {
+	// Names lists the namespaces allowed to use the MasterPassword
+	// +optional
+	// +listType=set
+	Names []string `json:"names,omitempty"`
+
+	// NamespaceSelector selects the namespaces allowed to use the MasterPassword.
+	// An empty selector matches every namespace.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+}
+
+// Allows reports whether the given namespace may derive secrets from the MasterPassword.
+// A nil AllowedNamespaces allows every namespace.
+func (a *AllowedNamespaces) Allows(namespace *corev1.Namespace) (bool, error) {
+	if a == nil || slices.Contains(a.Names, namespace.Name) {
+		return true, nil
+	}
+	if a.NamespaceSelector == nil {
+		return false, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(a.NamespaceSelector)
+	if err != nil {
+		return false, fmt.Errorf("invalid namespaceSelector: %w", err)
+	}
+	return selector.Matches(labels.Set(namespace.Labels)), nil
+}
+
+// MasterPasswordStorage selects where the master password of a MasterPassword is kept
+// +kubebuilder:validation:Enum=Secret;Memory
+type MasterPasswordStorage string
+
+const (
+	// MasterPasswordStorageSecret keeps the master password in a Secret
+	MasterPasswordStorageSecret MasterPasswordStorage = "Secret"
+	// MasterPasswordStorageMemory keeps the master password in the operator's memory only.
+	// It is unsealed through the operator's unseal endpoint after every restart
+	MasterPasswordStorageMemory MasterPasswordStorage = "Memory"
+)
+
+// MasterPasswordFormat selects the format of a master password
+// +kubebuilder:validation:Enum=Base62;Mnemonic
+type MasterPasswordFormat string
+
+const (
+	// MasterPasswordFormatBase62 is a random Base62 string of the configured length
+	MasterPasswordFormatBase62 MasterPasswordFormat = "Base62"
+	// MasterPasswordFormatMnemonic is 24 BIP39 English words with a checksum, which can be written
+	// down and typed back to recover the master password
+	MasterPasswordFormatMnemonic MasterPasswordFormat = "Mnemonic"
+)
+
+// MasterPasswordEncryption selects how the master password is encrypted in its Secret
+// +kubebuilder:validation:Enum=None;KMS
+type MasterPasswordEncryption string
+
+const (
+	// MasterPasswordEncryptionNone stores the master password as is, protected by the encryption
+	// at rest of the cluster only
+	MasterPasswordEncryptionNone MasterPasswordEncryption = "None"
+	// MasterPasswordEncryptionKMS stores the master password as an envelope encrypted by a data
+	// key, which is encrypted by the KMS plugin the operator is configured with
+	MasterPasswordEncryptionKMS MasterPasswordEncryption = "KMS"
+)
+
+// MasterPasswordSpec defines the desired state of MasterPassword
+// +kubebuilder:validation:XValidation:rule="!has(self.storage) || self.storage != 'Memory' || (!has(self.secret) && !has(self.sealedValue) && !has(self.migration))",message="secret, sealedValue and migration cannot be used with Memory storage"
+// +kubebuilder:validation:XValidation:rule="!has(self.shamir) || (!has(self.secret) && !has(self.sealedValue) && !has(self.migration) && !has(self.storage))",message="secret, sealedValue, migration and storage cannot be used with shamir"
+// +kubebuilder:validation:XValidation:rule="!has(self.source) || (!has(self.secret) && !has(self.sealedValue) && !has(self.migration) && !has(self.storage) && !has(self.shamir))",message="secret, sealedValue, migration, storage and shamir cannot be used with source"
+// +kubebuilder:validation:XValidation:rule="!has(self.deriver) || (!has(self.secret) && !has(self.sealedValue) && !has(self.migration) && !has(self.storage) && !has(self.shamir) && !has(self.source) && !has(self.encryption))",message="secret, sealedValue, migration, storage, shamir, source and encryption cannot be used with deriver"
+// +kubebuilder:validation:XValidation:rule="!has(self.encryption) || self.encryption != 'KMS' || ((!has(self.storage) || self.storage == 'Secret') && !has(self.shamir) && !has(self.source))",message="KMS encryption can only be used with Secret storage"
+type MasterPasswordSpec struct {
+	// Length is the length of the generated master password
+	// +optional
+	// +kubebuilder:default=86
+	// +kubebuilder:validation:Minimum=22
+	// +kubebuilder:validation:Maximum=256
+	Length int `json:"length,omitempty"`
+
+	// Secret defines the secret where the master password is stored
+	// If not specified, defaults to the masterPassword key of <name>-mp in the operator namespace
+	// +optional
+	Secret *SecretReference `json:"secret,omitempty"`
+
+	// Annotations to apply to the generated secret
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// AllowedNamespaces restricts which namespaces may derive secrets from this MasterPassword.
+	// If not specified, every namespace may use it.
+	// +optional
+	AllowedNamespaces *AllowedNamespaces `json:"allowedNamespaces,omitempty"`
+
+	// AllowCrossNamespaceContexts allows keys to override their derivation context with a
+	// context of another namespace, deriving the same value in several namespaces
+	// +optional
+	AllowCrossNamespaceContexts bool `json:"allowCrossNamespaceContexts,omitempty"`
+
+	// Migration moves dependent namespaces to a new primary master password in batches.
+	// If not specified, every namespace moves as soon as the primary changes
+	// +optional
+	Migration *MigrationSpec `json:"migration,omitempty"`
+
+	// HistoryLimit is the number of most recent generations of a generated master password kept
+	// in the <secret>-history secret for rollbacks.
+	// If not specified, defaults to 5
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	HistoryLimit int `json:"historyLimit,omitempty"`
+
+	// SealedValue is the master password sealed to the operator's public key with `dsctl seal`
+	// for the name of this MasterPassword, so it does not unseal in any other.
+	// The operator unseals it into the secret, so the master password can be kept in Git.
+	// Sealed master passwords are not regenerated by the operator
+	// +optional
+	SealedValue string `json:"sealedValue,omitempty"`
+
+	// Storage selects where the master password is kept. Memory keeps it out of etcd: the
+	// operator starts sealed and DerivedSecrets stay pending until it is unsealed.
+	// If not specified, defaults to Secret
+	// +optional
+	Storage MasterPasswordStorage `json:"storage,omitempty"`
+
+	// Shamir reconstructs the master password from Shamir shares held in separate Secrets.
+	// The shares are combined in memory only, so no single object holds the master password
+	// +optional
+	Shamir *ShamirSpec `json:"shamir,omitempty"`
+
+	// Format selects the format of the master password. Mnemonic master passwords are generated
+	// as 24 BIP39 words, ignoring length, and imported, sealed, unsealed or combined ones must be a
+	// mnemonic with a valid checksum before they are used. Changing the format applies to the
+	// next generation of the master password.
+	// If not specified, defaults to Base62
+	// +optional
+	Format MasterPasswordFormat `json:"format,omitempty"`
+
+	// Source reads the master password from a Secret key, a file or an environment variable of
+	// the operator whenever it is used. The operator never stores, regenerates or migrates it
+	// +optional
+	Source *MasterPasswordSource `json:"source,omitempty"`
+
+	// Validation rejects weak master passwords. A master password failing it is reported by the
+	// WeakMasterPassword condition and derives no secrets.
+	// If not specified, master passwords are not validated
+	// +optional
+	Validation *MasterPasswordValidation `json:"validation,omitempty"`
+
+	// Encryption selects how the master password is encrypted in its Secret. KMS stores it, its
+	// previous generation and its history as envelopes decrypted through the operator's KMS
+	// plugin, encrypting plain text values in place. Changing it back to None decrypts them.
+	// If not specified, defaults to None
+	// +optional
+	Encryption MasterPasswordEncryption `json:"encryption,omitempty"`
+
+	// Deriver derives secrets with a key held by an external backend instead of a master
+	// password, so the key never reaches the operator. The operator never stores, regenerates or
+	// migrates it.
+	// If not specified, secrets are derived from the master password with Argon2id
+	// +optional
+	Deriver *DeriverSpec `json:"deriver,omitempty"`
+}
+
+// DeriverSpec selects the backend secrets are derived with. Exactly one of Vault and PKCS11
+// must be set
+// +kubebuilder:validation:XValidation:rule="(has(self.vault) ? 1 : 0) + (has(self.pkcs11) ? 1 : 0) == 1",message="exactly one of vault and pkcs11 must be set"
+type DeriverSpec struct {
+	// Vault derives secrets with HMAC-SHA256 of a HashiCorp Vault Transit key
+	// +optional
+	Vault *VaultTransitDeriver `json:"vault,omitempty"`
+
+	// PKCS11 derives secrets with HMAC-SHA256 of a key held by a PKCS#11 token, such as an HSM.
+	// The operator must be built with the pkcs11 build tag
+	// +optional
+	PKCS11 *PKCS11Deriver `json:"pkcs11,omitempty"`
+}
+
+// VaultTransitDeriver references a key of a HashiCorp Vault Transit secrets engine
+type VaultTransitDeriver struct {
+	// Address is the URL of the Vault server
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=`^https?://`
+	Address string `json:"address"`
+
+	// Mount is the path the Transit secrets engine is mounted at.
+	// If not specified, defaults to transit
+	// +optional
+	// +kubebuilder:default=transit
+	Mount string `json:"mount,omitempty"`
+
+	// Key is the name of the Transit key. It must support HMAC
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Key string `json:"key"`
+
+	// KeyVersion pins the version of the Transit key. Rotating an unpinned key changes every
+	// derived secret.
+	// If not specified, the latest version is used
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	KeyVersion int `json:"keyVersion,omitempty"`
+
+	// Namespace is the Vault Enterprise namespace of the mount
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// TokenSecretRef references the Vault token the operator authenticates with. It needs the
+	// update capability on <mount>/hmac/<key>
+	// +kubebuilder:validation:Required
+	TokenSecretRef SecretKeyReference `json:"tokenSecretRef"`
+
+	// CABundle is the PEM encoded CA bundle verifying the Vault server certificate.
+	// If not specified, the system roots are used
+	// +optional
+	CABundle string `json:"caBundle,omitempty"`
+}
+
+// PKCS11Deriver references a secret key of a PKCS#11 token
+type PKCS11Deriver struct {
+	// Module is the path of the PKCS#11 library in the operator pod
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=`^/`
+	Module string `json:"module"`
+
+	// TokenLabel is the label of the token holding the key
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	TokenLabel string `json:"tokenLabel"`
+
+	// KeyLabel is the label of the secret key. It must allow signing with CKM_SHA256_HMAC
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	KeyLabel string `json:"keyLabel"`
+
+	// PINSecretRef references the user PIN of the token
+	// +kubebuilder:validation:Required
+	PINSecretRef SecretKeyReference `json:"pinSecretRef"`
+}
+
+// MasterPasswordSource selects where an externally managed master password is read from.
+// Exactly one of SecretKeyRef, File and Env must be set
+// +kubebuilder:validation:XValidation:rule="(has(self.secretKeyRef) ? 1 : 0) + (has(self.file) ? 1 : 0) + (has(self.env) ? 1 : 0) == 1",message="exactly one of secretKeyRef, file and env must be set"
+type MasterPasswordSource struct {
+	// SecretKeyRef reads the master password from a key of an existing Secret, which the
+	// operator never creates or changes
+	// +optional
+	SecretKeyRef *SecretKeyReference `json:"secretKeyRef,omitempty"`
+
+	// File reads the master password from a file mounted into the operator pod. It must be in the
+	// directory set by the operator's --master-password-file-dir flag
+	// +optional
+	// +kubebuilder:validation:Pattern=`^/`
+	File string `json:"file,omitempty"`
+
+	// Env reads the master password from an environment variable of the operator pod. It must
+	// start with the prefix set by the operator's --master-password-env-prefix flag
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[A-Za-z_][A-Za-z0-9_]*$`
+	Env string `json:"env,omitempty"`
+}
+
+// SecretKeyReference points at a key of a Secret
+type SecretKeyReference struct {
+	// Name is the name of the secret
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// Namespace is the namespace of the secret
+	// +kubebuilder:validation:Required
+	Namespace string `json:"namespace"`
+
+	// Key is the secret data key holding the value
+	// +kubebuilder:validation:Required
+	Key string `json:"key"`
+}
+
+// MasterPasswordValidation is the policy a master password must meet before it is used
+type MasterPasswordValidation struct {
+	// MinLength is the minimum number of characters of the master password
+	// +optional
+	// +kubebuilder:default=16
+	// +kubebuilder:validation:Minimum=1
+	MinLength int `json:"minLength,omitempty"`
+
+	// MinEntropyBits is the minimum entropy of the master password, estimated from its length
+	// and the frequencies of its characters
+	// +optional
+	// +kubebuilder:default=64
+	// +kubebuilder:validation:Minimum=0
+	MinEntropyBits int `json:"minEntropyBits,omitempty"`
+
+	// BannedPasswords are rejected, ignoring case, in addition to a built-in list of common
+	// passwords
+	// +optional
+	// +listType=set
+	BannedPasswords []string `json:"bannedPasswords,omitempty"`
+}
+
+// ShamirSpec references the Secrets holding the Shamir shares of a master password
+// +kubebuilder:validation:XValidation:rule="size(self.shares) >= self.threshold",message="threshold cannot exceed the number of shares"
+type ShamirSpec struct {
+	// Threshold is the number of shares needed to reconstruct the master password
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Minimum=2
+	// +kubebuilder:validation:Maximum=255
+	Threshold int `json:"threshold"`
+
+	// Shares lists the Secrets holding the shares, for example one per key holder team
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinItems=2
+	// +kubebuilder:validation:MaxItems=255
+	// +listType=atomic
+	Shares []ShareReference `json:"shares"`
+}
+
+// ShareReference points at a Secret holding one Shamir share of a master password
+type ShareReference struct {
+	// Name is the name of the secret
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// Namespace is the namespace of the secret
+	// +kubebuilder:validation:Required
+	Namespace string `json:"namespace"`
+
+	// Key is the secret data key holding the share.
+	// If not specified, defaults to share
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// DataKey returns the secret data key holding the share
+func (r *ShareReference) DataKey() string {
+	if r.Key == "" {
+		return DefaultShareKey
+	}
+	return r.Key
+}
+
+// InMemory reports whether the master password is kept in the operator's memory only
+func (s *MasterPasswordSpec) InMemory() bool {
+	return s.Storage == MasterPasswordStorageMemory
+}
+
+// InSecret reports whether the master password is kept in a Secret managed through spec.secret,
+// rather than in memory, as Shamir shares, in an external source or in a derivation backend
+func (s *MasterPasswordSpec) InSecret() bool {
+	return !s.InMemory() && s.Shamir == nil && s.Source == nil && s.Deriver == nil
+}
+
+// IsMnemonic reports whether the master password is a BIP39 mnemonic
+func (s *MasterPasswordSpec) IsMnemonic() bool {
+	return s.Format == MasterPasswordFormatMnemonic
+}
+
+// Encrypted reports whether the master password is stored as a KMS envelope
+func (s *MasterPasswordSpec) Encrypted() bool {
+	return s.Encryption == MasterPasswordEncryptionKMS
+}
+
+// HistoryLimitOrDefault returns the number of master password generations to keep in the history
+func (s *MasterPasswordSpec) HistoryLimitOrDefault() int {
+	if s.HistoryLimit == 0 {
+		return DefaultHistoryLimit
+	}
+	return s.HistoryLimit
+}
+
+// MigrationSpec controls how dependent namespaces move to a new primary master password.
+// Namespaces not migrated yet keep deriving from the previous generation
+type MigrationSpec struct {
+	// BatchSize is the number of namespaces migrated at a time
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Minimum=1
+	BatchSize int `json:"batchSize"`
+
+	// Interval between batches
+	// +kubebuilder:validation:Required
+	Interval metav1.Duration `json:"interval"`
+}
+
+// MigrationStatus reports the progress of a migration to a new primary master password
+type MigrationStatus struct {
+	// FromGeneration is the generation namespaces are migrated from
+	FromGeneration int `json:"fromGeneration"`
+
+	// ToGeneration is the generation namespaces are migrated to
+	ToGeneration int `json:"toGeneration"`
+
+	// MigratedNamespaces lists the namespaces deriving from ToGeneration
+	// +listType=set
+	// +optional
+	MigratedNamespaces []string `json:"migratedNamespaces,omitempty"`
+
+	// TotalNamespaces is the number of namespaces with secrets derived from the MasterPassword
+	TotalNamespaces int `json:"totalNamespaces"`
+
+	// LastBatchTime is when the last batch of namespaces was migrated
+	// +optional
+	LastBatchTime *metav1.Time `json:"lastBatchTime,omitempty"`
+
+	// CompletedAt is when every namespace was migrated
+	// +optional
+	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
+}
+
+// MasterPasswordStatus defines the observed state of MasterPassword.
+type MasterPasswordStatus struct {
+	// SecretName is the name of the secret containing the master password
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+
+	// SecretNamespace is the namespace of the secret containing the master password
+	// +optional
+	SecretNamespace string `json:"secretNamespace,omitempty"`
+
+	// DependentSecrets is the count of DerivedSecret resources using this MasterPassword
+	// +optional
+	DependentSecrets int `json:"dependentSecrets,omitempty"`
+
+	// PasswordFingerprint is an HMAC fingerprint of the master password, keyed from the master
+	// password itself, or from the derivation backend key. It tracks changes without revealing anything about the password
+	// +optional
+	PasswordFingerprint string `json:"passwordFingerprint,omitempty"`
+
+	// PrimaryGeneration is the generation of the primary master password
+	// +optional
+	PrimaryGeneration int `json:"primaryGeneration,omitempty"`
+
+	// PreviousGeneration is the generation of the previous master password, if the secret holds one
+	// +optional
+	PreviousGeneration int `json:"previousGeneration,omitempty"`
+
+	// UnsealVerifier verifies the master password unsealed into memory. It is recorded by the
+	// first unseal, and later unseals must match it
+	// +optional
+	UnsealVerifier string `json:"unsealVerifier,omitempty"`
+
+	// SharesPresent is the number of Shamir shares present in their Secrets
+	// +optional
+	SharesPresent int `json:"sharesPresent,omitempty"`
+
+	// HistoryGenerations lists the generations of the master password kept in the history secret
+	// +optional
+	HistoryGenerations []int `json:"historyGenerations,omitempty"`
+
+	// Migration reports the progress of the last migration to a new primary master password
+	// +optional
+	Migration *MigrationStatus `json:"migration,omitempty"`
+
+	// Conditions represent the current state of the MasterPassword resource.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:storageversion
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Dependent Secrets",type=integer,JSONPath=`.status.dependentSecrets`
+// +kubebuilder:printcolumn:name="Generation",type=integer,JSONPath=`.status.primaryGeneration`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// MasterPassword is the Schema for the masterpasswords API
+type MasterPassword struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty,omitzero"`
+
+	// spec defines the desired state of MasterPassword
+	// +required
+	Spec MasterPasswordSpec `json:"spec"`
+
+	// status defines the observed state of MasterPassword
+	// +optional
+	Status MasterPasswordStatus `json:"status,omitempty,omitzero"`
+}
+
+// +kubebuilder:object:root=true
+
+// MasterPasswordList contains a list of MasterPassword
+type MasterPasswordList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MasterPassword `json:"items"`
+}
+
+// GenerationFor returns the master password generation secrets in the given namespace are
+// derived from: the previous generation until a migration reaches the namespace, else the
+// primary one. It returns 0 before the primary generation is known
+func (mp *MasterPassword) GenerationFor(namespace string) int {
+	migration := mp.Status.Migration
+	if migration != nil && migration.CompletedAt == nil && !slices.Contains(migration.MigratedNamespaces, namespace) {
+		return migration.FromGeneration
+	}
+	return mp.Status.PrimaryGeneration
+}
+
+func init() {
+	SchemeBuilder.Register(&MasterPassword{}, &MasterPasswordList{})
+}
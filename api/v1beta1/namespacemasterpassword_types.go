@@ -0,0 +1,103 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NamespaceMasterPasswordSpec defines the desired state of NamespaceMasterPassword
+// +kubebuilder:validation:XValidation:rule="!has(self.secret) || !has(self.secret.__namespace__)",message="secret.namespace is not allowed, the secret lives in the NamespaceMasterPassword's namespace"
+type NamespaceMasterPasswordSpec struct {
+	// Length is the length of the generated master password
+	// +optional
+	// +kubebuilder:default=86
+	// +kubebuilder:validation:Minimum=22
+	// +kubebuilder:validation:Maximum=256
+	Length int `json:"length,omitempty"`
+
+	// Secret defines the secret where the master password is stored.
+	// The secret always lives in the namespace of the NamespaceMasterPassword,
+	// so secret.namespace must not be set.
+	// If not specified, defaults to the masterPassword key of <name>-mp
+	// +optional
+	Secret *SecretReference `json:"secret,omitempty"`
+
+	// Annotations to apply to the generated secret
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// NamespaceMasterPasswordStatus defines the observed state of NamespaceMasterPassword.
+type NamespaceMasterPasswordStatus struct {
+	// SecretName is the name of the secret containing the master password
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+
+	// DependentSecrets is the count of DerivedSecret resources using this NamespaceMasterPassword
+	// +optional
+	DependentSecrets int `json:"dependentSecrets,omitempty"`
+
+	// PasswordFingerprint is an HMAC fingerprint of the master password, keyed from the master
+	// password itself. It tracks changes without revealing anything about the password
+	// +optional
+	PasswordFingerprint string `json:"passwordFingerprint,omitempty"`
+
+	// Conditions represent the current state of the NamespaceMasterPassword resource.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=nsmp
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Dependent Secrets",type=integer,JSONPath=`.status.dependentSecrets`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// NamespaceMasterPassword is the Schema for the namespacemasterpasswords API.
+// It is a tenant-owned master password that can only be used by DerivedSecrets in its namespace.
+type NamespaceMasterPassword struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty,omitzero"`
+
+	// spec defines the desired state of NamespaceMasterPassword
+	// +required
+	Spec NamespaceMasterPasswordSpec `json:"spec"`
+
+	// status defines the observed state of NamespaceMasterPassword
+	// +optional
+	Status NamespaceMasterPasswordStatus `json:"status,omitempty,omitzero"`
+}
+
+// +kubebuilder:object:root=true
+
+// NamespaceMasterPasswordList contains a list of NamespaceMasterPassword
+type NamespaceMasterPasswordList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NamespaceMasterPassword `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NamespaceMasterPassword{}, &NamespaceMasterPasswordList{})
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RequestedByAnnotation records the user who created a RotationRequest. It is set in admission
+// and cannot be changed
+const RequestedByAnnotation = "secrets.oleksiyp.dev/requested-by"
+
+// RotationRequestAnnotation records on a master password secret the UID of the RotationRequest
+// that regenerated it, so a retried request does not regenerate it twice
+const RotationRequestAnnotation = "secrets.oleksiyp.dev/rotation-request"
+
+// RotationTargetKind is the kind of object a RotationRequest rotates
+// +kubebuilder:validation:Enum=DerivedSecret;MasterPassword
+type RotationTargetKind string
+
+const (
+	// RotationTargetDerivedSecret rotates keys of a DerivedSecret in the request's namespace
+	RotationTargetDerivedSecret RotationTargetKind = "DerivedSecret"
+	// RotationTargetMasterPassword regenerates a MasterPassword, rotating every secret derived from it
+	RotationTargetMasterPassword RotationTargetKind = "MasterPassword"
+)
+
+// RotationRequestSpec defines the desired state of RotationRequest
+// +kubebuilder:validation:XValidation:rule="self.kind == 'DerivedSecret' || !has(self.keys)",message="keys may only be set for DerivedSecrets"
+type RotationRequestSpec struct {
+	// Kind of the object to rotate
+	// +kubebuilder:validation:Required
+	Kind RotationTargetKind `json:"kind"`
+
+	// Name of the object to rotate. DerivedSecrets are looked up in the request's namespace
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Keys of the DerivedSecret to rotate by bumping their version.
+	// If not specified, all keys without a rotation schedule are rotated
+	// +optional
+	// +listType=set
+	Keys []string `json:"keys,omitempty"`
+
+	// Reason explains why the rotation is requested, for the audit record
+	// +optional
+	Reason string `json:"reason,omitempty"`
+}
+
+// RotationRequestStatus defines the observed state of RotationRequest.
+// The Approved condition is set by an approver, who must differ from the requester
+type RotationRequestStatus struct {
+	// RequestedBy is the user who created the request
+	// +optional
+	RequestedBy string `json:"requestedBy,omitempty"`
+
+	// ApprovedBy is the user who approved the request, set in admission
+	// +optional
+	ApprovedBy string `json:"approvedBy,omitempty"`
+
+	// ApprovedAt is when the request was approved, set in admission
+	// +optional
+	ApprovedAt *metav1.Time `json:"approvedAt,omitempty"`
+
+	// KeyVersions maps every rotated DerivedSecret key to the version it was rotated to
+	// +optional
+	KeyVersions map[string]int `json:"keyVersions,omitempty"`
+
+	// CompletedAt is when the rotation was applied
+	// +optional
+	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
+
+	// Conditions represent the current state of the RotationRequest resource.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=rr
+// +kubebuilder:printcolumn:name="Kind",type=string,JSONPath=`.spec.kind`
+// +kubebuilder:printcolumn:name="Target",type=string,JSONPath=`.spec.name`
+// +kubebuilder:printcolumn:name="Requested By",type=string,JSONPath=`.status.requestedBy`
+// +kubebuilder:printcolumn:name="Approved By",type=string,JSONPath=`.status.approvedBy`
+// +kubebuilder:printcolumn:name="Complete",type=string,JSONPath=`.status.conditions[?(@.type=="Complete")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// RotationRequest is the Schema for the rotationrequests API.
+// It rotates a DerivedSecret or MasterPassword once approved by a second user.
+type RotationRequest struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty,omitzero"`
+
+	// spec defines the rotation to apply and cannot be changed
+	// +required
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="spec is immutable"
+	Spec RotationRequestSpec `json:"spec"`
+
+	// status defines the observed state of RotationRequest
+	// +optional
+	Status RotationRequestStatus `json:"status,omitempty,omitzero"`
+}
+
+// +kubebuilder:object:root=true
+
+// RotationRequestList contains a list of RotationRequest
+type RotationRequestList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RotationRequest `json:"items"`
+}
+
+// Approved reports whether the request carries a true Approved condition
+func (rr *RotationRequest) Approved() bool {
+	return meta.IsStatusConditionTrue(rr.Status.Conditions, "Approved")
+}
+
+func init() {
+	SchemeBuilder.Register(&RotationRequest{}, &RotationRequestList{})
+}
@@ -0,0 +1,391 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	secretsv1beta1 "github.com/oleksiyp/derived-secret-operator/api/v1beta1"
+)
+
+const (
+	// bundleAPIVersion identifies the format of a backup bundle
+	bundleAPIVersion = "dsctl.secrets.oleksiyp.dev/v1"
+
+	// passphraseEnv holds the passphrase of a bundle when no passphrase file is given
+	passphraseEnv = "DSCTL_PASSPHRASE"
+
+	// historySecretSuffix is appended to the name of a master password secret to get the name of
+	// the secret holding its history
+	historySecretSuffix = "-history"
+)
+
+// bundle is the decrypted content of a backup
+type bundle struct {
+	APIVersion      string                          `json:"apiVersion"`
+	CreatedAt       metav1.Time                     `json:"createdAt"`
+	MasterPasswords []secretsv1beta1.MasterPassword `json:"masterPasswords"`
+	Secrets         []corev1.Secret                 `json:"secrets"`
+}
+
+// backup writes all MasterPasswords and the secrets holding their master passwords as a bundle
+// encrypted to age recipients or a passphrase
+func backup(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	var recipients stringList
+	flags.Var(&recipients, "recipient", "An age recipient to encrypt the bundle to; may be repeated")
+	recipientsFile := flags.String("recipients-file", "", "A file of age recipients, one per line")
+	passphraseFile := flags.String("passphrase-file", "", "A file holding the passphrase to encrypt the bundle "+
+		"with, instead of the "+passphraseEnv+" environment variable")
+	output := flags.String("output", "", "The file to write the bundle to instead of stdout")
+	kubeconfig := flags.String("kubeconfig", "", "The kubeconfig of the cluster to back up")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	encryptTo, err := bundleRecipients(recipients, *recipientsFile, *passphraseFile)
+	if err != nil {
+		return err
+	}
+	c, err := newClient(*kubeconfig)
+	if err != nil {
+		return err
+	}
+	b, err := collectBundle(context.Background(), c, os.Stderr)
+	if err != nil {
+		return err
+	}
+
+	if *output != "" {
+		file, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return fmt.Errorf("failed to create bundle: %w", err)
+		}
+		defer file.Close() //nolint:errcheck
+		out = file
+	}
+	if err := writeBundle(b, out, encryptTo...); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Backed up %d MasterPasswords and %d secrets\n", len(b.MasterPasswords), len(b.Secrets))
+	return nil
+}
+
+// restore creates the MasterPasswords and secrets of a bundle. Existing objects are skipped
+// unless --overwrite is set
+func restore(args []string, in io.Reader, out io.Writer) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	identityFile := flags.String("identity-file", "", "A file of age identities to decrypt the bundle with")
+	passphraseFile := flags.String("passphrase-file", "", "A file holding the passphrase of the bundle, "+
+		"instead of the "+passphraseEnv+" environment variable")
+	input := flags.String("input", "", "The file to read the bundle from instead of stdin")
+	overwrite := flags.Bool("overwrite", false, "Replace MasterPasswords and secrets that already exist")
+	kubeconfig := flags.String("kubeconfig", "", "The kubeconfig of the cluster to restore into")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	identities, err := bundleIdentities(*identityFile, *passphraseFile)
+	if err != nil {
+		return err
+	}
+	if *input != "" {
+		file, err := os.Open(*input)
+		if err != nil {
+			return fmt.Errorf("failed to open bundle: %w", err)
+		}
+		defer file.Close() //nolint:errcheck
+		in = file
+	}
+	b, err := readBundle(in, identities...)
+	if err != nil {
+		return err
+	}
+
+	c, err := newClient(*kubeconfig)
+	if err != nil {
+		return err
+	}
+	return restoreBundle(context.Background(), c, b, *overwrite, out)
+}
+
+// bundleRecipients returns the age recipients a bundle is encrypted to: the given recipients,
+// or a passphrase if there are none
+func bundleRecipients(recipients []string, recipientsFile, passphraseFile string) ([]age.Recipient, error) {
+	var result []age.Recipient
+	for _, value := range recipients {
+		recipient, err := age.ParseX25519Recipient(value)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, recipient)
+	}
+	if recipientsFile != "" {
+		data, err := os.ReadFile(recipientsFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read recipients: %w", err)
+		}
+		parsed, err := age.ParseRecipients(strings.NewReader(string(data)))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, parsed...)
+	}
+	if len(result) > 0 {
+		if passphraseFile != "" {
+			return nil, fmt.Errorf("a bundle is encrypted to recipients or a passphrase, not both")
+		}
+		return result, nil
+	}
+
+	passphrase, err := readPassphrase(passphraseFile)
+	if err != nil {
+		return nil, err
+	}
+	recipient, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return []age.Recipient{recipient}, nil
+}
+
+// bundleIdentities returns the age identities a bundle is decrypted with: those of the identity
+// file, or a passphrase if it is not set
+func bundleIdentities(identityFile, passphraseFile string) ([]age.Identity, error) {
+	if identityFile != "" {
+		file, err := os.Open(identityFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read identities: %w", err)
+		}
+		defer file.Close() //nolint:errcheck
+		return age.ParseIdentities(file)
+	}
+
+	passphrase, err := readPassphrase(passphraseFile)
+	if err != nil {
+		return nil, err
+	}
+	identity, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return []age.Identity{identity}, nil
+}
+
+// readPassphrase reads the passphrase from the file, or from the environment if it is not set
+func readPassphrase(file string) (string, error) {
+	if file == "" {
+		passphrase := os.Getenv(passphraseEnv)
+		if passphrase == "" {
+			return "", fmt.Errorf("age recipients or identities, --passphrase-file or %s is required", passphraseEnv)
+		}
+		return passphrase, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	passphrase := strings.TrimRight(string(data), "\r\n")
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase file %s is empty", file)
+	}
+	return passphrase, nil
+}
+
+// collectBundle gathers all MasterPasswords and the secrets holding their master passwords and
+// history. Master passwords kept in memory, split into shares or read from an external source
+// are backed up as their spec only, which is reported to warn
+func collectBundle(ctx context.Context, c client.Reader, warn io.Writer) (*bundle, error) {
+	mpList := &secretsv1beta1.MasterPasswordList{}
+	if err := c.List(ctx, mpList); err != nil {
+		return nil, fmt.Errorf("failed to list MasterPasswords: %w", err)
+	}
+
+	b := &bundle{APIVersion: bundleAPIVersion, CreatedAt: metav1.Now()}
+	for _, mp := range mpList.Items {
+		b.MasterPasswords = append(b.MasterPasswords, secretsv1beta1.MasterPassword{
+			ObjectMeta: metav1.ObjectMeta{Name: mp.Name, Labels: mp.Labels, Annotations: mp.Annotations},
+			Spec:       mp.Spec,
+		})
+
+		switch {
+		case mp.Spec.InMemory():
+			fmt.Fprintf(warn, "MasterPassword %s is kept in memory; only its spec is backed up\n", mp.Name)
+			continue
+		case mp.Spec.Shamir != nil:
+			fmt.Fprintf(warn, "MasterPassword %s is split into shares held by their key holders; "+
+				"only its spec is backed up\n", mp.Name)
+			continue
+		case mp.Spec.Source != nil:
+			fmt.Fprintf(warn, "MasterPassword %s is read from an external source; only its spec is backed up\n", mp.Name)
+			continue
+		case mp.Spec.Deriver != nil:
+			fmt.Fprintf(warn, "MasterPassword %s derives with a key held by its backend; "+
+				"only its spec is backed up\n", mp.Name)
+			continue
+		case mp.Status.SecretName == "":
+			fmt.Fprintf(warn, "MasterPassword %s has no secret yet; only its spec is backed up\n", mp.Name)
+			continue
+		}
+
+		name, namespace := mp.Status.SecretName, mp.Status.SecretNamespace
+		for i, secretName := range []string{name, name + historySecretSuffix} {
+			secret := &corev1.Secret{}
+			if err := c.Get(ctx, types.NamespacedName{Name: secretName, Namespace: namespace}, secret); err != nil {
+				// Only master passwords with a history have a history secret
+				if apierrors.IsNotFound(err) && i > 0 {
+					continue
+				}
+				return nil, fmt.Errorf("failed to get secret %s/%s of MasterPassword %s: %w",
+					namespace, secretName, mp.Name, err)
+			}
+			b.Secrets = append(b.Secrets, corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        secret.Name,
+					Namespace:   secret.Namespace,
+					Labels:      secret.Labels,
+					Annotations: secret.Annotations,
+				},
+				Type: secret.Type,
+				Data: secret.Data,
+			})
+		}
+	}
+	return b, nil
+}
+
+// writeBundle writes the bundle encrypted to the recipients as ASCII armored age
+func writeBundle(b *bundle, out io.Writer, recipients ...age.Recipient) error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return fmt.Errorf("failed to encode bundle: %w", err)
+	}
+
+	armored := armor.NewWriter(out)
+	encrypted, err := age.Encrypt(armored, recipients...)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt bundle: %w", err)
+	}
+	if _, err := encrypted.Write(data); err != nil {
+		return fmt.Errorf("failed to encrypt bundle: %w", err)
+	}
+	if err := encrypted.Close(); err != nil {
+		return fmt.Errorf("failed to encrypt bundle: %w", err)
+	}
+	return armored.Close()
+}
+
+// readBundle decrypts a bundle written by writeBundle with one of the identities
+func readBundle(in io.Reader, identities ...age.Identity) (*bundle, error) {
+	decrypted, err := age.Decrypt(armor.NewReader(in), identities...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt bundle: %w", err)
+	}
+	data, err := io.ReadAll(decrypted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt bundle: %w", err)
+	}
+
+	b := &bundle{}
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, fmt.Errorf("failed to decode bundle: %w", err)
+	}
+	if b.APIVersion != bundleAPIVersion {
+		return nil, fmt.Errorf("unsupported bundle version %q", b.APIVersion)
+	}
+	return b, nil
+}
+
+// restoreBundle creates the secrets and MasterPasswords of the bundle, replacing existing ones
+// if overwrite is set. Secrets are restored first, so the operator finds the master passwords
+// instead of generating new ones
+func restoreBundle(ctx context.Context, c client.Client, b *bundle, overwrite bool, out io.Writer) error {
+	for i := range b.Secrets {
+		restored := &b.Secrets[i]
+		existing := &corev1.Secret{}
+		result, err := restoreObject(ctx, c, restored, existing, overwrite, func() {
+			existing.Labels = restored.Labels
+			existing.Annotations = restored.Annotations
+			existing.Data = restored.Data
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "secret %s/%s %s\n", restored.Namespace, restored.Name, result)
+	}
+
+	for i := range b.MasterPasswords {
+		restored := &b.MasterPasswords[i]
+		existing := &secretsv1beta1.MasterPassword{}
+		result, err := restoreObject(ctx, c, restored, existing, overwrite, func() {
+			existing.Labels = restored.Labels
+			existing.Annotations = restored.Annotations
+			existing.Spec = restored.Spec
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "masterpassword %s %s\n", restored.Name, result)
+	}
+	return nil
+}
+
+// restoreObject creates the restored object. If it already exists it is skipped, or, if
+// overwrite is set, fetched, replaced by apply and updated. It returns what was done
+func restoreObject(
+	ctx context.Context,
+	c client.Client,
+	restored, existing client.Object,
+	overwrite bool,
+	apply func(),
+) (string, error) {
+	key := client.ObjectKeyFromObject(restored)
+	// Create fills in the copy, so the bundle can be restored again
+	err := c.Create(ctx, restored.DeepCopyObject().(client.Object))
+	if err == nil {
+		return "created", nil
+	}
+	if !apierrors.IsAlreadyExists(err) {
+		return "", fmt.Errorf("failed to restore %s: %w", key, err)
+	}
+	if !overwrite {
+		return "skipped, it already exists", nil
+	}
+
+	if err := c.Get(ctx, key, existing); err != nil {
+		return "", fmt.Errorf("failed to get %s: %w", key, err)
+	}
+	apply()
+	if err := c.Update(ctx, existing); err != nil {
+		return "", fmt.Errorf("failed to restore %s: %w", key, err)
+	}
+	return "replaced", nil
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	secretsv1beta1 "github.com/oleksiyp/derived-secret-operator/api/v1beta1"
+)
+
+func newTestClient(t *testing.T, objects ...client.Object) client.Client {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatalf("AddToScheme() error = %v", err)
+	}
+	if err := secretsv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("AddToScheme() error = %v", err)
+	}
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+}
+
+func TestSplitCombine(t *testing.T) {
+	var shares bytes.Buffer
+	args := []string{"--shares", "5", "--threshold", "3"}
+	if err := split(args, strings.NewReader("root-password\n"), &shares); err != nil {
+		t.Fatalf("split() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(shares.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("split() printed %d shares, want 5", len(lines))
+	}
+
+	var password bytes.Buffer
+	in := strings.NewReader(lines[4] + "\n" + lines[0] + "\n\n" + lines[2] + "\n")
+	if err := combine(nil, in, &password); err != nil {
+		t.Fatalf("combine() error = %v", err)
+	}
+	if password.String() != "root-password\n" {
+		t.Errorf("combine() = %q, want the split master password", password.String())
+	}
+
+	if err := combine(nil, strings.NewReader(lines[1]+"\n"+lines[3]), io.Discard); err == nil {
+		t.Errorf("combine() below the threshold succeeded")
+	}
+}
+
+func TestBackupRestore(t *testing.T) {
+	ctx := context.Background()
+	source := newTestClient(t,
+		&secretsv1beta1.MasterPassword{
+			ObjectMeta: metav1.ObjectMeta{Name: "stored"},
+			Spec:       secretsv1beta1.MasterPasswordSpec{Length: 64},
+			Status:     secretsv1beta1.MasterPasswordStatus{SecretName: "stored-mp", SecretNamespace: "operator"},
+		},
+		&secretsv1beta1.MasterPassword{
+			ObjectMeta: metav1.ObjectMeta{Name: "memory"},
+			Spec:       secretsv1beta1.MasterPasswordSpec{Storage: secretsv1beta1.MasterPasswordStorageMemory},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "stored-mp", Namespace: "operator"},
+			Data:       map[string][]byte{secretsv1beta1.DefaultMasterPasswordKey: []byte("stored-password")},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "stored-mp-history", Namespace: "operator"},
+			Data:       map[string][]byte{"1": []byte("old-password")},
+		},
+	)
+
+	var warnings bytes.Buffer
+	b, err := collectBundle(ctx, source, &warnings)
+	if err != nil {
+		t.Fatalf("collectBundle() error = %v", err)
+	}
+	if len(b.MasterPasswords) != 2 || len(b.Secrets) != 2 {
+		t.Fatalf("collectBundle() = %d MasterPasswords and %d secrets, want 2 and 2",
+			len(b.MasterPasswords), len(b.Secrets))
+	}
+	if !strings.Contains(warnings.String(), "memory") {
+		t.Errorf("collectBundle() did not warn about the Memory master password: %q", warnings.String())
+	}
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("GenerateX25519Identity() error = %v", err)
+	}
+	var encrypted bytes.Buffer
+	if err := writeBundle(b, &encrypted, identity.Recipient()); err != nil {
+		t.Fatalf("writeBundle() error = %v", err)
+	}
+	if strings.Contains(encrypted.String(), "stored-password") {
+		t.Fatalf("writeBundle() wrote the master password in plain text")
+	}
+
+	other, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("GenerateX25519Identity() error = %v", err)
+	}
+	if _, err := readBundle(bytes.NewReader(encrypted.Bytes()), other); err == nil {
+		t.Errorf("readBundle() with another identity succeeded")
+	}
+	restored, err := readBundle(bytes.NewReader(encrypted.Bytes()), identity)
+	if err != nil {
+		t.Fatalf("readBundle() error = %v", err)
+	}
+
+	target := newTestClient(t, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "stored-mp", Namespace: "operator"},
+		Data:       map[string][]byte{secretsv1beta1.DefaultMasterPasswordKey: []byte("generated-password")},
+	})
+	if err := restoreBundle(ctx, target, restored, false, io.Discard); err != nil {
+		t.Fatalf("restoreBundle() error = %v", err)
+	}
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Name: "stored-mp", Namespace: "operator"}
+	if err := target.Get(ctx, key, secret); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got := string(secret.Data[secretsv1beta1.DefaultMasterPasswordKey]); got != "generated-password" {
+		t.Errorf("restoreBundle() without overwrite replaced the secret with %q", got)
+	}
+
+	if err := restoreBundle(ctx, target, restored, true, io.Discard); err != nil {
+		t.Fatalf("restoreBundle() error = %v", err)
+	}
+	if err := target.Get(ctx, key, secret); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got := string(secret.Data[secretsv1beta1.DefaultMasterPasswordKey]); got != "stored-password" {
+		t.Errorf("restored master password = %q, want %q", got, "stored-password")
+	}
+	mp := &secretsv1beta1.MasterPassword{}
+	if err := target.Get(ctx, types.NamespacedName{Name: "stored"}, mp); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if mp.Spec.Length != 64 {
+		t.Errorf("restored spec.length = %d, want 64", mp.Spec.Length)
+	}
+	historyKey := types.NamespacedName{Name: "stored-mp-history", Namespace: "operator"}
+	if err := target.Get(ctx, historyKey, &corev1.Secret{}); err != nil {
+		t.Errorf("history secret was not restored: %v", err)
+	}
+}
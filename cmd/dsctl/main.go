@@ -0,0 +1,177 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// dsctl is the command line companion of the derived-secret-operator
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	secretsv1beta1 "github.com/oleksiyp/derived-secret-operator/api/v1beta1"
+	"github.com/oleksiyp/derived-secret-operator/internal/crypto"
+)
+
+const usage = `Usage: dsctl <command> [flags]
+
+Commands:
+  seal     Seal a master password read from stdin to the operator's public key
+  split    Split a master password into Shamir shares
+  combine  Combine Shamir shares back into the master password
+  backup   Export all MasterPasswords and their secrets as an encrypted bundle
+  restore  Restore an encrypted backup bundle into a cluster
+  verify   Check Secret values against the fingerprints recorded by the operator
+
+Run dsctl <command> -h for the flags of a command.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "seal":
+		err = seal(os.Args[2:], os.Stdin, os.Stdout)
+	case "split":
+		err = split(os.Args[2:], os.Stdin, os.Stdout)
+	case "combine":
+		err = combine(os.Args[2:], os.Stdin, os.Stdout)
+	case "backup":
+		err = backup(os.Args[2:], os.Stdout)
+	case "restore":
+		err = restore(os.Args[2:], os.Stdin, os.Stdout)
+	case "verify":
+		err = verify(os.Args[2:], os.Stdin, os.Stdout)
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(os.Stdout, usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "dsctl %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+// seal prints the master password read from in sealed to the operator's public key,
+// ready to be used as the sealedValue of a MasterPassword
+func seal(args []string, in io.Reader, out io.Writer) error {
+	flags := flag.NewFlagSet("seal", flag.ExitOnError)
+	publicKey := flags.String("public-key", "", "The Base64 encoded public key of the operator")
+	publicKeyFile := flags.String("public-key-file", "", "A file holding the public key of the operator")
+	masterPassword := flags.String("masterpassword", "", "The MasterPassword the value is sealed for; "+
+		"it does not unseal in any other")
+	mnemonic := flags.Bool("mnemonic", false, "Check that the master password is a BIP39 mnemonic with a valid "+
+		"checksum, for MasterPasswords with the Mnemonic format")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	switch {
+	case *publicKey != "" && *publicKeyFile != "":
+		return fmt.Errorf("only one of --public-key and --public-key-file may be set")
+	case *publicKeyFile != "":
+		data, err := os.ReadFile(*publicKeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to read public key: %w", err)
+		}
+		*publicKey = strings.TrimSpace(string(data))
+	case *publicKey == "":
+		return fmt.Errorf("--public-key or --public-key-file is required")
+	}
+	if *masterPassword == "" {
+		return fmt.Errorf("--masterpassword is required")
+	}
+
+	password, err := readMasterPassword(in)
+	if err != nil {
+		return err
+	}
+	if *mnemonic {
+		normalized, err := crypto.NormalizeMnemonic(string(password))
+		if err != nil {
+			return err
+		}
+		password = []byte(normalized)
+	}
+
+	sealed, err := crypto.Seal(*publicKey, *masterPassword, password)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, sealed)
+	return err
+}
+
+// readMasterPassword reads a master password from in, dropping the newline echo and heredocs add
+func readMasterPassword(in io.Reader) ([]byte, error) {
+	password, err := io.ReadAll(in)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read master password: %w", err)
+	}
+	password = bytes.TrimRight(password, "\r\n")
+	if len(password) == 0 {
+		return nil, fmt.Errorf("the master password read from stdin is empty")
+	}
+	return password, nil
+}
+
+// newClient returns a client for the cluster of the kubeconfig, or of the default kubeconfig
+// loading rules if it is empty
+func newClient(kubeconfig string) (client.Client, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfig
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{}).
+		ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	if err := secretsv1beta1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	return client.New(config, client.Options{Scheme: scheme})
+}
+
+// stringList is a flag that may be repeated
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
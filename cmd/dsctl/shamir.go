@@ -0,0 +1,131 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	secretsv1beta1 "github.com/oleksiyp/derived-secret-operator/api/v1beta1"
+	"github.com/oleksiyp/derived-secret-operator/internal/crypto"
+	"github.com/oleksiyp/derived-secret-operator/internal/kms"
+)
+
+// split prints the Shamir shares of a master password, one per line. The master password is
+// read from a Secret in the cluster, or from in
+func split(args []string, in io.Reader, out io.Writer) error {
+	flags := flag.NewFlagSet("split", flag.ExitOnError)
+	shares := flags.Int("shares", 5, "The number of shares to create")
+	threshold := flags.Int("threshold", 3, "The number of shares needed to combine the master password")
+	secretRef := flags.String("secret", "", "Read the master password from this <namespace>/<name> Secret "+
+		"instead of stdin")
+	key := flags.String("key", secretsv1beta1.DefaultMasterPasswordKey, "The key of the master password in the Secret")
+	kubeconfig := flags.String("kubeconfig", "", "The kubeconfig used with --secret")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var password []byte
+	if *secretRef != "" {
+		namespace, name, ok := strings.Cut(*secretRef, "/")
+		if !ok || namespace == "" || name == "" {
+			return fmt.Errorf("--secret must be <namespace>/<name>, got %q", *secretRef)
+		}
+		c, err := newClient(*kubeconfig)
+		if err != nil {
+			return err
+		}
+		secret := &corev1.Secret{}
+		if err := c.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: name}, secret); err != nil {
+			return fmt.Errorf("failed to get secret %s: %w", *secretRef, err)
+		}
+		password = bytes.TrimRight(secret.Data[*key], "\r\n")
+		if len(password) == 0 {
+			return fmt.Errorf("secret %s has no key %s", *secretRef, *key)
+		}
+		// Splitting an envelope would hand out shares that combine into nothing usable
+		if kms.IsEnvelope(password) {
+			return fmt.Errorf("secret %s holds a KMS encrypted master password; split it from stdin instead", *secretRef)
+		}
+	} else {
+		var err error
+		if password, err = readMasterPassword(in); err != nil {
+			return err
+		}
+	}
+
+	encoded, err := crypto.SplitSecret(password, *shares, *threshold)
+	if err != nil {
+		return err
+	}
+	for _, share := range encoded {
+		if _, err := fmt.Fprintln(out, share); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// combine prints the master password combined from Shamir shares, read one per file given as
+// arguments, or one per line from in
+func combine(args []string, in io.Reader, out io.Writer) error {
+	flags := flag.NewFlagSet("combine", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: dsctl combine [share-file...]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var shares []string
+	if flags.NArg() > 0 {
+		for _, file := range flags.Args() {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return fmt.Errorf("failed to read share: %w", err)
+			}
+			shares = append(shares, strings.TrimSpace(string(data)))
+		}
+	} else {
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				shares = append(shares, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("failed to read shares: %w", err)
+		}
+	}
+
+	password, err := crypto.CombineShares(shares)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, string(password))
+	return err
+}
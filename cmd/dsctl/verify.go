@@ -0,0 +1,239 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	secretsv1beta1 "github.com/oleksiyp/derived-secret-operator/api/v1beta1"
+	"github.com/oleksiyp/derived-secret-operator/internal/crypto"
+)
+
+// verify checks values against the fingerprints recorded in the status of a DerivedSecret or a
+// MasterPassword. The fingerprints are keyed from the master password read from in.
+func verify(args []string, in io.Reader, out io.Writer) error {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	derivedSecret := flags.String("derivedsecret", "", "Check the Secrets of this <namespace>/<name> DerivedSecret")
+	masterPassword := flags.String("masterpassword", "", "Check the master password against this MasterPassword")
+	key := flags.String("key", "", "Only check this key of the DerivedSecret")
+	valueFile := flags.String("value-file", "", "Check the value in this file instead of the Secrets of the "+
+		"DerivedSecret; requires --key")
+	mnemonic := flags.Bool("mnemonic", false, "Normalize the master password as a BIP39 mnemonic, "+
+		"for MasterPasswords with the Mnemonic format")
+	kubeconfig := flags.String("kubeconfig", "", "The kubeconfig of the cluster")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var namespace, name string
+	switch {
+	case (*derivedSecret == "") == (*masterPassword == ""):
+		return fmt.Errorf("exactly one of --derivedsecret and --masterpassword is required")
+	case *derivedSecret != "":
+		var ok bool
+		namespace, name, ok = strings.Cut(*derivedSecret, "/")
+		if !ok || namespace == "" || name == "" {
+			return fmt.Errorf("--derivedsecret must be <namespace>/<name>, got %q", *derivedSecret)
+		}
+	case *key != "" || *valueFile != "":
+		return fmt.Errorf("--key and --value-file only apply to --derivedsecret")
+	}
+	if *valueFile != "" && *key == "" {
+		return fmt.Errorf("--value-file requires --key")
+	}
+
+	var value []byte
+	if *valueFile != "" {
+		data, err := os.ReadFile(*valueFile)
+		if err != nil {
+			return fmt.Errorf("failed to read value: %w", err)
+		}
+		value = bytes.TrimRight(data, "\r\n")
+		if len(value) == 0 {
+			return fmt.Errorf("the value in %s is empty", *valueFile)
+		}
+	}
+
+	password, err := readMasterPassword(in)
+	if err != nil {
+		return err
+	}
+	if *mnemonic {
+		normalized, err := crypto.NormalizeMnemonic(string(password))
+		if err != nil {
+			return err
+		}
+		password = []byte(normalized)
+	}
+
+	ctx := context.Background()
+	fingerprintKey, err := crypto.FingerprintKey(ctx, crypto.NewArgon2idDeriver(string(password)))
+	if err != nil {
+		return err
+	}
+	c, err := newClient(*kubeconfig)
+	if err != nil {
+		return err
+	}
+	if *masterPassword != "" {
+		return verifyMasterPassword(ctx, c, *masterPassword, fingerprintKey, out)
+	}
+	return verifyDerivedSecret(ctx, c, types.NamespacedName{Namespace: namespace, Name: name}, *key, value,
+		fingerprintKey, out)
+}
+
+// verifyMasterPassword checks that the fingerprint key was derived from the master password of
+// the named MasterPassword.
+func verifyMasterPassword(
+	ctx context.Context,
+	c client.Reader,
+	name string,
+	fingerprintKey []byte,
+	out io.Writer,
+) error {
+	mp := &secretsv1beta1.MasterPassword{}
+	if err := c.Get(ctx, types.NamespacedName{Name: name}, mp); err != nil {
+		return fmt.Errorf("failed to get MasterPassword %s: %w", name, err)
+	}
+	if err := requireMasterPasswordDerivation(mp); err != nil {
+		return err
+	}
+	if mp.Status.PasswordFingerprint == "" {
+		return fmt.Errorf("MasterPassword %s has no recorded fingerprint", name)
+	}
+	if crypto.MasterFingerprint(fingerprintKey) != mp.Status.PasswordFingerprint {
+		return fmt.Errorf("the master password does not match MasterPassword %s", name)
+	}
+	_, err := fmt.Fprintf(out, "masterpassword/%s: matches\n", name)
+	return err
+}
+
+// verifyDerivedSecret checks the keys of a DerivedSecret against their recorded fingerprints.
+// Every key, or the named one, is checked in every Secret the DerivedSecret writes, unless a value
+// is given. It fails if any value does not match.
+func verifyDerivedSecret(
+	ctx context.Context,
+	c client.Reader,
+	key types.NamespacedName,
+	keyName string,
+	value []byte,
+	fingerprintKey []byte,
+	out io.Writer,
+) error {
+	ds := &secretsv1beta1.DerivedSecret{}
+	if err := c.Get(ctx, key, ds); err != nil {
+		return fmt.Errorf("failed to get DerivedSecret %s: %w", key, err)
+	}
+
+	keyNames := slices.Sorted(maps.Keys(ds.Status.KeyFingerprints))
+	if keyName != "" {
+		if _, ok := ds.Status.KeyFingerprints[keyName]; !ok {
+			return fmt.Errorf("DerivedSecret %s has no recorded fingerprint for key %s", key, keyName)
+		}
+		keyNames = []string{keyName}
+	}
+	if len(keyNames) == 0 {
+		return fmt.Errorf("DerivedSecret %s has no recorded fingerprints", key)
+	}
+
+	// Keys derived by a backend have fingerprints keyed by the backend, not by a master password
+	checkedMasterPasswords := map[string]bool{}
+	for _, keyName := range keyNames {
+		ref := ds.Spec.MasterPasswordRefFor(ds.Spec.Keys[keyName])
+		if ref.Kind != secretsv1beta1.KindMasterPassword || checkedMasterPasswords[ref.Name] {
+			continue
+		}
+		checkedMasterPasswords[ref.Name] = true
+		mp := &secretsv1beta1.MasterPassword{}
+		if err := c.Get(ctx, types.NamespacedName{Name: ref.Name}, mp); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return fmt.Errorf("failed to get MasterPassword %s: %w", ref.Name, err)
+		}
+		if err := requireMasterPasswordDerivation(mp); err != nil {
+			return fmt.Errorf("key %s of DerivedSecret %s cannot be verified: %w", keyName, key, err)
+		}
+	}
+
+	// check prints whether a value matches the fingerprint of a key and counts mismatches
+	checked, mismatched := 0, 0
+	check := func(source, keyName string, value []byte, ok bool) error {
+		checked++
+		result := "matches"
+		switch {
+		case !ok:
+			result = "is missing"
+			mismatched++
+		case !crypto.VerifyFingerprint(fingerprintKey, crypto.BuildContext(ds.Namespace, ds.Name, keyName),
+			string(value), ds.Status.KeyFingerprints[keyName]):
+			result = "does not match"
+			mismatched++
+		}
+		_, err := fmt.Fprintf(out, "%s %s: %s\n", source, keyName, result)
+		return err
+	}
+
+	if len(value) > 0 {
+		if err := check("value", keyName, value, true); err != nil {
+			return err
+		}
+	} else {
+		for _, target := range ds.TargetSecrets() {
+			secret := &corev1.Secret{}
+			secretKey := types.NamespacedName{Namespace: ds.Namespace, Name: target.Name}
+			if err := c.Get(ctx, secretKey, secret); err != nil && !apierrors.IsNotFound(err) {
+				return fmt.Errorf("failed to get secret %s: %w", secretKey, err)
+			}
+			for _, keyName := range keyNames {
+				data, ok := secret.Data[keyName]
+				if err := check("secret/"+target.Name, keyName, data, ok); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if mismatched > 0 {
+		return fmt.Errorf("%d of %d values do not match their fingerprints; check the master password "+
+			"read from stdin is the one the keys are derived from", mismatched, checked)
+	}
+	return nil
+}
+
+// requireMasterPasswordDerivation fails if the MasterPassword derives with a key held by a Vault
+// or PKCS#11 backend, as verify can only rebuild fingerprint keys from a master password.
+func requireMasterPasswordDerivation(mp *secretsv1beta1.MasterPassword) error {
+	if mp.Spec.Deriver != nil {
+		return fmt.Errorf("MasterPassword %s derives with a key held by its backend, "+
+			"so its fingerprints cannot be checked with a master password", mp.Name)
+	}
+	return nil
+}
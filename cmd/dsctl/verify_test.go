@@ -0,0 +1,144 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	secretsv1beta1 "github.com/oleksiyp/derived-secret-operator/api/v1beta1"
+	"github.com/oleksiyp/derived-secret-operator/internal/crypto"
+)
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	fingerprintKey, err := crypto.FingerprintKey(ctx, crypto.NewArgon2idDeriver("root-password"))
+	if err != nil {
+		t.Fatalf("FingerprintKey() error = %v", err)
+	}
+	otherKey, err := crypto.FingerprintKey(ctx, crypto.NewArgon2idDeriver("other-password"))
+	if err != nil {
+		t.Fatalf("FingerprintKey() error = %v", err)
+	}
+	fingerprint := func(key, value string) string {
+		return crypto.Fingerprint(fingerprintKey, crypto.BuildContext("team-a", "app", key), value)
+	}
+
+	c := newTestClient(t,
+		&secretsv1beta1.MasterPassword{
+			ObjectMeta: metav1.ObjectMeta{Name: "default"},
+			Status:     secretsv1beta1.MasterPasswordStatus{PasswordFingerprint: crypto.MasterFingerprint(fingerprintKey)},
+		},
+		&secretsv1beta1.DerivedSecret{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team-a"},
+			Spec: secretsv1beta1.DerivedSecretSpec{
+				Targets: []secretsv1beta1.DerivedSecretTarget{{}, {Name: "app-copy"}},
+				Keys: map[string]secretsv1beta1.DerivedKeySpec{
+					"password": {Type: secretsv1beta1.SecretTypePassword},
+					"token":    {Type: secretsv1beta1.SecretTypeEncryptionKey},
+				},
+			},
+			Status: secretsv1beta1.DerivedSecretStatus{
+				KeyFingerprints: map[string]string{
+					"password": fingerprint("password", "derived-password"),
+					"token":    fingerprint("token", "derived-token"),
+				},
+			},
+		},
+		&secretsv1beta1.MasterPassword{
+			ObjectMeta: metav1.ObjectMeta{Name: "vault"},
+			Spec: secretsv1beta1.MasterPasswordSpec{
+				Deriver: &secretsv1beta1.DeriverSpec{
+					Vault: &secretsv1beta1.VaultTransitDeriver{Address: "https://vault:8200", Key: "derived"},
+				},
+			},
+			Status: secretsv1beta1.MasterPasswordStatus{PasswordFingerprint: crypto.MasterFingerprint(fingerprintKey)},
+		},
+		&secretsv1beta1.DerivedSecret{
+			ObjectMeta: metav1.ObjectMeta{Name: "backend", Namespace: "team-a"},
+			Spec: secretsv1beta1.DerivedSecretSpec{
+				MasterPassword: "vault",
+				Keys:           map[string]secretsv1beta1.DerivedKeySpec{"password": {Type: secretsv1beta1.SecretTypePassword}},
+			},
+			Status: secretsv1beta1.DerivedSecretStatus{
+				KeyFingerprints: map[string]string{"password": fingerprint("password", "derived-password")},
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team-a"},
+			Data:       map[string][]byte{"password": []byte("derived-password"), "token": []byte("derived-token")},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-copy", Namespace: "team-a"},
+			Data:       map[string][]byte{"password": []byte("tampered-password"), "token": []byte("derived-token")},
+		},
+	)
+	app := types.NamespacedName{Namespace: "team-a", Name: "app"}
+
+	if err := verifyMasterPassword(ctx, c, "default", fingerprintKey, io.Discard); err != nil {
+		t.Errorf("verifyMasterPassword() error = %v", err)
+	}
+	if err := verifyMasterPassword(ctx, c, "default", otherKey, io.Discard); err == nil {
+		t.Errorf("verifyMasterPassword() with another master password succeeded")
+	}
+
+	var out bytes.Buffer
+	err = verifyDerivedSecret(ctx, c, app, "", nil, fingerprintKey, &out)
+	if err == nil || !strings.Contains(err.Error(), "1 of 4 values") {
+		t.Errorf("verifyDerivedSecret() error = %v, want 1 of 4 values not matching", err)
+	}
+	want := "secret/app password: matches\nsecret/app token: matches\n" +
+		"secret/app-copy password: does not match\nsecret/app-copy token: matches\n"
+	if out.String() != want {
+		t.Errorf("verifyDerivedSecret() printed\n%s\nwant\n%s", out.String(), want)
+	}
+
+	if err := verifyDerivedSecret(ctx, c, app, "token", nil, fingerprintKey, io.Discard); err != nil {
+		t.Errorf("verifyDerivedSecret() of a single key error = %v", err)
+	}
+	if err := verifyDerivedSecret(ctx, c, app, "password", []byte("derived-password"), fingerprintKey,
+		io.Discard); err != nil {
+		t.Errorf("verifyDerivedSecret() of a value error = %v", err)
+	}
+	if err := verifyDerivedSecret(ctx, c, app, "token", []byte("derived-password"), fingerprintKey,
+		io.Discard); err == nil {
+		t.Errorf("verifyDerivedSecret() matched the value of another key")
+	}
+	if err := verifyDerivedSecret(ctx, c, app, "token", nil, otherKey, io.Discard); err == nil {
+		t.Errorf("verifyDerivedSecret() with another master password succeeded")
+	}
+	if err := verifyDerivedSecret(ctx, c, app, "missing", nil, fingerprintKey, io.Discard); err == nil {
+		t.Errorf("verifyDerivedSecret() of an unknown key succeeded")
+	}
+
+	err = verifyMasterPassword(ctx, c, "vault", fingerprintKey, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "held by its backend") {
+		t.Errorf("verifyMasterPassword() of a backend MasterPassword error = %v, want held by its backend", err)
+	}
+	backend := types.NamespacedName{Namespace: "team-a", Name: "backend"}
+	err = verifyDerivedSecret(ctx, c, backend, "password", []byte("derived-password"), fingerprintKey, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "held by its backend") {
+		t.Errorf("verifyDerivedSecret() of a backend MasterPassword error = %v, want held by its backend", err)
+	}
+}
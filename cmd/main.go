@@ -20,15 +20,20 @@ import (
 	"crypto/tls"
 	"flag"
 	"os"
+	"path/filepath"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
@@ -36,7 +41,11 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	secretsv1alpha1 "github.com/oleksiyp/derived-secret-operator/api/v1alpha1"
+	secretsv1beta1 "github.com/oleksiyp/derived-secret-operator/api/v1beta1"
 	"github.com/oleksiyp/derived-secret-operator/internal/controller"
+	"github.com/oleksiyp/derived-secret-operator/internal/kms"
+	"github.com/oleksiyp/derived-secret-operator/internal/unseal"
+	webhooksecretsv1beta1 "github.com/oleksiyp/derived-secret-operator/internal/webhook/v1beta1"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -49,6 +58,7 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(secretsv1alpha1.AddToScheme(scheme))
+	utilruntime.Must(secretsv1beta1.AddToScheme(scheme))
 	// +kubebuilder:scaffold:scheme
 }
 
@@ -62,6 +72,10 @@ func main() {
 	var secureMetrics bool
 	var enableHTTP2 bool
 	var operatorNamespace string
+	var kmsEndpoint, kmsKeyFile string
+	var kmsTimeout, kmsCacheTTL time.Duration
+	var unsealPeers, unsealPeerServerName string
+	var sourcePolicy controller.SourcePolicy
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -82,6 +96,21 @@ func main() {
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
 	flag.StringVar(&operatorNamespace, "operator-namespace", os.Getenv("POD_NAMESPACE"),
 		"The namespace where the operator is running")
+	flag.StringVar(&kmsEndpoint, "kms-endpoint", "",
+		"The unix socket of a KMS v2 plugin encrypting master passwords with KMS encryption.")
+	flag.StringVar(&kmsKeyFile, "kms-key-file", "",
+		"A file holding a local KMS key, for testing without a KMS plugin. Ignored if --kms-endpoint is set.")
+	flag.DurationVar(&kmsTimeout, "kms-timeout", 3*time.Second, "The timeout of calls to the KMS plugin.")
+	flag.DurationVar(&kmsCacheTTL, "kms-cache-ttl", 30*time.Second,
+		"How long decrypted master passwords are cached in memory, saving calls to the KMS plugin.")
+	flag.StringVar(&sourcePolicy.FileDir, "master-password-file-dir", "",
+		"The directory MasterPassword sources may read files from. Files are not allowed if empty.")
+	flag.StringVar(&sourcePolicy.EnvPrefix, "master-password-env-prefix", "",
+		"The prefix of the environment variables MasterPassword sources may read. Variables are not allowed if empty.")
+	flag.StringVar(&unsealPeers, "unseal-peers", "",
+		"The DNS name of a headless Service resolving to every replica. Unseals are forwarded to the other replicas.")
+	flag.StringVar(&unsealPeerServerName, "unseal-peer-server-name", "",
+		"The name the webhook certificate of the replicas is valid for, verified on forwarded unseals.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -179,16 +208,74 @@ func main() {
 		// if you are doing or is intended to do any operation such as perform cleanups
 		// after the manager stops then its usage might be unsafe.
 		// LeaderElectionReleaseOnCancel: true,
+
+		// Only the sealing key ConfigMap in the operator namespace is read, so other ConfigMaps
+		// are not cached
+		Cache: cache.Options{
+			ByObject: map[client.Object]cache.ByObject{
+				&corev1.ConfigMap{}: {Namespaces: map[string]cache.Config{operatorNamespace: {}}},
+			},
+		},
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
+	// Master passwords with Memory storage are unsealed through the webhook server, which serves
+	// on every replica. Each replica keeps its own copy, so unseals are forwarded to the other
+	// replicas, and a replica started later must be unsealed again
+	vault := unseal.NewVault()
+	unsealHandler := &unseal.Handler{Client: mgr.GetClient(), Vault: vault}
+	if unsealPeers != "" {
+		peerClient, err := unseal.NewPeerClient(filepath.Join(webhookCertPath, "ca.crt"), unsealPeerServerName)
+		if err != nil {
+			setupLog.Error(err, "unable to create the unseal peer client")
+			os.Exit(1)
+		}
+		port := webhookServerOptions.Port
+		if port == 0 {
+			port = webhook.DefaultPort
+		}
+		unsealHandler.Peers = &unseal.Peers{
+			Service: unsealPeers,
+			Port:    port,
+			Self:    os.Getenv("POD_IP"),
+			Client:  peerClient,
+		}
+	}
+	webhookServer.Register(unseal.Path, unsealHandler)
+
+	// Derivation backends keep their connections across reconciles
+	derivers := controller.NewDeriverCache()
+
+	// Master passwords with KMS encryption stay unreadable without a KMS plugin
+	var kmsClient *kms.Client
+	switch {
+	case kmsEndpoint != "":
+		svc, err := kms.NewGRPCService(kmsEndpoint, kmsTimeout)
+		if err != nil {
+			setupLog.Error(err, "unable to connect to the KMS plugin")
+			os.Exit(1)
+		}
+		kmsClient = kms.NewClient(svc, kmsCacheTTL)
+	case kmsKeyFile != "":
+		svc, err := kms.NewLocalServiceFromFile(kmsKeyFile)
+		if err != nil {
+			setupLog.Error(err, "unable to load the local KMS key")
+			os.Exit(1)
+		}
+		kmsClient = kms.NewClient(svc, kmsCacheTTL)
+	}
+
 	if err := (&controller.MasterPasswordReconciler{
 		Client:            mgr.GetClient(),
 		Scheme:            mgr.GetScheme(),
 		OperatorNamespace: operatorNamespace,
+		Vault:             vault,
+		KMS:               kmsClient,
+		Derivers:          derivers,
+		Sources:           sourcePolicy,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "MasterPassword")
 		os.Exit(1)
@@ -197,10 +284,61 @@ func main() {
 		Client:            mgr.GetClient(),
 		Scheme:            mgr.GetScheme(),
 		OperatorNamespace: operatorNamespace,
+		Vault:             vault,
+		KMS:               kmsClient,
+		Derivers:          derivers,
+		Sources:           sourcePolicy,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "DerivedSecret")
 		os.Exit(1)
 	}
+	if err := (&controller.NamespaceMasterPasswordReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "NamespaceMasterPassword")
+		os.Exit(1)
+	}
+	if err := (&controller.ClusterDerivedSecretReconciler{
+		Client:            mgr.GetClient(),
+		Scheme:            mgr.GetScheme(),
+		OperatorNamespace: operatorNamespace,
+		Vault:             vault,
+		KMS:               kmsClient,
+		Derivers:          derivers,
+		Sources:           sourcePolicy,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterDerivedSecret")
+		os.Exit(1)
+	}
+	if err := (&controller.RotationRequestReconciler{
+		Client:            mgr.GetClient(),
+		Scheme:            mgr.GetScheme(),
+		OperatorNamespace: operatorNamespace,
+		KMS:               kmsClient,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "RotationRequest")
+		os.Exit(1)
+	}
+	// nolint:goconst
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err := webhooksecretsv1beta1.SetupMasterPasswordWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "MasterPassword")
+			os.Exit(1)
+		}
+		if err := webhooksecretsv1beta1.SetupDerivedSecretWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "DerivedSecret")
+			os.Exit(1)
+		}
+		if err := webhooksecretsv1beta1.SetupClusterDerivedSecretWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ClusterDerivedSecret")
+			os.Exit(1)
+		}
+		if err := webhooksecretsv1beta1.SetupRotationRequestWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "RotationRequest")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -0,0 +1,327 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/clock"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	secretsv1beta1 "github.com/oleksiyp/derived-secret-operator/api/v1beta1"
+	"github.com/oleksiyp/derived-secret-operator/internal/kms"
+	"github.com/oleksiyp/derived-secret-operator/internal/unseal"
+)
+
+// ClusterDerivedSecretReconciler reconciles a ClusterDerivedSecret object
+type ClusterDerivedSecretReconciler struct {
+	client.Client
+	Scheme            *runtime.Scheme
+	OperatorNamespace string
+	// Clock is used to select the rotation epoch of scheduled keys; defaults to the real clock
+	Clock clock.PassiveClock
+	// Vault holds the master passwords with Memory storage; nil keeps them sealed
+	Vault *unseal.Vault
+	// KMS decrypts the master passwords of MasterPasswords with KMS encryption; nil if none is configured
+	KMS *kms.Client
+	// Derivers caches the derivers of MasterPasswords with a derivation backend; nil builds one per use
+	Derivers *DeriverCache
+	// Sources restricts the files and environment variables MasterPassword sources may read
+	Sources SourcePolicy
+}
+
+// +kubebuilder:rbac:groups=secrets.oleksiyp.dev,resources=clusterderivedsecrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=secrets.oleksiyp.dev,resources=clusterderivedsecrets/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=secrets.oleksiyp.dev,resources=masterpasswords,verbs=get;list;watch
+// +kubebuilder:rbac:groups=secrets.oleksiyp.dev,resources=namespacemasterpasswords,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+// Reconcile is part of the main kubernetes reconciliation loop which aims to
+// move the current state of the cluster closer to the desired state.
+func (r *ClusterDerivedSecretReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	// Fetch the ClusterDerivedSecret instance
+	cds := &secretsv1beta1.ClusterDerivedSecret{}
+	err := r.Get(ctx, req.NamespacedName, cds)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Info("ClusterDerivedSecret resource not found. Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get ClusterDerivedSecret")
+		return ctrl.Result{}, err
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(&cds.Spec.NamespaceSelector)
+	if err != nil {
+		// An invalid selector is not retried until the ClusterDerivedSecret changes
+		log.Info("Invalid namespaceSelector", "error", err.Error())
+		r.setCondition(cds, "Ready", metav1.ConditionFalse, "InvalidSelector", err.Error())
+		return ctrl.Result{}, r.Status().Update(ctx, cds)
+	}
+
+	namespaces := &corev1.NamespaceList{}
+	if err := r.List(ctx, namespaces, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		log.Error(err, "Failed to list namespaces")
+		return ctrl.Result{}, err
+	}
+
+	// Write the derived secret to every matching namespace; a failing namespace does not
+	// hold back the others
+	var errs []error
+	matched := make(map[string]bool, len(namespaces.Items))
+	statuses := make([]secretsv1beta1.NamespaceStatus, 0, len(namespaces.Items))
+	for _, namespace := range namespaces.Items {
+		if namespace.DeletionTimestamp != nil {
+			continue
+		}
+		matched[namespace.Name] = true
+
+		status, err := r.reconcileNamespace(ctx, cds, namespace.Name)
+		if err != nil {
+			log.Error(err, "Failed to reconcile derived secret", "namespace", namespace.Name)
+			errs = append(errs, err)
+		}
+		statuses = append(statuses, status)
+	}
+
+	// Clean up namespaces that stopped matching, keeping them in status until that succeeds
+	for _, previous := range cds.Status.Namespaces {
+		if matched[previous.Namespace] {
+			continue
+		}
+		if err := r.releaseNamespace(ctx, cds, previous); err != nil {
+			log.Error(err, "Failed to clean up derived secret", "namespace", previous.Namespace)
+			errs = append(errs, err)
+			previous.Ready = false
+			previous.Reason = "CleanupFailed"
+			previous.Message = err.Error()
+			statuses = append(statuses, previous)
+		}
+	}
+
+	if err := r.updateStatus(ctx, cds, statuses); err != nil {
+		log.Error(err, "Failed to update status")
+		return ctrl.Result{}, err
+	}
+	if len(errs) > 0 {
+		return ctrl.Result{}, errors.Join(errs...)
+	}
+
+	log.Info("Successfully reconciled ClusterDerivedSecret")
+	return ctrl.Result{RequeueAfter: rotationRequeueAfter(cds.Spec.Template.Keys, r.secretWriter().now())}, nil
+}
+
+// reconcileNamespace writes the derived secret to a single namespace and reports its state.
+// Forbidden namespaces, unusable master passwords and conflicts are reported in the status without
+// returning an error
+func (r *ClusterDerivedSecretReconciler) reconcileNamespace(
+	ctx context.Context,
+	cds *secretsv1beta1.ClusterDerivedSecret,
+	namespace string,
+) (secretsv1beta1.NamespaceStatus, error) {
+	ds := cds.DerivedSecretFor(namespace)
+	err := r.secretWriter().writeDerivedSecret(ctx, ds, cds)
+
+	status := secretsv1beta1.NamespaceStatus{
+		Namespace:       namespace,
+		Ready:           err == nil,
+		Targets:         ds.Status.Targets,
+		KeyFingerprints: ds.Status.KeyFingerprints,
+		KeyVersions:     ds.Status.KeyVersions,
+	}
+	if err == nil {
+		return status, nil
+	}
+	status.Message = err.Error()
+
+	var forbidden *forbiddenError
+	var pending *pendingError
+	var conflict *conflictError
+	switch {
+	case errors.As(err, &forbidden):
+		status.Reason = "NamespaceNotAllowed"
+	case errors.As(err, &pending):
+		status.Reason = pending.reason
+		status.Message = pending.message
+	case errors.As(err, &conflict):
+		status.Reason = conflict.reason
+		status.Message = conflict.message
+	default:
+		status.Reason = "ReconciliationFailed"
+		return status, err
+	}
+	return status, nil
+}
+
+// releaseNamespace cleans up the secrets written to a namespace that no longer matches
+func (r *ClusterDerivedSecretReconciler) releaseNamespace(
+	ctx context.Context,
+	cds *secretsv1beta1.ClusterDerivedSecret,
+	status secretsv1beta1.NamespaceStatus,
+) error {
+	for _, target := range status.Targets {
+		if !target.Ready {
+			continue
+		}
+		if err := r.secretWriter().releaseTarget(ctx, cds, status.Namespace, target.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// secretWriter returns a DerivedSecretReconciler used to derive and write the secrets
+func (r *ClusterDerivedSecretReconciler) secretWriter() *DerivedSecretReconciler {
+	return &DerivedSecretReconciler{
+		Client:            r.Client,
+		Scheme:            r.Scheme,
+		OperatorNamespace: r.OperatorNamespace,
+		Clock:             r.Clock,
+		Vault:             r.Vault,
+		KMS:               r.KMS,
+		Derivers:          r.Derivers,
+		Sources:           r.Sources,
+	}
+}
+
+// updateStatus updates the ClusterDerivedSecret status
+func (r *ClusterDerivedSecretReconciler) updateStatus(
+	ctx context.Context,
+	cds *secretsv1beta1.ClusterDerivedSecret,
+	statuses []secretsv1beta1.NamespaceStatus,
+) error {
+	ready := 0
+	for _, status := range statuses {
+		if status.Ready {
+			ready++
+		}
+	}
+
+	cds.Status.Namespaces = statuses
+	now := metav1.Now()
+	cds.Status.LastUpdated = &now
+
+	message := fmt.Sprintf("%d/%d namespaces ready", ready, len(statuses))
+	if ready == len(statuses) {
+		r.setCondition(cds, "Ready", metav1.ConditionTrue, "SecretsReady", message)
+	} else {
+		r.setCondition(cds, "Ready", metav1.ConditionFalse, "NamespacesNotReady", message)
+	}
+
+	return r.Status().Update(ctx, cds)
+}
+
+// setCondition sets a condition on the ClusterDerivedSecret
+func (r *ClusterDerivedSecretReconciler) setCondition(
+	cds *secretsv1beta1.ClusterDerivedSecret,
+	condType string,
+	status metav1.ConditionStatus,
+	reason, message string,
+) {
+	condition := metav1.Condition{
+		Type:               condType,
+		Status:             status,
+		ObservedGeneration: cds.Generation,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	}
+	meta.SetStatusCondition(&cds.Status.Conditions, condition)
+}
+
+// clusterTargetSecretNames returns the names of the secrets written by a ClusterDerivedSecret
+// in any namespace, for the targetSecretNameField index
+func clusterTargetSecretNames(obj client.Object) []string {
+	cds := obj.(*secretsv1beta1.ClusterDerivedSecret)
+	names := targetSecretNames(cds.DerivedSecretFor(""))
+	for _, status := range cds.Status.Namespaces {
+		for _, target := range status.Targets {
+			names = append(names, target.Name)
+		}
+	}
+	return names
+}
+
+// enqueueClusterDerivedSecrets returns reconcile requests for the ClusterDerivedSecrets
+// matching the given list options
+func (r *ClusterDerivedSecretReconciler) enqueueClusterDerivedSecrets(
+	ctx context.Context,
+	opts ...client.ListOption,
+) []ctrl.Request {
+	cdsList := &secretsv1beta1.ClusterDerivedSecretList{}
+	if err := r.List(ctx, cdsList, opts...); err != nil {
+		return nil
+	}
+
+	requests := make([]ctrl.Request, 0, len(cdsList.Items))
+	for _, cds := range cdsList.Items {
+		requests = append(requests, ctrl.Request{NamespacedName: types.NamespacedName{Name: cds.Name}})
+	}
+	return requests
+}
+
+// findClusterDerivedSecretsForSecret returns an event handler that maps Secret events to the
+// ClusterDerivedSecrets writing secrets of that name, so that conflicts are retried
+func (r *ClusterDerivedSecretReconciler) findClusterDerivedSecretsForSecret() handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []ctrl.Request {
+		return r.enqueueClusterDerivedSecrets(ctx, client.MatchingFields{targetSecretNameField: obj.GetName()})
+	})
+}
+
+// findAllClusterDerivedSecrets returns an event handler that maps Namespace, MasterPassword and
+// NamespaceMasterPassword events to every ClusterDerivedSecret, since any of them may select
+// a namespace or use a master password
+func (r *ClusterDerivedSecretReconciler) findAllClusterDerivedSecrets() handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []ctrl.Request {
+		return r.enqueueClusterDerivedSecrets(ctx)
+	})
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterDerivedSecretReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(),
+		&secretsv1beta1.ClusterDerivedSecret{}, targetSecretNameField, clusterTargetSecretNames); err != nil {
+		return err
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&secretsv1beta1.ClusterDerivedSecret{}).
+		Watches(&corev1.Secret{}, r.findClusterDerivedSecretsForSecret()).
+		Watches(&secretsv1beta1.MasterPassword{}, r.findAllClusterDerivedSecrets()).
+		Watches(&secretsv1beta1.NamespaceMasterPassword{}, r.findAllClusterDerivedSecrets()).
+		// Namespace labels decide which namespaces are selected
+		Watches(&corev1.Namespace{}, r.findAllClusterDerivedSecrets(),
+			builder.WithPredicates(predicate.LabelChangedPredicate{})).
+		Named("clusterderivedsecret").
+		Complete(r)
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	secretsv1beta1 "github.com/oleksiyp/derived-secret-operator/api/v1beta1"
+)
+
+var _ = Describe("ClusterDerivedSecret Controller", func() {
+	Context("When reconciling a resource", func() {
+		const resourceName = "metrics-token"
+		const masterPasswordName = "cluster-root"
+
+		ctx := context.Background()
+
+		typeNamespacedName := types.NamespacedName{Name: resourceName}
+		namespaces := []string{"metrics-a", "metrics-b"}
+
+		BeforeEach(func() {
+			By("creating the MasterPassword and its secret")
+			masterPasswordSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      masterPasswordName + "-mp",
+					Namespace: "default",
+				},
+				Data: map[string][]byte{
+					masterPasswordKey: []byte("cluster-master-password-for-testing-only"),
+				},
+			}
+			Expect(k8sClient.Create(ctx, masterPasswordSecret)).To(Succeed())
+			masterPassword := &secretsv1beta1.MasterPassword{
+				ObjectMeta: metav1.ObjectMeta{Name: masterPasswordName},
+			}
+			Expect(k8sClient.Create(ctx, masterPassword)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, masterPassword)).To(Succeed())
+				Expect(k8sClient.Delete(ctx, masterPasswordSecret)).To(Succeed())
+			})
+
+			By("creating the selected namespaces")
+			for _, name := range namespaces {
+				namespace := &corev1.Namespace{}
+				err := k8sClient.Get(ctx, types.NamespacedName{Name: name}, namespace)
+				if err != nil && errors.IsNotFound(err) {
+					namespace = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+					Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+				}
+				namespace.Labels = map[string]string{"metrics": "enabled"}
+				Expect(k8sClient.Update(ctx, namespace)).To(Succeed())
+			}
+
+			By("creating the custom resource for the Kind ClusterDerivedSecret")
+			resource := &secretsv1beta1.ClusterDerivedSecret{
+				ObjectMeta: metav1.ObjectMeta{Name: resourceName},
+				Spec: secretsv1beta1.ClusterDerivedSecretSpec{
+					NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"metrics": "enabled"}},
+					Template: secretsv1beta1.DerivedSecretSpec{
+						MasterPassword: masterPasswordName,
+						Keys: map[string]secretsv1beta1.DerivedKeySpec{
+							"token": {Type: secretsv1beta1.SecretTypePassword},
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			resource := &secretsv1beta1.ClusterDerivedSecret{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+
+			By("Cleanup the specific resource instance ClusterDerivedSecret")
+			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+
+			// envtest runs no garbage collector, so the derived secrets are removed explicitly
+			for _, name := range namespaces {
+				secret := &corev1.Secret{}
+				if err := k8sClient.Get(ctx, types.NamespacedName{Name: resourceName, Namespace: name}, secret); err == nil {
+					Expect(k8sClient.Delete(ctx, secret)).To(Succeed())
+				}
+			}
+		})
+
+		It("should write distinct secrets to selected namespaces and clean up", func() {
+			controllerReconciler := &ClusterDerivedSecretReconciler{
+				Client:            k8sClient,
+				Scheme:            k8sClient.Scheme(),
+				OperatorNamespace: "default",
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			secretA := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceName, Namespace: "metrics-a"}, secretA)).
+				To(Succeed())
+			secretB := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceName, Namespace: "metrics-b"}, secretB)).
+				To(Succeed())
+			Expect(secretA.Data).To(HaveKey("token"))
+			Expect(secretA.Data["token"]).NotTo(Equal(secretB.Data["token"]))
+
+			cds := &secretsv1beta1.ClusterDerivedSecret{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, cds)).To(Succeed())
+			Expect(metav1.IsControlledBy(secretA, cds)).To(BeTrue())
+			Expect(cds.Status.Namespaces).To(HaveLen(2))
+			Expect(meta.IsStatusConditionTrue(cds.Status.Conditions, "Ready")).To(BeTrue())
+
+			By("Unlabeling a namespace")
+			namespace := &corev1.Namespace{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "metrics-b"}, namespace)).To(Succeed())
+			namespace.Labels = nil
+			Expect(k8sClient.Update(ctx, namespace)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: resourceName, Namespace: "metrics-b"}, &corev1.Secret{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, cds)).To(Succeed())
+			Expect(cds.Status.Namespaces).To(HaveLen(1))
+			Expect(cds.Status.Namespaces[0].Namespace).To(Equal("metrics-a"))
+		})
+	})
+})
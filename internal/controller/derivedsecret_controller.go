@@ -18,7 +18,12 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -26,30 +31,80 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/clock"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
-	secretsv1alpha1 "github.com/oleksiyp/derived-secret-operator/api/v1alpha1"
+	secretsv1beta1 "github.com/oleksiyp/derived-secret-operator/api/v1beta1"
 	"github.com/oleksiyp/derived-secret-operator/internal/crypto"
+	"github.com/oleksiyp/derived-secret-operator/internal/kms"
+	"github.com/oleksiyp/derived-secret-operator/internal/unseal"
 )
 
-const (
-	defaultMasterPasswordName = "default"
-)
+// forbiddenError reports that a namespace is not allowed to use a MasterPassword
+type forbiddenError struct {
+	namespace      string
+	masterPassword string
+}
+
+func (e *forbiddenError) Error() string {
+	return fmt.Sprintf("namespace %s is not allowed to use MasterPassword %s", e.namespace, e.masterPassword)
+}
+
+// conflictError reports that the target Secret may not be written by the DerivedSecret, or that a
+// master password cannot be used. DerivedSecrets report the latter as a pendingError
+type conflictError struct {
+	reason  string
+	message string
+}
+
+func (e *conflictError) Error() string {
+	return e.message
+}
+
+// pendingError reports that a master password the DerivedSecret derives from cannot be used yet,
+// for example while it is sealed or its generation is missing
+type pendingError struct {
+	reason  string
+	message string
+}
+
+func (e *pendingError) Error() string {
+	return e.message
+}
+
+// blockingConditions are the conditions reportBlocked records; they are cleared once the
+// DerivedSecret is ready
+var blockingConditions = []string{"Forbidden", "Pending", "Conflict"}
 
 // DerivedSecretReconciler reconciles a DerivedSecret object
 type DerivedSecretReconciler struct {
 	client.Client
 	Scheme            *runtime.Scheme
 	OperatorNamespace string
+	// Clock is used to select the rotation epoch of scheduled keys; defaults to the real clock
+	Clock clock.PassiveClock
+	// Vault holds the master passwords with Memory storage; nil keeps them sealed
+	Vault *unseal.Vault
+	// KMS decrypts the master passwords of MasterPasswords with KMS encryption; nil if none is configured
+	KMS *kms.Client
+	// Derivers caches the derivers of MasterPasswords with a derivation backend; nil builds one per use
+	Derivers *DeriverCache
+	// Sources restricts the files and environment variables MasterPassword sources may read
+	Sources SourcePolicy
 }
 
 // +kubebuilder:rbac:groups=secrets.oleksiyp.dev,resources=derivedsecrets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=secrets.oleksiyp.dev,resources=derivedsecrets/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=secrets.oleksiyp.dev,resources=masterpasswords,verbs=get;list;watch
+// +kubebuilder:rbac:groups=secrets.oleksiyp.dev,resources=namespacemasterpasswords,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -57,7 +112,7 @@ func (r *DerivedSecretReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 	log := logf.FromContext(ctx)
 
 	// Fetch the DerivedSecret instance
-	derivedSecret := &secretsv1alpha1.DerivedSecret{}
+	derivedSecret := &secretsv1beta1.DerivedSecret{}
 	err := r.Get(ctx, req.NamespacedName, derivedSecret)
 	if err != nil {
 		if apierrors.IsNotFound(err) {
@@ -68,8 +123,32 @@ func (r *DerivedSecretReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		return ctrl.Result{}, err
 	}
 
+	// Apply a requested staged rotation step before writing the secret
+	if err := r.advanceStagedRotation(ctx, derivedSecret); err != nil {
+		log.Error(err, "Failed to advance staged rotation")
+		return ctrl.Result{}, err
+	}
+
 	// Reconcile the derived secret
 	if err := r.reconcileDerivedSecret(ctx, derivedSecret); err != nil {
+		// Forbidden namespaces, unusable master passwords and conflicts are not retried; changes
+		// to the DerivedSecret, its MasterPasswords, the Namespace or the Secret trigger a new reconcile
+		var forbidden *forbiddenError
+		if errors.As(err, &forbidden) {
+			log.Info("Namespace is not allowed to use MasterPassword", "masterPassword", forbidden.masterPassword)
+			return ctrl.Result{}, r.reportBlocked(ctx, derivedSecret, "Forbidden", "NamespaceNotAllowed", forbidden.Error())
+		}
+		var pending *pendingError
+		if errors.As(err, &pending) {
+			log.Info("Waiting for master password", "reason", pending.reason, "message", pending.message)
+			return ctrl.Result{}, r.reportBlocked(ctx, derivedSecret, "Pending", pending.reason, pending.message)
+		}
+		var conflict *conflictError
+		if errors.As(err, &conflict) {
+			log.Info("Refusing to write secret", "reason", conflict.reason, "message", conflict.message)
+			return ctrl.Result{}, r.reportBlocked(ctx, derivedSecret, "Conflict", conflict.reason, conflict.message)
+		}
+
 		log.Error(err, "Failed to reconcile derived secret")
 		r.setCondition(derivedSecret, "Ready", metav1.ConditionFalse, "ReconciliationFailed", err.Error())
 		if err := r.Status().Update(ctx, derivedSecret); err != nil {
@@ -85,159 +164,749 @@ func (r *DerivedSecretReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 	}
 
 	log.Info("Successfully reconciled DerivedSecret")
-	return ctrl.Result{}, nil
+	// Keys with a rotation schedule are rewritten at the next epoch boundary or overlap end
+	return ctrl.Result{RequeueAfter: rotationRequeueAfter(derivedSecret.Spec.Keys, r.now())}, nil
 }
 
-// reconcileDerivedSecret reconciles the actual Kubernetes secret based on the DerivedSecret spec
-func (r *DerivedSecretReconciler) reconcileDerivedSecret(ctx context.Context, ds *secretsv1alpha1.DerivedSecret) error {
-	log := logf.FromContext(ctx)
+// reconcileDerivedSecret reconciles the target Kubernetes secrets based on the DerivedSecret spec
+func (r *DerivedSecretReconciler) reconcileDerivedSecret(ctx context.Context, ds *secretsv1beta1.DerivedSecret) error {
+	return r.writeDerivedSecret(ctx, ds, ds)
+}
 
-	// Derive all secrets and calculate hashes
+// writeDerivedSecret derives the keys of ds and writes them to its targets as secrets
+// controlled by owner. The previous targets are read from and stored in ds.Status
+func (r *DerivedSecretReconciler) writeDerivedSecret(
+	ctx context.Context,
+	ds *secretsv1beta1.DerivedSecret,
+	owner client.Object,
+) error {
+	// Derive all secrets and fingerprint them
 	secretData := make(map[string][]byte)
-	keyHashes := make(map[string]int32)
+	keyFingerprints := make(map[string]string)
+	fingerprintKeys := make(map[secretsv1beta1.MasterPasswordReference][]byte)
+	epochs := make(map[string]int64)
+	generations := make(map[string]int)
+	now := r.now()
 
 	for keyName, keySpec := range ds.Spec.Keys {
-		masterPasswordName := keySpec.MasterPassword
-		if masterPasswordName == "" {
-			masterPasswordName = defaultMasterPasswordName
-		}
+		ref := ds.Spec.MasterPasswordRefFor(keySpec)
 
-		// Get the master password
-		masterPassword, err := r.getMasterPassword(ctx, masterPasswordName)
+		// Get the deriver of the master password; a master password that cannot be used yet
+		// holds back the secret, but is not a conflict over it
+		deriver, generation, err := r.getDeriver(ctx, ds, ref)
 		if err != nil {
-			return fmt.Errorf("failed to get master password %s: %w", masterPasswordName, err)
+			var conflict *conflictError
+			if errors.As(err, &conflict) {
+				return &pendingError{reason: conflict.reason, message: conflict.message}
+			}
+			return fmt.Errorf("failed to get master password %s: %w", ref.Name, err)
+		}
+		if ref.Kind == secretsv1beta1.KindMasterPassword {
+			generations[ref.Name] = generation
 		}
 
 		// Derive the secret
 		length := crypto.GetSecretLength(string(keySpec.Type), keySpec.Length)
-		derivationContext := crypto.BuildContext(ds.Namespace, ds.Name, keyName)
+		baseContext := derivationContext(ds, keyName, keySpec)
+		derivationContext := crypto.VersionContext(baseContext, keySpec.Version)
+
+		if suffix, version, ok := stagedRotationValue(ds, keyName); ok {
+			// Keys in a staged rotation also publish their next or previous version
+			stagedValue, err := crypto.DeriveSecretWith(
+				ctx, deriver, crypto.VersionContext(baseContext, version), length)
+			if err != nil {
+				return fmt.Errorf("failed to derive staged secret for key %s: %w", keyName, err)
+			}
+			secretData[keyName+suffix] = []byte(stagedValue)
+		}
 
-		derivedValue, err := crypto.DeriveSecret(masterPassword, derivationContext, length)
+		if keySpec.Rotation != nil {
+			// Scheduled keys derive from the current epoch bucket and publish the previous
+			// epoch's value during the overlap window
+			interval := keySpec.Rotation.Interval.Duration
+			epoch := crypto.RotationEpoch(now, interval)
+			epochs[keyName] = epoch
+			if now.Sub(crypto.EpochStart(epoch, interval)) < keySpec.Rotation.OverlapDuration() {
+				previousValue, err := crypto.DeriveSecretWith(
+					ctx, deriver, crypto.EpochContext(derivationContext, epoch-1), length)
+				if err != nil {
+					return fmt.Errorf("failed to derive previous secret for key %s: %w", keyName, err)
+				}
+				secretData[keyName+secretsv1beta1.PreviousKeySuffix] = []byte(previousValue)
+			}
+			derivationContext = crypto.EpochContext(derivationContext, epoch)
+		}
+
+		derivedValue, err := crypto.DeriveSecretWith(ctx, deriver, derivationContext, length)
 		if err != nil {
 			return fmt.Errorf("failed to derive secret for key %s: %w", keyName, err)
 		}
 
 		secretData[keyName] = []byte(derivedValue)
-		keyHashes[keyName] = crypto.CalculatePasswordHash(derivedValue)
+
+		// Fingerprints are bound to the key they are recorded for, so equal values of different
+		// keys have different fingerprints
+		fingerprintKey, ok := fingerprintKeys[ref]
+		if !ok {
+			if fingerprintKey, err = crypto.FingerprintKey(ctx, deriver); err != nil {
+				return fmt.Errorf("failed to derive fingerprint key for key %s: %w", keyName, err)
+			}
+			fingerprintKeys[ref] = fingerprintKey
+		}
+		keyFingerprints[keyName] = crypto.Fingerprint(fingerprintKey,
+			crypto.BuildContext(ds.Namespace, ds.Name, keyName), derivedValue)
 	}
 
-	// Create or update the Kubernetes secret
-	secret := &corev1.Secret{}
-	secretName := ds.Name
-	err := r.Get(ctx, types.NamespacedName{Name: secretName, Namespace: ds.Namespace}, secret)
+	// Write every target; a conflict on one target does not hold back the others
+	targets := ds.TargetSecrets()
+	statuses := make([]secretsv1beta1.TargetStatus, 0, len(targets))
+	var firstConflict error
+	for _, target := range targets {
+		status := secretsv1beta1.TargetStatus{Name: target.Name, Ready: true}
+		if err := r.reconcileTarget(ctx, ds, owner, target, secretData); err != nil {
+			var conflict *conflictError
+			if !errors.As(err, &conflict) {
+				return err
+			}
+			status.Ready = false
+			status.Reason = conflict.reason
+			status.Message = conflict.message
+			if firstConflict == nil {
+				firstConflict = err
+			}
+		}
+		statuses = append(statuses, status)
+	}
 
-	if err != nil {
+	// Release the secrets of targets that were removed from the spec
+	for _, previous := range ds.Status.Targets {
+		removed := !slices.ContainsFunc(targets, func(target secretsv1beta1.DerivedSecretTarget) bool {
+			return target.Name == previous.Name
+		})
+		if previous.Ready && removed {
+			if err := r.releaseTarget(ctx, owner, ds.Namespace, previous.Name); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Store target states and key fingerprints in status after the secrets were written
+	ds.Status.Targets = statuses
+	ds.Status.KeyFingerprints = keyFingerprints
+	ds.Status.MasterPasswordGenerations = generations
+	recordKeyVersions(ds, keyFingerprints, epochs)
+	return firstConflict
+}
+
+// advanceStagedRotation applies the staged rotation step requested by the rotation annotation,
+// unless it was applied already. Promoting bumps the versions of the rotated keys in the spec,
+// so the new values stay current after the rotation is finalized
+func (r *DerivedSecretReconciler) advanceStagedRotation(ctx context.Context, ds *secretsv1beta1.DerivedSecret) error {
+	action, ok := ds.Annotations[secretsv1beta1.RotationAnnotation]
+	if !ok {
+		return nil
+	}
+
+	rotation := ds.Status.StagedRotation
+	switch secretsv1beta1.RotationAction(action) {
+	case secretsv1beta1.RotationActionStage:
+		if rotation != nil {
+			if rotation.Phase == secretsv1beta1.StagedRotationPromoted {
+				r.setCondition(ds, "Rotation", metav1.ConditionFalse, "InvalidRotation",
+					"The promoted rotation must be finalized before staging another one")
+			}
+			return nil
+		}
+		fromVersions, err := rotatedKeyVersions(ds)
+		if err != nil {
+			r.setCondition(ds, "Rotation", metav1.ConditionFalse, "InvalidRotation", err.Error())
+			return nil
+		}
+		ds.Status.StagedRotation = &secretsv1beta1.StagedRotationStatus{
+			Phase:        secretsv1beta1.StagedRotationStaged,
+			FromVersions: fromVersions,
+		}
+		r.setCondition(ds, "Rotation", metav1.ConditionTrue, "Staged",
+			"Next values are published as <key>"+secretsv1beta1.NextKeySuffix)
+
+	case secretsv1beta1.RotationActionPromote:
+		if rotation == nil {
+			r.setCondition(ds, "Rotation", metav1.ConditionFalse, "InvalidRotation",
+				"No rotation is staged")
+			return nil
+		}
+		if rotation.Phase == secretsv1beta1.StagedRotationPromoted {
+			return nil
+		}
+		for keyName, from := range rotation.FromVersions {
+			if keySpec, ok := ds.Spec.Keys[keyName]; ok {
+				keySpec.Version = from + 1
+				ds.Spec.Keys[keyName] = keySpec
+			}
+		}
+		// Updating the spec returns the stored status, which lacks the changes of this reconcile
+		status := ds.Status.DeepCopy()
+		if err := r.Update(ctx, ds); err != nil {
+			return fmt.Errorf("failed to promote rotated keys: %w", err)
+		}
+		ds.Status = *status
+		ds.Status.StagedRotation.Phase = secretsv1beta1.StagedRotationPromoted
+		r.setCondition(ds, "Rotation", metav1.ConditionTrue, "Promoted",
+			"Previous values are published as <key>"+secretsv1beta1.PreviousKeySuffix)
+
+	case secretsv1beta1.RotationActionFinalize:
+		if rotation == nil {
+			return nil
+		}
+		if rotation.Phase == secretsv1beta1.StagedRotationStaged {
+			r.setCondition(ds, "Rotation", metav1.ConditionFalse, "InvalidRotation",
+				"The staged rotation must be promoted before it is finalized")
+			return nil
+		}
+		ds.Status.StagedRotation = nil
+		r.setCondition(ds, "Rotation", metav1.ConditionFalse, "Finalized", "Rotation is complete")
+
+	default:
+		r.setCondition(ds, "Rotation", metav1.ConditionFalse, "InvalidRotation",
+			fmt.Sprintf("Unknown rotation step %q", action))
+	}
+	return nil
+}
+
+// rotatedKeyVersions returns the current version of every key a staged rotation applies to
+func rotatedKeyVersions(ds *secretsv1beta1.DerivedSecret) (map[string]int, error) {
+	value, ok := ds.Annotations[secretsv1beta1.RotationKeysAnnotation]
+	if !ok {
+		return keyVersions(ds, nil)
+	}
+	var keyNames []string
+	for keyName := range strings.SplitSeq(value, ",") {
+		keyNames = append(keyNames, strings.TrimSpace(keyName))
+	}
+	return keyVersions(ds, keyNames)
+}
+
+// keyVersions returns the current version of the given keys to rotate, or of all keys without
+// a rotation schedule if none are given
+func keyVersions(ds *secretsv1beta1.DerivedSecret, keyNames []string) (map[string]int, error) {
+	fromVersions := make(map[string]int)
+	if len(keyNames) == 0 {
+		for keyName, keySpec := range ds.Spec.Keys {
+			if keySpec.Rotation == nil {
+				fromVersions[keyName] = keySpec.Version
+			}
+		}
+		return fromVersions, nil
+	}
+
+	for _, keyName := range keyNames {
+		keySpec, ok := ds.Spec.Keys[keyName]
+		switch {
+		case !ok:
+			return nil, fmt.Errorf("key %q to rotate does not exist", keyName)
+		case keySpec.Rotation != nil:
+			return nil, fmt.Errorf("key %q has a rotation schedule", keyName)
+		}
+		fromVersions[keyName] = keySpec.Version
+	}
+	return fromVersions, nil
+}
+
+// stagedRotationValue returns the suffix and version of the extra value a key publishes
+// during a staged rotation, if any
+func stagedRotationValue(ds *secretsv1beta1.DerivedSecret, keyName string) (string, int, bool) {
+	rotation := ds.Status.StagedRotation
+	if rotation == nil {
+		return "", 0, false
+	}
+	from, ok := rotation.FromVersions[keyName]
+	if !ok {
+		return "", 0, false
+	}
+	if rotation.Phase == secretsv1beta1.StagedRotationPromoted {
+		return secretsv1beta1.PreviousKeySuffix, from, true
+	}
+	return secretsv1beta1.NextKeySuffix, from + 1, true
+}
+
+// reconcileTarget creates or updates the secret of a single target with the derived data
+func (r *DerivedSecretReconciler) reconcileTarget(
+	ctx context.Context,
+	ds *secretsv1beta1.DerivedSecret,
+	owner client.Object,
+	target secretsv1beta1.DerivedSecretTarget,
+	secretData map[string][]byte,
+) error {
+	log := logf.FromContext(ctx)
+
+	// Make sure the secret may be written
+	secretKey := types.NamespacedName{Name: target.Name, Namespace: ds.Namespace}
+	if err := r.checkNotProtectedSecret(ctx, secretKey); err != nil {
+		return err
+	}
+
+	labels := ds.Spec.Labels
+	annotations := ds.Spec.Annotations
+	immutable := false
+	if target.Template != nil {
+		labels = mergeMaps(labels, target.Template.Metadata.Labels)
+		annotations = mergeMaps(annotations, target.Template.Metadata.Annotations)
+		immutable = target.Template.Immutable
+	}
+	merge := target.Merges()
+
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, secretKey, secret); err != nil {
 		if !apierrors.IsNotFound(err) {
 			return fmt.Errorf("failed to get secret: %w", err)
 		}
 
-		// Create new secret
+		if merge {
+			annotations = mergeMaps(annotations, nil)
+			annotations[secretsv1beta1.ManagedKeysAnnotation] = formatManagedKeys(secretData)
+		}
 		secret = &corev1.Secret{
 			ObjectMeta: metav1.ObjectMeta{
-				Name:        secretName,
-				Namespace:   ds.Namespace,
-				Labels:      ds.Spec.Labels,
-				Annotations: ds.Spec.Annotations,
+				Name:        secretKey.Name,
+				Namespace:   secretKey.Namespace,
+				Labels:      labels,
+				Annotations: annotations,
 			},
 			Type: ds.Spec.Type,
 			Data: secretData,
 		}
-
-		// Set owner reference
-		if err := controllerutil.SetControllerReference(ds, secret, r.Scheme); err != nil {
-			return fmt.Errorf("failed to set controller reference: %w", err)
+		if immutable {
+			secret.Immutable = &immutable
 		}
+		return r.createSecret(ctx, owner, secret)
+	}
 
-		if err := r.Create(ctx, secret); err != nil {
-			return fmt.Errorf("failed to create secret: %w", err)
-		}
-
-		log.Info("Created derived secret", "secret", ds.Namespace+"/"+secretName)
-
-		// Store key hashes in status after successful secret creation
-		ds.Status.KeyHashes = keyHashes
-		return nil
+	adopted, err := r.adoptSecret(ds, owner, target, secret)
+	if err != nil {
+		return err
 	}
 
 	// Update existing secret
-	needsUpdate := false
+	needsUpdate := adopted
+	dataChanged := false
+
+	// In Merge mode only the managed keys, labels and annotations are touched
+	desiredData := secretData
+	desiredLabels := labels
+	desiredAnnotations := annotations
+	if merge {
+		desiredData = mergeSecretData(secret.Data, managedKeys(secret), secretData)
+		desiredLabels = mergeMaps(secret.Labels, labels)
+		desiredAnnotations = mergeMaps(secret.Annotations, annotations)
+		desiredAnnotations[secretsv1beta1.ManagedKeysAnnotation] = formatManagedKeys(secretData)
+	}
 
 	// Check if data changed
-	if !equalSecretData(secret.Data, secretData) {
-		secret.Data = secretData
-		needsUpdate = true
+	if !equalSecretData(secret.Data, desiredData) {
+		secret.Data = desiredData
+		dataChanged = true
 	}
 
-	// Check if type changed
-	if secret.Type != ds.Spec.Type {
+	// Check if type changed; a shared secret keeps its own type
+	if !merge && secret.Type != ds.Spec.Type {
 		secret.Type = ds.Spec.Type
-		needsUpdate = true
+		dataChanged = true
 	}
 
 	// Update labels
-	if !equalMaps(secret.Labels, ds.Spec.Labels) {
-		secret.Labels = ds.Spec.Labels
+	if !equalMaps(secret.Labels, desiredLabels) {
+		secret.Labels = desiredLabels
 		needsUpdate = true
 	}
 
 	// Update annotations
-	if !equalMaps(secret.Annotations, ds.Spec.Annotations) {
-		secret.Annotations = ds.Spec.Annotations
+	if !equalMaps(secret.Annotations, desiredAnnotations) {
+		secret.Annotations = desiredAnnotations
+		needsUpdate = true
+	}
+
+	// An immutable secret can only be replaced to change its data
+	if dataChanged && secret.Immutable != nil && *secret.Immutable {
+		if !metav1.IsControlledBy(secret, owner) {
+			return &conflictError{
+				reason: "SecretImmutable",
+				message: fmt.Sprintf("secret %s is immutable and not owned by %s %s",
+					secretKey, ownerKind(owner), owner.GetName()),
+			}
+		}
+		if err := r.Delete(ctx, secret, client.Preconditions{UID: &secret.UID}); err != nil {
+			return fmt.Errorf("failed to delete immutable secret: %w", err)
+		}
+		log.Info("Deleted immutable secret to replace its data", "secret", secretKey.String())
+
+		secret.ObjectMeta = metav1.ObjectMeta{
+			Name:        secret.Name,
+			Namespace:   secret.Namespace,
+			Labels:      secret.Labels,
+			Annotations: secret.Annotations,
+		}
+		secret.Immutable = &immutable
+		return r.createSecret(ctx, owner, secret)
+	}
+
+	if immutable && (secret.Immutable == nil || !*secret.Immutable) {
+		secret.Immutable = &immutable
 		needsUpdate = true
 	}
 
-	if needsUpdate {
+	if needsUpdate || dataChanged {
 		if err := r.Update(ctx, secret); err != nil {
 			return fmt.Errorf("failed to update secret: %w", err)
 		}
-		log.Info("Updated derived secret", "secret", ds.Namespace+"/"+secretName)
+		log.Info("Updated derived secret", "secret", secretKey.String())
 	}
 
-	// Store key hashes in status after successful secret reconciliation
-	ds.Status.KeyHashes = keyHashes
 	return nil
 }
 
-// getMasterPassword fetches the master password from the MasterPassword resource
-func (r *DerivedSecretReconciler) getMasterPassword(ctx context.Context, name string) (string, error) {
-	// Fetch the MasterPassword resource
-	masterPassword := &secretsv1alpha1.MasterPassword{}
-	if err := r.Get(ctx, types.NamespacedName{Name: name}, masterPassword); err != nil {
-		return "", fmt.Errorf("failed to get MasterPassword %s: %w", name, err)
+// createSecret creates a secret controlled by owner
+func (r *DerivedSecretReconciler) createSecret(ctx context.Context, owner client.Object, secret *corev1.Secret) error {
+	// Set owner reference
+	if err := controllerutil.SetControllerReference(owner, secret, r.Scheme); err != nil {
+		return fmt.Errorf("failed to set controller reference: %w", err)
 	}
 
-	// Get the secret name and namespace
-	secretName := masterPassword.Name + "-mp"
-	if masterPassword.Spec.Secret != nil && masterPassword.Spec.Secret.Name != "" {
-		secretName = masterPassword.Spec.Secret.Name
+	if err := r.Create(ctx, secret); err != nil {
+		return fmt.Errorf("failed to create secret: %w", err)
 	}
-	secretNamespace := r.OperatorNamespace
 
-	// Fetch the secret
+	logf.FromContext(ctx).Info("Created derived secret", "secret", secret.Namespace+"/"+secret.Name)
+	return nil
+}
+
+// releaseTarget cleans up the secret of a target that is no longer written by owner.
+// A secret it controls is deleted; a shared secret only loses the keys merged into it
+func (r *DerivedSecretReconciler) releaseTarget(
+	ctx context.Context,
+	owner client.Object,
+	namespace, name string,
+) error {
+	log := logf.FromContext(ctx)
+
 	secret := &corev1.Secret{}
-	if err := r.Get(ctx, types.NamespacedName{Name: secretName, Namespace: secretNamespace}, secret); err != nil {
-		return "", fmt.Errorf("failed to get master password secret %s/%s: %w", secretNamespace, secretName, err)
+	if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, secret); err != nil {
+		return client.IgnoreNotFound(err)
 	}
 
-	// Extract the master password
-	passwordBytes, ok := secret.Data[masterPasswordKey]
-	if !ok {
-		return "", fmt.Errorf("master password secret %s/%s missing key %s", secretNamespace, secretName, masterPasswordKey)
+	if metav1.IsControlledBy(secret, owner) {
+		if err := r.Delete(ctx, secret); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete secret %s: %w", name, err)
+		}
+		log.Info("Deleted secret of removed target", "secret", namespace+"/"+name)
+		return nil
 	}
 
-	return string(passwordBytes), nil
+	keys := managedKeys(secret)
+	if len(keys) == 0 {
+		return nil
+	}
+	secret.Data = mergeSecretData(secret.Data, keys, nil)
+	delete(secret.Annotations, secretsv1beta1.ManagedKeysAnnotation)
+	if err := r.Update(ctx, secret); err != nil {
+		return fmt.Errorf("failed to remove derived keys from secret %s: %w", name, err)
+	}
+	log.Info("Removed derived keys from secret of removed target", "secret", namespace+"/"+name)
+	return nil
+}
+
+// checkNotProtectedSecret returns a conflictError if the secret is one the operator's master
+// passwords depend on: a master password secret or its history, a Shamir share, a source or
+// derivation backend credential, or the sealing key. These are never written, whatever the
+// adoption policy
+func (r *DerivedSecretReconciler) checkNotProtectedSecret(ctx context.Context, key types.NamespacedName) error {
+	if key == (types.NamespacedName{Name: sealingKeyName, Namespace: r.OperatorNamespace}) {
+		return &conflictError{
+			reason:  "ProtectedSecret",
+			message: fmt.Sprintf("secret %s holds the key master passwords are sealed to", key),
+		}
+	}
+
+	mpList := &secretsv1beta1.MasterPasswordList{}
+	if err := r.List(ctx, mpList); err != nil {
+		return fmt.Errorf("failed to list MasterPasswords: %w", err)
+	}
+	for i := range mpList.Items {
+		mp := &mpList.Items[i]
+		if holds, ok := masterPasswordSecrets(mp, r.OperatorNamespace)[key]; ok {
+			return &conflictError{
+				reason:  "ProtectedSecret",
+				message: fmt.Sprintf("secret %s holds the %s of MasterPassword %s", key, holds, mp.Name),
+			}
+		}
+	}
+
+	nsmpList := &secretsv1beta1.NamespaceMasterPasswordList{}
+	if err := r.List(ctx, nsmpList, client.InNamespace(key.Namespace)); err != nil {
+		return fmt.Errorf("failed to list NamespaceMasterPasswords: %w", err)
+	}
+	for _, nsmp := range nsmpList.Items {
+		if masterPasswordSecretName(nsmp.Name, nsmp.Spec.Secret) == key.Name {
+			return &conflictError{
+				reason:  "ProtectedSecret",
+				message: fmt.Sprintf("secret %s holds the master password of NamespaceMasterPassword %s", key, nsmp.Name),
+			}
+		}
+	}
+
+	return nil
+}
+
+// masterPasswordSecrets returns every Secret a MasterPassword reads or writes, with what it holds
+func masterPasswordSecrets(
+	mp *secretsv1beta1.MasterPassword,
+	operatorNamespace string,
+) map[types.NamespacedName]string {
+	primary := types.NamespacedName{
+		Name:      masterPasswordSecretName(mp.Name, mp.Spec.Secret),
+		Namespace: masterPasswordSecretNamespace(mp.Spec.Secret, operatorNamespace),
+	}
+	secrets := map[types.NamespacedName]string{
+		primary: "master password",
+		{Name: masterPasswordHistorySecretName(primary.Name), Namespace: primary.Namespace}: "master password history",
+	}
+	if shamir := mp.Spec.Shamir; shamir != nil {
+		for _, share := range shamir.Shares {
+			secrets[types.NamespacedName{Name: share.Name, Namespace: share.Namespace}] = "Shamir share"
+		}
+	}
+	if source := mp.Spec.Source; source != nil && source.SecretKeyRef != nil {
+		ref := source.SecretKeyRef
+		secrets[types.NamespacedName{Name: ref.Name, Namespace: ref.Namespace}] = "master password source"
+	}
+	if ref := deriverCredentialRef(mp.Spec.Deriver); ref != nil {
+		secrets[types.NamespacedName{Name: ref.Name, Namespace: ref.Namespace}] = "derivation backend credential"
+	}
+	return secrets
+}
+
+// adoptSecret checks that owner may write an existing secret according to the adoption
+// policy of ds. It returns true if the secret was taken over and needs to be updated,
+// or a conflictError if it may not be written
+func (r *DerivedSecretReconciler) adoptSecret(
+	ds *secretsv1beta1.DerivedSecret,
+	owner client.Object,
+	target secretsv1beta1.DerivedSecretTarget,
+	secret *corev1.Secret,
+) (bool, error) {
+	controller := metav1.GetControllerOf(secret)
+	if controller != nil && controller.UID == owner.GetUID() {
+		return false, nil
+	}
+
+	// Master password secrets created by the operator stay protected after their MasterPassword is gone
+	if secret.Labels[managedByLabel] == managedByValue {
+		return false, &conflictError{
+			reason: "ProtectedSecret",
+			message: fmt.Sprintf("secret %s/%s is a master password secret managed by the operator",
+				secret.Namespace, secret.Name),
+		}
+	}
+
+	policy := ds.Spec.AdoptionPolicy
+
+	// A shared secret is written in place without taking it over, unless another controller manages it
+	if target.Merges() {
+		if controller == nil || policy == secretsv1beta1.AdoptionPolicyForce {
+			return false, nil
+		}
+		return false, &conflictError{
+			reason: "SecretOwnedByOther",
+			message: fmt.Sprintf("secret %s/%s is owned by %s %s; set spec.adoptionPolicy to Force to merge into it",
+				secret.Namespace, secret.Name, controller.Kind, controller.Name),
+		}
+	}
+
+	adoptsUnowned := policy == secretsv1beta1.AdoptionPolicyIfUnowned || policy == secretsv1beta1.AdoptionPolicyForce
+	switch {
+	case controller == nil && adoptsUnowned:
+	case controller != nil && policy == secretsv1beta1.AdoptionPolicyForce:
+		// Drop the previous controller so that ours can be set
+		refs := secret.OwnerReferences[:0]
+		for _, ref := range secret.OwnerReferences {
+			if ref.UID != controller.UID {
+				refs = append(refs, ref)
+			}
+		}
+		secret.OwnerReferences = refs
+	case controller == nil:
+		return false, &conflictError{
+			reason: "SecretNotOwned",
+			message: fmt.Sprintf("secret %s/%s already exists and is not owned by %s %s; "+
+				"set spec.adoptionPolicy to IfUnowned or Force to take it over",
+				secret.Namespace, secret.Name, ownerKind(owner), owner.GetName()),
+		}
+	default:
+		return false, &conflictError{
+			reason: "SecretOwnedByOther",
+			message: fmt.Sprintf("secret %s/%s is owned by %s %s; set spec.adoptionPolicy to Force to take it over",
+				secret.Namespace, secret.Name, controller.Kind, controller.Name),
+		}
+	}
+
+	if err := controllerutil.SetControllerReference(owner, secret, r.Scheme); err != nil {
+		return false, fmt.Errorf("failed to set controller reference: %w", err)
+	}
+	return true, nil
+}
+
+// getDeriver returns the Deriver of the master password a key of the DerivedSecret references,
+// and its generation, which is 0 for NamespaceMasterPasswords.
+// It returns a forbiddenError if a MasterPassword does not allow the namespace
+func (r *DerivedSecretReconciler) getDeriver(
+	ctx context.Context,
+	ds *secretsv1beta1.DerivedSecret,
+	ref secretsv1beta1.MasterPasswordReference,
+) (crypto.Deriver, int, error) {
+	namespace := ds.Namespace
+	if ref.Kind == secretsv1beta1.KindNamespaceMasterPassword {
+		password, err := r.getNamespaceMasterPassword(ctx, ref.Name, namespace)
+		if err != nil {
+			return nil, 0, err
+		}
+		return crypto.NewArgon2idDeriver(password), 0, nil
+	}
+
+	// Fetch the MasterPassword resource
+	masterPassword := &secretsv1beta1.MasterPassword{}
+	if err := r.Get(ctx, types.NamespacedName{Name: ref.Name}, masterPassword); err != nil {
+		return nil, 0, fmt.Errorf("failed to get MasterPassword %s: %w", ref.Name, err)
+	}
+
+	// Check that the namespace may derive from this MasterPassword
+	if masterPassword.Spec.AllowedNamespaces != nil {
+		ns := &corev1.Namespace{}
+		if err := r.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+			return nil, 0, fmt.Errorf("failed to get namespace %s: %w", namespace, err)
+		}
+		allowed, err := masterPassword.Spec.AllowedNamespaces.Allows(ns)
+		if err != nil {
+			return nil, 0, fmt.Errorf("MasterPassword %s: %w", ref.Name, err)
+		}
+		if !allowed {
+			return nil, 0, &forbiddenError{namespace: namespace, masterPassword: ref.Name}
+		}
+	}
+
+	// Derivation backends have a single generation, the key they hold
+	if masterPassword.Spec.Deriver != nil {
+		d, err := r.Derivers.backendDeriver(ctx, r.Client, masterPassword)
+		if err != nil {
+			return nil, 0, err
+		}
+		return d, 1, nil
+	}
+
+	password, generation, err := r.getMasterPassword(ctx, ds, masterPassword)
+	if err != nil {
+		return nil, 0, err
+	}
+	return crypto.NewArgon2idDeriver(password), generation, nil
+}
+
+// getMasterPassword fetches the master password of a MasterPassword a key of the DerivedSecret
+// references, and its generation
+func (r *DerivedSecretReconciler) getMasterPassword(
+	ctx context.Context,
+	ds *secretsv1beta1.DerivedSecret,
+	masterPassword *secretsv1beta1.MasterPassword,
+) (string, int, error) {
+	// Master passwords with Memory storage have a single generation, held by the Vault
+	if masterPassword.Spec.InMemory() {
+		password, ok := r.Vault.Get(masterPassword.Name, masterPassword.UID)
+		if !ok {
+			return "", 0, &conflictError{
+				reason:  "Sealed",
+				message: fmt.Sprintf("MasterPassword %s is sealed", masterPassword.Name),
+			}
+		}
+		return checkMasterPasswordGeneration(masterPassword, password, 1)
+	}
+
+	// Shamir master passwords have a single generation, combined from the shares present now
+	if masterPassword.Spec.Shamir != nil {
+		password, _, err := readShamirMasterPassword(ctx, r.Client, masterPassword.Spec.Shamir)
+		if err != nil {
+			return "", 0, err
+		}
+		return checkMasterPasswordGeneration(masterPassword, password, 1)
+	}
+
+	// External master passwords have a single generation, read from their source
+	if masterPassword.Spec.Source != nil {
+		password, err := readSourceMasterPassword(ctx, r.Client, r.Sources, masterPassword.Spec.Source)
+		if err != nil {
+			return "", 0, err
+		}
+		return checkMasterPasswordGeneration(masterPassword, password, 1)
+	}
+
+	// A pinned generation wins over the one the MasterPassword's migration selects
+	generation := ds.PinnedGeneration()
+	if generation == 0 {
+		generation = masterPassword.GenerationFor(ds.Namespace)
+	}
+	password, generation, err := readMasterPasswordGeneration(ctx, r.Client, types.NamespacedName{
+		Name:      masterPasswordSecretName(masterPassword.Name, masterPassword.Spec.Secret),
+		Namespace: masterPasswordSecretNamespace(masterPassword.Spec.Secret, r.OperatorNamespace),
+	}, masterPassword.Spec.Secret.DataKey(), newMasterPasswordCodec(r.KMS, masterPassword), generation)
+	if err != nil {
+		return "", 0, err
+	}
+	// Generations older than the primary may predate a change of the format or the policy
+	if generation < masterPassword.Status.PrimaryGeneration {
+		return password, generation, nil
+	}
+	return checkMasterPasswordGeneration(masterPassword, password, generation)
+}
+
+// checkMasterPasswordGeneration returns the generation of a master password checked by
+// checkMasterPassword
+func checkMasterPasswordGeneration(
+	mp *secretsv1beta1.MasterPassword,
+	password string,
+	generation int,
+) (string, int, error) {
+	normalized, err := checkMasterPassword(mp, password)
+	if err != nil {
+		return "", 0, err
+	}
+	return normalized, generation, nil
+}
+
+// getNamespaceMasterPassword fetches the master password from a NamespaceMasterPassword.
+// Both the NamespaceMasterPassword and its secret are looked up in the given namespace,
+// so a DerivedSecret can only use NamespaceMasterPasswords from its own namespace
+func (r *DerivedSecretReconciler) getNamespaceMasterPassword(
+	ctx context.Context,
+	name, namespace string,
+) (string, error) {
+	nsmp := &secretsv1beta1.NamespaceMasterPassword{}
+	if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, nsmp); err != nil {
+		return "", fmt.Errorf("failed to get NamespaceMasterPassword %s/%s: %w", namespace, name, err)
+	}
+
+	return readMasterPassword(ctx, r.Client, types.NamespacedName{
+		Name:      masterPasswordSecretName(nsmp.Name, nsmp.Spec.Secret),
+		Namespace: namespace,
+	}, nsmp.Spec.Secret.DataKey(), masterPasswordCodec{})
 }
 
 // updateStatus updates the DerivedSecret status
-func (r *DerivedSecretReconciler) updateStatus(ctx context.Context, ds *secretsv1alpha1.DerivedSecret) error {
+func (r *DerivedSecretReconciler) updateStatus(ctx context.Context, ds *secretsv1beta1.DerivedSecret) error {
 	log := logf.FromContext(ctx)
 
-	ds.Status.SecretName = ds.Name
-	ds.Status.Ready = true
 	now := metav1.Now()
 	ds.Status.LastUpdated = &now
 
 	r.setCondition(ds, "Ready", metav1.ConditionTrue, "SecretReady", "Derived secret is ready")
+	for _, condType := range blockingConditions {
+		meta.RemoveStatusCondition(&ds.Status.Conditions, condType)
+	}
 
 	if err := r.Status().Update(ctx, ds); err != nil {
 		log.Error(err, "Failed to update status")
@@ -247,9 +916,31 @@ func (r *DerivedSecretReconciler) updateStatus(ctx context.Context, ds *secretsv
 	return nil
 }
 
+// reportBlocked records a condition that blocks the DerivedSecret until something it
+// depends on changes, marking it as not ready
+func (r *DerivedSecretReconciler) reportBlocked(
+	ctx context.Context,
+	ds *secretsv1beta1.DerivedSecret,
+	condType, reason, message string,
+) error {
+	// Only the condition blocking the DerivedSecret now is reported
+	for _, other := range blockingConditions {
+		if other != condType {
+			meta.RemoveStatusCondition(&ds.Status.Conditions, other)
+		}
+	}
+	r.setCondition(ds, condType, metav1.ConditionTrue, reason, message)
+	r.setCondition(ds, "Ready", metav1.ConditionFalse, condType, message)
+	if err := r.Status().Update(ctx, ds); err != nil {
+		logf.FromContext(ctx).Error(err, "Failed to update status")
+		return err
+	}
+	return nil
+}
+
 // setCondition sets a condition on the DerivedSecret
 func (r *DerivedSecretReconciler) setCondition(
-	ds *secretsv1alpha1.DerivedSecret,
+	ds *secretsv1beta1.DerivedSecret,
 	condType string,
 	status metav1.ConditionStatus,
 	reason, message string,
@@ -291,11 +982,235 @@ func equalMaps(a, b map[string]string) bool {
 	return true
 }
 
+// derivationContext returns the context a key of the DerivedSecret is derived with
+func derivationContext(ds *secretsv1beta1.DerivedSecret, keyName string, keySpec secretsv1beta1.DerivedKeySpec) string {
+	if keySpec.ContextOverride != "" {
+		return keySpec.ContextOverride
+	}
+	name := ds.Name
+	if ds.Spec.DerivationID != "" {
+		name = ds.Spec.DerivationID
+	}
+	return crypto.BuildContext(ds.Namespace, name, keyName)
+}
+
+// recordKeyVersions appends the current version of every key to the status history when it
+// changed, and drops the history of keys that were removed
+func recordKeyVersions(
+	ds *secretsv1beta1.DerivedSecret,
+	keyFingerprints map[string]string,
+	epochs map[string]int64,
+) {
+	history := make(map[string][]secretsv1beta1.KeyVersionStatus, len(ds.Spec.Keys))
+	now := metav1.Now()
+	for keyName, keySpec := range ds.Spec.Keys {
+		versions := slices.Clone(ds.Status.KeyVersions[keyName])
+		last := len(versions) - 1
+		// Versions recorded before fingerprints were introduced get the fingerprint of their value
+		if last >= 0 && versions[last].Fingerprint == "" && versions[last].Version == keySpec.Version &&
+			versions[last].Epoch == epochs[keyName] {
+			versions[last].Fingerprint = keyFingerprints[keyName]
+		}
+		if last < 0 || versions[last].Version != keySpec.Version ||
+			versions[last].Fingerprint != keyFingerprints[keyName] || versions[last].Epoch != epochs[keyName] {
+			versions = append(versions, secretsv1beta1.KeyVersionStatus{
+				Version:     keySpec.Version,
+				Fingerprint: keyFingerprints[keyName],
+				Epoch:       epochs[keyName],
+				AppliedAt:   now,
+			})
+		}
+		if len(versions) > secretsv1beta1.MaxKeyVersionHistory {
+			versions = versions[len(versions)-secretsv1beta1.MaxKeyVersionHistory:]
+		}
+		history[keyName] = versions
+	}
+	ds.Status.KeyVersions = history
+}
+
+// rotationRequeueAfter returns the time until the next epoch boundary or overlap end of the
+// keys with a rotation schedule, or 0 if no key rotates
+func rotationRequeueAfter(keys map[string]secretsv1beta1.DerivedKeySpec, now time.Time) time.Duration {
+	var next time.Duration
+	for _, keySpec := range keys {
+		if keySpec.Rotation == nil {
+			continue
+		}
+		interval := keySpec.Rotation.Interval.Duration
+		start := crypto.EpochStart(crypto.RotationEpoch(now, interval), interval)
+		after := start.Add(interval).Sub(now)
+		if overlapEnd := start.Add(keySpec.Rotation.OverlapDuration()).Sub(now); overlapEnd > 0 && overlapEnd < after {
+			after = overlapEnd
+		}
+		if next == 0 || after < next {
+			next = after
+		}
+	}
+	return next
+}
+
+// now returns the current time of the reconciler's clock
+func (r *DerivedSecretReconciler) now() time.Time {
+	if r.Clock == nil {
+		return time.Now()
+	}
+	return r.Clock.Now()
+}
+
+// ownerKind returns the kind of the object owning derived secrets, for messages
+func ownerKind(owner client.Object) string {
+	switch owner.(type) {
+	case *secretsv1beta1.ClusterDerivedSecret:
+		return "ClusterDerivedSecret"
+	default:
+		return "DerivedSecret"
+	}
+}
+
+// mergeMaps returns a copy of base with the entries of overrides applied
+func mergeMaps(base, overrides map[string]string) map[string]string {
+	out := make(map[string]string, len(base)+len(overrides))
+	maps.Copy(out, base)
+	maps.Copy(out, overrides)
+	return out
+}
+
+// mergeSecretData returns a copy of data where the previously managed keys are replaced by derived
+func mergeSecretData(data map[string][]byte, previous []string, derived map[string][]byte) map[string][]byte {
+	out := maps.Clone(data)
+	if out == nil {
+		out = make(map[string][]byte, len(derived))
+	}
+	for _, key := range previous {
+		delete(out, key)
+	}
+	maps.Copy(out, derived)
+	return out
+}
+
+// managedKeys returns the data keys recorded in the managed-keys annotation of the secret
+func managedKeys(secret *corev1.Secret) []string {
+	value := secret.Annotations[secretsv1beta1.ManagedKeysAnnotation]
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// formatManagedKeys returns the managed-keys annotation value for the given secret data
+func formatManagedKeys(data map[string][]byte) string {
+	return strings.Join(slices.Sorted(maps.Keys(data)), ",")
+}
+
+// findDerivedSecretsForMasterPassword returns an event handler that maps MasterPassword or
+// NamespaceMasterPassword events to reconcile requests for the DerivedSecrets using it
+func (r *DerivedSecretReconciler) findDerivedSecretsForMasterPassword(
+	kind secretsv1beta1.MasterPasswordKind,
+) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []ctrl.Request {
+		// A NamespaceMasterPassword is only used from its own namespace; a cluster-scoped
+		// MasterPassword has no namespace, so DerivedSecrets in all namespaces are listed
+		dsList := &secretsv1beta1.DerivedSecretList{}
+		if err := r.List(ctx, dsList, client.InNamespace(obj.GetNamespace())); err != nil {
+			return nil
+		}
+
+		want := secretsv1beta1.MasterPasswordReference{Kind: kind, Name: obj.GetName()}
+		var requests []ctrl.Request
+		for _, ds := range dsList.Items {
+			for _, keySpec := range ds.Spec.Keys {
+				if ds.Spec.MasterPasswordRefFor(keySpec) == want {
+					requests = append(requests, ctrl.Request{
+						NamespacedName: types.NamespacedName{Name: ds.Name, Namespace: ds.Namespace},
+					})
+					break
+				}
+			}
+		}
+
+		return requests
+	})
+}
+
+// findDerivedSecretsForNamespace returns an event handler that maps Namespace events
+// to reconcile requests for the DerivedSecrets in that namespace
+func (r *DerivedSecretReconciler) findDerivedSecretsForNamespace() handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []ctrl.Request {
+		dsList := &secretsv1beta1.DerivedSecretList{}
+		if err := r.List(ctx, dsList, client.InNamespace(obj.GetName())); err != nil {
+			return nil
+		}
+
+		requests := make([]ctrl.Request, 0, len(dsList.Items))
+		for _, ds := range dsList.Items {
+			requests = append(requests, ctrl.Request{
+				NamespacedName: types.NamespacedName{Name: ds.Name, Namespace: ds.Namespace},
+			})
+		}
+
+		return requests
+	})
+}
+
+// targetSecretNameField indexes DerivedSecrets by the names of the secrets they write
+const targetSecretNameField = ".spec.targets.name"
+
+// targetSecretNames returns the names of the secrets written by a DerivedSecret, including
+// those of removed targets that still have to be released, for the targetSecretNameField index
+func targetSecretNames(obj client.Object) []string {
+	ds := obj.(*secretsv1beta1.DerivedSecret)
+	var names []string
+	for _, target := range ds.TargetSecrets() {
+		names = append(names, target.Name)
+	}
+	for _, target := range ds.Status.Targets {
+		if !slices.Contains(names, target.Name) {
+			names = append(names, target.Name)
+		}
+	}
+	return names
+}
+
+// findDerivedSecretsForSecret returns an event handler that maps Secret events to the
+// DerivedSecrets writing them, including Secrets they do not own yet, so that
+// conflicts are retried once the Secret is deleted or changes owner
+func (r *DerivedSecretReconciler) findDerivedSecretsForSecret() handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []ctrl.Request {
+		dsList := &secretsv1beta1.DerivedSecretList{}
+		if err := r.List(ctx, dsList,
+			client.InNamespace(obj.GetNamespace()),
+			client.MatchingFields{targetSecretNameField: obj.GetName()},
+		); err != nil {
+			return nil
+		}
+
+		requests := make([]ctrl.Request, 0, len(dsList.Items))
+		for _, ds := range dsList.Items {
+			requests = append(requests, ctrl.Request{
+				NamespacedName: types.NamespacedName{Name: ds.Name, Namespace: ds.Namespace},
+			})
+		}
+		return requests
+	})
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *DerivedSecretReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(),
+		&secretsv1beta1.DerivedSecret{}, targetSecretNameField, targetSecretNames); err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&secretsv1alpha1.DerivedSecret{}).
-		Owns(&corev1.Secret{}).
+		For(&secretsv1beta1.DerivedSecret{}).
+		Watches(&corev1.Secret{}, r.findDerivedSecretsForSecret()).
+		Watches(&secretsv1beta1.MasterPassword{},
+			r.findDerivedSecretsForMasterPassword(secretsv1beta1.KindMasterPassword)).
+		Watches(&secretsv1beta1.NamespaceMasterPassword{},
+			r.findDerivedSecretsForMasterPassword(secretsv1beta1.KindNamespaceMasterPassword)).
+		// Namespace labels decide whether a MasterPassword namespaceSelector matches
+		Watches(&corev1.Namespace{}, r.findDerivedSecretsForNamespace(),
+			builder.WithPredicates(predicate.LabelChangedPredicate{})).
 		Named("derivedsecret").
 		Complete(r)
 }
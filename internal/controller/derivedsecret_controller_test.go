@@ -18,17 +18,20 @@ package controller
 
 import (
 	"context"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/types"
+	clocktesting "k8s.io/utils/clock/testing"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
-	secretsv1alpha1 "github.com/oleksiyp/derived-secret-operator/api/v1alpha1"
+	secretsv1beta1 "github.com/oleksiyp/derived-secret-operator/api/v1beta1"
 )
 
 var _ = Describe("DerivedSecret Controller", func() {
@@ -42,7 +45,7 @@ var _ = Describe("DerivedSecret Controller", func() {
 			Name:      resourceName,
 			Namespace: "default",
 		}
-		derivedsecret := &secretsv1alpha1.DerivedSecret{}
+		derivedsecret := &secretsv1beta1.DerivedSecret{}
 
 		BeforeEach(func() {
 			By("creating the MasterPassword and its secret")
@@ -64,12 +67,12 @@ var _ = Describe("DerivedSecret Controller", func() {
 				Expect(k8sClient.Create(ctx, masterPasswordSecret)).To(Succeed())
 			}
 
-			masterPassword := &secretsv1alpha1.MasterPassword{
+			masterPassword := &secretsv1beta1.MasterPassword{
 				ObjectMeta: metav1.ObjectMeta{
 					Name: masterPasswordName,
 				},
 			}
-			err = k8sClient.Get(ctx, types.NamespacedName{Name: masterPasswordName}, &secretsv1alpha1.MasterPassword{})
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: masterPasswordName}, &secretsv1beta1.MasterPassword{})
 			if err != nil && errors.IsNotFound(err) {
 				Expect(k8sClient.Create(ctx, masterPassword)).To(Succeed())
 			}
@@ -77,15 +80,15 @@ var _ = Describe("DerivedSecret Controller", func() {
 			By("creating the custom resource for the Kind DerivedSecret")
 			err = k8sClient.Get(ctx, typeNamespacedName, derivedsecret)
 			if err != nil && errors.IsNotFound(err) {
-				resource := &secretsv1alpha1.DerivedSecret{
+				resource := &secretsv1beta1.DerivedSecret{
 					ObjectMeta: metav1.ObjectMeta{
 						Name:      resourceName,
 						Namespace: "default",
 					},
-					Spec: secretsv1alpha1.DerivedSecretSpec{
-						Keys: map[string]secretsv1alpha1.DerivedKeySpec{
+					Spec: secretsv1beta1.DerivedSecretSpec{
+						Keys: map[string]secretsv1beta1.DerivedKeySpec{
 							"password": {
-								Type: secretsv1alpha1.SecretTypePassword,
+								Type: secretsv1beta1.SecretTypePassword,
 							},
 						},
 					},
@@ -95,12 +98,18 @@ var _ = Describe("DerivedSecret Controller", func() {
 		})
 
 		AfterEach(func() {
-			resource := &secretsv1alpha1.DerivedSecret{}
+			resource := &secretsv1beta1.DerivedSecret{}
 			err := k8sClient.Get(ctx, typeNamespacedName, resource)
 			Expect(err).NotTo(HaveOccurred())
 
 			By("Cleanup the specific resource instance DerivedSecret")
 			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+
+			// envtest runs no garbage collector, so the derived secret is removed explicitly
+			secret := &corev1.Secret{}
+			if err := k8sClient.Get(ctx, typeNamespacedName, secret); err == nil {
+				Expect(k8sClient.Delete(ctx, secret)).To(Succeed())
+			}
 		})
 		It("should successfully reconcile the resource", func() {
 			By("Reconciling the created resource")
@@ -129,5 +138,580 @@ var _ = Describe("DerivedSecret Controller", func() {
 			Expect(secret.Data).To(HaveKey("password"))
 			Expect(secret.Data["password"]).To(HaveLen(26)) // password type is 26 chars
 		})
+
+		It("should set a Forbidden condition when the namespace is not allowed", func() {
+			controllerReconciler := &DerivedSecretReconciler{
+				Client:            k8sClient,
+				Scheme:            k8sClient.Scheme(),
+				OperatorNamespace: "default",
+			}
+
+			By("Restricting the MasterPassword to another namespace")
+			masterPassword := &secretsv1beta1.MasterPassword{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: masterPasswordName}, masterPassword)).To(Succeed())
+			masterPassword.Spec.AllowedNamespaces = &secretsv1beta1.AllowedNamespaces{Names: []string{"other"}}
+			Expect(k8sClient.Update(ctx, masterPassword)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: masterPasswordName}, masterPassword)).To(Succeed())
+				masterPassword.Spec.AllowedNamespaces = nil
+				Expect(k8sClient.Update(ctx, masterPassword)).To(Succeed())
+			})
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, derivedsecret)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(derivedsecret.Status.Conditions, "Forbidden")).To(BeTrue())
+			Expect(meta.IsStatusConditionFalse(derivedsecret.Status.Conditions, "Ready")).To(BeTrue())
+
+			By("Allowing the namespace by label")
+			masterPassword.Spec.AllowedNamespaces.NamespaceSelector = &metav1.LabelSelector{
+				MatchLabels: map[string]string{"kubernetes.io/metadata.name": "default"},
+			}
+			Expect(k8sClient.Update(ctx, masterPassword)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, derivedsecret)).To(Succeed())
+			Expect(meta.FindStatusCondition(derivedsecret.Status.Conditions, "Forbidden")).To(BeNil())
+			Expect(meta.IsStatusConditionTrue(derivedsecret.Status.Conditions, "Ready")).To(BeTrue())
+		})
+
+		It("should only take over an existing Secret as allowed by the adoption policy", func() {
+			controllerReconciler := &DerivedSecretReconciler{
+				Client:            k8sClient,
+				Scheme:            k8sClient.Scheme(),
+				OperatorNamespace: "default",
+			}
+
+			By("Creating a Secret not owned by the DerivedSecret")
+			existing := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      resourceName,
+					Namespace: "default",
+				},
+				Data: map[string][]byte{"password": []byte("hand-written")},
+			}
+			Expect(k8sClient.Create(ctx, existing)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, derivedsecret)).To(Succeed())
+			conflict := meta.FindStatusCondition(derivedsecret.Status.Conditions, "Conflict")
+			Expect(conflict).NotTo(BeNil())
+			Expect(conflict.Reason).To(Equal("SecretNotOwned"))
+			Expect(meta.IsStatusConditionFalse(derivedsecret.Status.Conditions, "Ready")).To(BeTrue())
+
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, secret)).To(Succeed())
+			Expect(secret.Data["password"]).To(Equal([]byte("hand-written")))
+
+			By("Allowing the DerivedSecret to adopt unowned Secrets")
+			derivedsecret.Spec.AdoptionPolicy = secretsv1beta1.AdoptionPolicyIfUnowned
+			Expect(k8sClient.Update(ctx, derivedsecret)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, derivedsecret)).To(Succeed())
+			Expect(meta.FindStatusCondition(derivedsecret.Status.Conditions, "Conflict")).To(BeNil())
+			Expect(meta.IsStatusConditionTrue(derivedsecret.Status.Conditions, "Ready")).To(BeTrue())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, secret)).To(Succeed())
+			Expect(metav1.IsControlledBy(secret, derivedsecret)).To(BeTrue())
+			Expect(secret.Data["password"]).NotTo(Equal([]byte("hand-written")))
+		})
+
+		It("should only manage its own keys in Merge mode", func() {
+			controllerReconciler := &DerivedSecretReconciler{
+				Client:            k8sClient,
+				Scheme:            k8sClient.Scheme(),
+				OperatorNamespace: "default",
+			}
+
+			By("Creating a shared Secret with a key of its own")
+			shared := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      resourceName,
+					Namespace: "default",
+				},
+				Data: map[string][]byte{"chart-key": []byte("from-chart")},
+			}
+			Expect(k8sClient.Create(ctx, shared)).To(Succeed())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, derivedsecret)).To(Succeed())
+			derivedsecret.Spec.Target = &secretsv1beta1.DerivedSecretTarget{MergeMode: secretsv1beta1.MergeModeMerge}
+			Expect(k8sClient.Update(ctx, derivedsecret)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, secret)).To(Succeed())
+			Expect(secret.Data).To(HaveKeyWithValue("chart-key", []byte("from-chart")))
+			Expect(secret.Data).To(HaveKey("password"))
+			Expect(secret.Annotations).To(HaveKeyWithValue(secretsv1beta1.ManagedKeysAnnotation, "password"))
+			Expect(metav1.GetControllerOf(secret)).To(BeNil())
+
+			By("Replacing the derived key")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, derivedsecret)).To(Succeed())
+			derivedsecret.Spec.Keys = map[string]secretsv1beta1.DerivedKeySpec{
+				"token": {Type: secretsv1beta1.SecretTypePassword},
+			}
+			Expect(k8sClient.Update(ctx, derivedsecret)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, secret)).To(Succeed())
+			Expect(secret.Data).To(HaveKeyWithValue("chart-key", []byte("from-chart")))
+			Expect(secret.Data).To(HaveKey("token"))
+			Expect(secret.Data).NotTo(HaveKey("password"))
+			Expect(secret.Annotations).To(HaveKeyWithValue(secretsv1beta1.ManagedKeysAnnotation, "token"))
+		})
+
+		It("should write the derived keys to every target", func() {
+			controllerReconciler := &DerivedSecretReconciler{
+				Client:            k8sClient,
+				Scheme:            k8sClient.Scheme(),
+				OperatorNamespace: "default",
+			}
+
+			appName := types.NamespacedName{Name: "app-db", Namespace: "default"}
+			migratorName := types.NamespacedName{Name: "migrator-db", Namespace: "default"}
+			DeferCleanup(func() {
+				for _, name := range []types.NamespacedName{appName, migratorName} {
+					secret := &corev1.Secret{}
+					if err := k8sClient.Get(ctx, name, secret); err == nil {
+						Expect(k8sClient.Delete(ctx, secret)).To(Succeed())
+					}
+				}
+			})
+
+			By("Adding two named targets")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, derivedsecret)).To(Succeed())
+			derivedsecret.Spec.Targets = []secretsv1beta1.DerivedSecretTarget{
+				{Name: appName.Name},
+				{
+					Name: migratorName.Name,
+					Template: &secretsv1beta1.SecretTemplate{
+						Metadata:  secretsv1beta1.SecretTemplateMetadata{Labels: map[string]string{"role": "migrator"}},
+						Immutable: true,
+					},
+				},
+			}
+			Expect(k8sClient.Update(ctx, derivedsecret)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			appSecret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, appName, appSecret)).To(Succeed())
+			migratorSecret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, migratorName, migratorSecret)).To(Succeed())
+			Expect(migratorSecret.Data).To(Equal(appSecret.Data))
+			Expect(migratorSecret.Labels).To(HaveKeyWithValue("role", "migrator"))
+			Expect(migratorSecret.Immutable).NotTo(BeNil())
+			Expect(*migratorSecret.Immutable).To(BeTrue())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, derivedsecret)).To(Succeed())
+			Expect(derivedsecret.Status.Targets).To(ConsistOf(
+				secretsv1beta1.TargetStatus{Name: appName.Name, Ready: true},
+				secretsv1beta1.TargetStatus{Name: migratorName.Name, Ready: true},
+			))
+
+			By("Removing the migrator target")
+			derivedsecret.Spec.Targets = derivedsecret.Spec.Targets[:1]
+			Expect(k8sClient.Update(ctx, derivedsecret)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			err = k8sClient.Get(ctx, migratorName, &corev1.Secret{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, derivedsecret)).To(Succeed())
+			Expect(derivedsecret.Status.Targets).To(ConsistOf(
+				secretsv1beta1.TargetStatus{Name: appName.Name, Ready: true},
+			))
+		})
+
+		It("should keep derived values when renamed with a derivation ID", func() {
+			controllerReconciler := &DerivedSecretReconciler{
+				Client:            k8sClient,
+				Scheme:            k8sClient.Scheme(),
+				OperatorNamespace: "default",
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			original := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, original)).To(Succeed())
+
+			By("Creating a renamed DerivedSecret with the original name as derivation ID")
+			renamedName := types.NamespacedName{Name: "renamed-resource", Namespace: "default"}
+			renamed := &secretsv1beta1.DerivedSecret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      renamedName.Name,
+					Namespace: renamedName.Namespace,
+				},
+				Spec: secretsv1beta1.DerivedSecretSpec{
+					DerivationID: resourceName,
+					Keys: map[string]secretsv1beta1.DerivedKeySpec{
+						"password": {Type: secretsv1beta1.SecretTypePassword},
+						"legacy": {
+							Type:            secretsv1beta1.SecretTypePassword,
+							ContextOverride: "default/" + resourceName + "/password",
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, renamed)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, renamed)).To(Succeed())
+				secret := &corev1.Secret{}
+				if err := k8sClient.Get(ctx, renamedName, secret); err == nil {
+					Expect(k8sClient.Delete(ctx, secret)).To(Succeed())
+				}
+			})
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: renamedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, renamedName, secret)).To(Succeed())
+			Expect(secret.Data["password"]).To(Equal(original.Data["password"]))
+			Expect(secret.Data["legacy"]).To(Equal(original.Data["password"]))
+		})
+
+		It("should rotate a single key by bumping its version", func() {
+			controllerReconciler := &DerivedSecretReconciler{
+				Client:            k8sClient,
+				Scheme:            k8sClient.Scheme(),
+				OperatorNamespace: "default",
+			}
+			reconcileWithVersion := func(version int) []byte {
+				Expect(k8sClient.Get(ctx, typeNamespacedName, derivedsecret)).To(Succeed())
+				derivedsecret.Spec.Keys["password"] = secretsv1beta1.DerivedKeySpec{
+					Type:    secretsv1beta1.SecretTypePassword,
+					Version: version,
+				}
+				Expect(k8sClient.Update(ctx, derivedsecret)).To(Succeed())
+
+				_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+					NamespacedName: typeNamespacedName,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				secret := &corev1.Secret{}
+				Expect(k8sClient.Get(ctx, typeNamespacedName, secret)).To(Succeed())
+				return secret.Data["password"]
+			}
+
+			original := reconcileWithVersion(0)
+			rotated := reconcileWithVersion(1)
+			Expect(rotated).NotTo(Equal(original))
+			Expect(reconcileWithVersion(0)).To(Equal(original))
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, derivedsecret)).To(Succeed())
+			versions := derivedsecret.Status.KeyVersions["password"]
+			Expect(versions).To(HaveLen(3))
+			Expect(versions[1].Version).To(Equal(1))
+			Expect(versions[1].Fingerprint).NotTo(Equal(versions[0].Fingerprint))
+			Expect(versions[2].Fingerprint).To(Equal(versions[0].Fingerprint))
+		})
+
+		It("should rotate a scheduled key at every epoch boundary", func() {
+			interval := 24 * time.Hour
+			overlap := time.Hour
+			fakeClock := clocktesting.NewFakePassiveClock(time.Unix(1700000000, 0).Truncate(interval).Add(30 * time.Minute))
+			controllerReconciler := &DerivedSecretReconciler{
+				Client:            k8sClient,
+				Scheme:            k8sClient.Scheme(),
+				OperatorNamespace: "default",
+				Clock:             fakeClock,
+			}
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, derivedsecret)).To(Succeed())
+			derivedsecret.Spec.Keys["password"] = secretsv1beta1.DerivedKeySpec{
+				Type: secretsv1beta1.SecretTypePassword,
+				Rotation: &secretsv1beta1.RotationSpec{
+					Interval: metav1.Duration{Duration: interval},
+					Overlap:  &metav1.Duration{Duration: overlap},
+				},
+			}
+			Expect(k8sClient.Update(ctx, derivedsecret)).To(Succeed())
+
+			reconcileAt := func(now time.Time) (*corev1.Secret, time.Duration) {
+				fakeClock.SetTime(now)
+				result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+					NamespacedName: typeNamespacedName,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				secret := &corev1.Secret{}
+				Expect(k8sClient.Get(ctx, typeNamespacedName, secret)).To(Succeed())
+				return secret, result.RequeueAfter
+			}
+
+			By("Publishing the previous value during the overlap window")
+			start := fakeClock.Now()
+			secret, requeueAfter := reconcileAt(start)
+			Expect(secret.Data).To(HaveKey("password.previous"))
+			Expect(requeueAfter).To(Equal(30 * time.Minute))
+			current := secret.Data["password"]
+
+			By("Dropping the previous value after the overlap window")
+			secret, requeueAfter = reconcileAt(start.Add(time.Hour))
+			Expect(secret.Data).NotTo(HaveKey("password.previous"))
+			Expect(secret.Data["password"]).To(Equal(current))
+			Expect(requeueAfter).To(Equal(interval - 90*time.Minute))
+
+			By("Rotating at the next boundary")
+			secret, _ = reconcileAt(start.Add(interval))
+			Expect(secret.Data["password"]).NotTo(Equal(current))
+			Expect(secret.Data["password.previous"]).To(Equal(current))
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, derivedsecret)).To(Succeed())
+			versions := derivedsecret.Status.KeyVersions["password"]
+			Expect(versions[len(versions)-1].Epoch).To(Equal(versions[len(versions)-2].Epoch + 1))
+		})
+
+		It("should reject key names reserved for rotations", func() {
+			for _, suffix := range []string{secretsv1beta1.PreviousKeySuffix, secretsv1beta1.NextKeySuffix} {
+				name := "password" + suffix
+				reserved := &secretsv1beta1.DerivedSecret{
+					ObjectMeta: metav1.ObjectMeta{Name: "reserved-keys", Namespace: "default"},
+					Spec: secretsv1beta1.DerivedSecretSpec{
+						Keys: map[string]secretsv1beta1.DerivedKeySpec{
+							name: {Type: secretsv1beta1.SecretTypePassword},
+						},
+					},
+				}
+				err := k8sClient.Create(ctx, reserved)
+				Expect(errors.IsInvalid(err)).To(BeTrue(), "creating key %s: %v", name, err)
+			}
+		})
+
+		It("should reject contexts that could collide with a versioned or epoch context", func() {
+			for _, spec := range []secretsv1beta1.DerivedSecretSpec{
+				{Keys: map[string]secretsv1beta1.DerivedKeySpec{
+					"password": {Type: secretsv1beta1.SecretTypePassword, ContextOverride: "default/app/password@v1"},
+				}},
+				{Keys: map[string]secretsv1beta1.DerivedKeySpec{
+					"password": {Type: secretsv1beta1.SecretTypePassword, ContextOverride: "default/app/password#42"},
+				}},
+				{DerivationID: "app#42", Keys: map[string]secretsv1beta1.DerivedKeySpec{
+					"password": {Type: secretsv1beta1.SecretTypePassword},
+				}},
+			} {
+				colliding := &secretsv1beta1.DerivedSecret{
+					ObjectMeta: metav1.ObjectMeta{Name: "colliding-context", Namespace: "default"},
+					Spec:       spec,
+				}
+				err := k8sClient.Create(ctx, colliding)
+				Expect(errors.IsInvalid(err)).To(BeTrue(), "creating %+v: %v", spec, err)
+			}
+		})
+
+		It("should stage, promote and finalize a rotation", func() {
+			controllerReconciler := &DerivedSecretReconciler{
+				Client:            k8sClient,
+				Scheme:            k8sClient.Scheme(),
+				OperatorNamespace: "default",
+			}
+			reconcileStep := func(action secretsv1beta1.RotationAction) *corev1.Secret {
+				Expect(k8sClient.Get(ctx, typeNamespacedName, derivedsecret)).To(Succeed())
+				if action != "" {
+					if derivedsecret.Annotations == nil {
+						derivedsecret.Annotations = map[string]string{}
+					}
+					derivedsecret.Annotations[secretsv1beta1.RotationAnnotation] = string(action)
+					Expect(k8sClient.Update(ctx, derivedsecret)).To(Succeed())
+				}
+
+				_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+					NamespacedName: typeNamespacedName,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(k8sClient.Get(ctx, typeNamespacedName, derivedsecret)).To(Succeed())
+				secret := &corev1.Secret{}
+				Expect(k8sClient.Get(ctx, typeNamespacedName, secret)).To(Succeed())
+				return secret
+			}
+			rotationReason := func() string {
+				condition := meta.FindStatusCondition(derivedsecret.Status.Conditions, "Rotation")
+				Expect(condition).NotTo(BeNil())
+				return condition.Reason
+			}
+
+			current := reconcileStep("").Data["password"]
+
+			By("Rejecting a promote before anything is staged")
+			secret := reconcileStep(secretsv1beta1.RotationActionPromote)
+			Expect(rotationReason()).To(Equal("InvalidRotation"))
+			Expect(secret.Data["password"]).To(Equal(current))
+
+			By("Staging the next value")
+			secret = reconcileStep(secretsv1beta1.RotationActionStage)
+			Expect(rotationReason()).To(Equal("Staged"))
+			Expect(secret.Data["password"]).To(Equal(current))
+			next := secret.Data["password.next"]
+			Expect(next).NotTo(BeEmpty())
+			Expect(next).NotTo(Equal(current))
+
+			By("Promoting the next value")
+			secret = reconcileStep(secretsv1beta1.RotationActionPromote)
+			Expect(rotationReason()).To(Equal("Promoted"))
+			Expect(derivedsecret.Spec.Keys["password"].Version).To(Equal(1))
+			Expect(secret.Data["password"]).To(Equal(next))
+			Expect(secret.Data["password.previous"]).To(Equal(current))
+			Expect(secret.Data).NotTo(HaveKey("password.next"))
+
+			By("Finalizing the rotation")
+			secret = reconcileStep(secretsv1beta1.RotationActionFinalize)
+			Expect(rotationReason()).To(Equal("Finalized"))
+			Expect(derivedsecret.Status.StagedRotation).To(BeNil())
+			Expect(secret.Data["password"]).To(Equal(next))
+			Expect(secret.Data).NotTo(HaveKey("password.previous"))
+
+			By("Keeping the promoted value on later reconciles")
+			Expect(reconcileStep("").Data["password"]).To(Equal(next))
+		})
+
+		It("should never overwrite a master password secret", func() {
+			controllerReconciler := &DerivedSecretReconciler{
+				Client:            k8sClient,
+				Scheme:            k8sClient.Scheme(),
+				OperatorNamespace: "default",
+			}
+
+			By("Creating a DerivedSecret targeting the master password secret")
+			protectedName := types.NamespacedName{Name: masterPasswordName + "-mp", Namespace: "default"}
+			protected := &secretsv1beta1.DerivedSecret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      protectedName.Name,
+					Namespace: protectedName.Namespace,
+				},
+				Spec: secretsv1beta1.DerivedSecretSpec{
+					AdoptionPolicy: secretsv1beta1.AdoptionPolicyForce,
+					Keys: map[string]secretsv1beta1.DerivedKeySpec{
+						masterPasswordKey: {Type: secretsv1beta1.SecretTypePassword},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, protected)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, protected)).To(Succeed())
+			})
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: protectedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, protectedName, protected)).To(Succeed())
+			conflict := meta.FindStatusCondition(protected.Status.Conditions, "Conflict")
+			Expect(conflict).NotTo(BeNil())
+			Expect(conflict.Reason).To(Equal("ProtectedSecret"))
+
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, protectedName, secret)).To(Succeed())
+			Expect(secret.Data[masterPasswordKey]).To(Equal([]byte("test-master-password-for-testing-only")))
+		})
+
+		It("should never overwrite a Secret a MasterPassword depends on", func() {
+			controllerReconciler := &DerivedSecretReconciler{
+				Client:            k8sClient,
+				Scheme:            k8sClient.Scheme(),
+				OperatorNamespace: "default",
+			}
+
+			By("Creating a Shamir MasterPassword whose shares are unlabelled Secrets")
+			shamirMP := &secretsv1beta1.MasterPassword{
+				ObjectMeta: metav1.ObjectMeta{Name: "protected-shamir"},
+				Spec: secretsv1beta1.MasterPasswordSpec{
+					Shamir: &secretsv1beta1.ShamirSpec{
+						Threshold: 2,
+						Shares: []secretsv1beta1.ShareReference{
+							{Name: "protected-share-1", Namespace: "default"},
+							{Name: "protected-share-2", Namespace: "default"},
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, shamirMP)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, shamirMP)).To(Succeed())
+			})
+			for _, name := range []string{"protected-share-1", sealingKeyName} {
+				secret := &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+					Data:       map[string][]byte{"share": []byte("original")},
+				}
+				Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+				DeferCleanup(func() {
+					Expect(k8sClient.Delete(ctx, secret)).To(Succeed())
+				})
+			}
+
+			By("Forcing a DerivedSecret onto the share and the sealing key")
+			protected := &secretsv1beta1.DerivedSecret{
+				ObjectMeta: metav1.ObjectMeta{Name: "protected-targets", Namespace: "default"},
+				Spec: secretsv1beta1.DerivedSecretSpec{
+					AdoptionPolicy: secretsv1beta1.AdoptionPolicyForce,
+					Targets: []secretsv1beta1.DerivedSecretTarget{
+						{Name: "protected-share-1"},
+						{Name: sealingKeyName},
+					},
+					Keys: map[string]secretsv1beta1.DerivedKeySpec{
+						"share": {Type: secretsv1beta1.SecretTypePassword},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, protected)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, protected)).To(Succeed())
+			})
+			protectedName := types.NamespacedName{Name: protected.Name, Namespace: protected.Namespace}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: protectedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, protectedName, protected)).To(Succeed())
+			Expect(protected.Status.Targets).To(HaveLen(2))
+			for _, target := range protected.Status.Targets {
+				Expect(target.Ready).To(BeFalse())
+				Expect(target.Reason).To(Equal("ProtectedSecret"))
+
+				secret := &corev1.Secret{}
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: target.Name, Namespace: "default"}, secret)).
+					To(Succeed())
+				Expect(secret.Data["share"]).To(Equal([]byte("original")))
+			}
+		})
 	})
 })
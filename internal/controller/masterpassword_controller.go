@@ -18,7 +18,12 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -30,14 +35,12 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 
-	secretsv1alpha1 "github.com/oleksiyp/derived-secret-operator/api/v1alpha1"
+	secretsv1beta1 "github.com/oleksiyp/derived-secret-operator/api/v1beta1"
 	"github.com/oleksiyp/derived-secret-operator/internal/crypto"
-)
-
-const (
-	masterPasswordKey = "masterPassword"
-	defaultLength     = 86
+	"github.com/oleksiyp/derived-secret-operator/internal/kms"
+	"github.com/oleksiyp/derived-secret-operator/internal/unseal"
 )
 
 // MasterPasswordReconciler reconciles a MasterPassword object
@@ -45,12 +48,22 @@ type MasterPasswordReconciler struct {
 	client.Client
 	Scheme            *runtime.Scheme
 	OperatorNamespace string
+	// Vault holds the master passwords with Memory storage; nil keeps them sealed
+	Vault *unseal.Vault
+	// KMS encrypts the master passwords of MasterPasswords with KMS encryption; nil if none is configured
+	KMS *kms.Client
+	// Derivers caches the derivers of MasterPasswords with a derivation backend; nil builds one per use
+	Derivers *DeriverCache
+	// Sources restricts the files and environment variables MasterPassword sources may read
+	Sources SourcePolicy
 }
 
 // +kubebuilder:rbac:groups=secrets.oleksiyp.dev,resources=masterpasswords,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=secrets.oleksiyp.dev,resources=masterpasswords/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update
 // +kubebuilder:rbac:groups=secrets.oleksiyp.dev,resources=derivedsecrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=secrets.oleksiyp.dev,resources=clusterderivedsecrets,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -58,11 +71,12 @@ func (r *MasterPasswordReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 	log := logf.FromContext(ctx)
 
 	// Fetch the MasterPassword instance
-	masterPassword := &secretsv1alpha1.MasterPassword{}
+	masterPassword := &secretsv1beta1.MasterPassword{}
 	err := r.Get(ctx, req.NamespacedName, masterPassword)
 	if err != nil {
 		if apierrors.IsNotFound(err) {
 			log.Info("MasterPassword resource not found. Ignoring since object must be deleted")
+			r.Derivers.Forget(req.Name)
 			return ctrl.Result{}, nil
 		}
 		log.Error(err, "Failed to get MasterPassword")
@@ -71,6 +85,15 @@ func (r *MasterPasswordReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 
 	// Reconcile the secret
 	if err := r.reconcileSecret(ctx, masterPassword); err != nil {
+		// Conflicts are not retried; changes to the MasterPassword or its secrets trigger a new reconcile
+		var conflict *conflictError
+		if errors.As(err, &conflict) {
+			log.Info("Refusing to reconcile secret", "reason", conflict.reason, "message", conflict.message)
+			r.setCondition(masterPassword, "Ready", metav1.ConditionFalse, conflict.reason, conflict.message)
+			r.reportWeakMasterPassword(masterPassword, conflict)
+			return ctrl.Result{}, r.Status().Update(ctx, masterPassword)
+		}
+
 		log.Error(err, "Failed to reconcile secret")
 		r.setCondition(masterPassword, "Ready", metav1.ConditionFalse, "SecretReconciliationFailed", err.Error())
 		if err := r.Status().Update(ctx, masterPassword); err != nil {
@@ -80,165 +103,396 @@ func (r *MasterPasswordReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 	}
 
 	// Update status
-	if err := r.updateStatus(ctx, masterPassword); err != nil {
+	requeueAfter, err := r.updateStatus(ctx, masterPassword)
+	if err != nil {
 		log.Error(err, "Failed to update status")
 		return ctrl.Result{}, err
 	}
 
 	log.Info("Successfully reconciled MasterPassword")
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
 }
 
-// reconcileSecret ensures the master password secret exists and is up to date
-func (r *MasterPasswordReconciler) reconcileSecret(ctx context.Context, mp *secretsv1alpha1.MasterPassword) error {
-	log := logf.FromContext(ctx)
+// reconcileSecret ensures the master password secret exists and is up to date, applies
+// requested regenerations and rollbacks, and keeps the history of the master password
+func (r *MasterPasswordReconciler) reconcileSecret(ctx context.Context, mp *secretsv1beta1.MasterPassword) error {
+	// Master passwords with Memory storage live in the Vault only, Shamir master passwords are
+	// combined from their shares and external ones read from their source whenever they are used.
+	// Derivation backends hold their key themselves
+	if !mp.Spec.InSecret() {
+		return nil
+	}
 
 	secretName, secretNamespace := r.getSecretNameAndNamespace(mp)
-
-	secret := &corev1.Secret{}
-	err := r.Get(ctx, types.NamespacedName{Name: secretName, Namespace: secretNamespace}, secret)
-
-	if err != nil {
-		if !apierrors.IsNotFound(err) {
-			return fmt.Errorf("failed to get secret: %w", err)
+	key := types.NamespacedName{Name: secretName, Namespace: secretNamespace}
+	dataKey := mp.Spec.Secret.DataKey()
+	codec := newMasterPasswordCodec(r.KMS, mp)
+	if mp.Spec.SealedValue != "" {
+		privateKey, err := ensureSealingKey(ctx, r.Client, r.OperatorNamespace)
+		if err != nil {
+			return err
 		}
-
-		// Secret doesn't exist, check if we should create it
-		if mp.Spec.Secret != nil && !mp.Spec.Secret.Create {
-			return fmt.Errorf("secret %s/%s does not exist and create is false", secretNamespace, secretName)
+		// Values are sealed for the name of their MasterPassword, so they cannot be copied to another one
+		password, err := crypto.Unseal(privateKey, mp.Name, mp.Spec.SealedValue)
+		if err != nil {
+			return &conflictError{reason: "UnsealFailed", message: err.Error()}
 		}
-
-		// Generate a new master password
-		length := mp.Spec.Length
-		if length == 0 {
-			length = defaultLength
+		// A mistyped mnemonic is rejected before it replaces the master password
+		normalized, err := checkMasterPassword(mp, string(password))
+		if err != nil {
+			return err
 		}
-
-		password, err := crypto.GenerateRandomPassword(length)
+		err = ensureUnsealedMasterPasswordSecret(ctx, r.Client, key, dataKey, codec, []byte(normalized),
+			mp.Spec.Annotations)
 		if err != nil {
-			return fmt.Errorf("failed to generate master password: %w", err)
+			return err
 		}
-
-		// Create the secret
-		secret = &corev1.Secret{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:        secretName,
-				Namespace:   secretNamespace,
-				Labels:      map[string]string{"app.kubernetes.io/managed-by": "derived-secret-operator"},
-				Annotations: mp.Spec.Annotations,
-			},
-			Type: corev1.SecretTypeOpaque,
-			StringData: map[string]string{
-				masterPasswordKey: password,
-			},
+	} else {
+		err := ensureMasterPasswordSecret(ctx, r.Client, key, mp.Spec.Secret, codec, mp.Spec.Format, mp.Spec.Length,
+			mp.Spec.Annotations)
+		if err != nil {
+			return err
 		}
+	}
 
-		if err := r.Create(ctx, secret); err != nil {
-			return fmt.Errorf("failed to create secret: %w", err)
-		}
+	// Plain text generations are encrypted in place when KMS encryption is turned on, and
+	// decrypted when it is turned off
+	if err := recodeMasterPasswordSecret(ctx, r.Client, key, dataKey, codec); err != nil {
+		return err
+	}
+
+	// The current generations are recorded first, so a regeneration never loses one
+	limit := mp.Spec.HistoryLimitOrDefault()
+	if _, err := recordMasterPasswordHistory(ctx, r.Client, key, dataKey, limit); err != nil {
+		return err
+	}
+	if err := r.applyGenerationRequests(ctx, mp, key); err != nil {
+		return err
+	}
+	history, err := recordMasterPasswordHistory(ctx, r.Client, key, dataKey, limit)
+	if err != nil {
+		return err
+	}
+	mp.Status.HistoryGenerations = history
+	return nil
+}
 
-		log.Info("Created master password secret", "secret", secretNamespace+"/"+secretName)
+// applyGenerationRequests regenerates or rolls back the master password when the
+// RegenerateAnnotation or RollbackAnnotation holds a value not applied yet. Applied values
+// are recorded on the secret along with the new master password, so each is applied once
+func (r *MasterPasswordReconciler) applyGenerationRequests(
+	ctx context.Context,
+	mp *secretsv1beta1.MasterPassword,
+	key types.NamespacedName,
+) error {
+	// Sealed master passwords change with their sealed value only
+	if mp.Spec.SealedValue != "" {
+		_, regenerate := mp.Annotations[secretsv1beta1.RegenerateAnnotation]
+		_, rollback := mp.Annotations[secretsv1beta1.RollbackAnnotation]
+		if regenerate || rollback {
+			r.setCondition(mp, "Generation", metav1.ConditionFalse, "SealedMasterPassword",
+				"Sealed master passwords are replaced by sealing a new value")
+		}
 		return nil
 	}
 
-	// Secret exists, ensure it has the master password key
-	if _, ok := secret.Data[masterPasswordKey]; !ok {
-		return fmt.Errorf("secret %s/%s exists but missing %s key", secretNamespace, secretName, masterPasswordKey)
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, key, secret); err != nil {
+		return fmt.Errorf("failed to get master password secret %s: %w", key, err)
+	}
+	dataKey := mp.Spec.Secret.DataKey()
+
+	// A new primary generation during a migration would restart it from a generation some
+	// namespaces never derived from, so requests wait until the migration completes
+	if migration := mp.Status.Migration; migration != nil && migration.CompletedAt == nil &&
+		(pendingGenerationRequest(mp, secret, secretsv1beta1.RegenerateAnnotation) ||
+			pendingGenerationRequest(mp, secret, secretsv1beta1.RollbackAnnotation)) {
+		r.setCondition(mp, "Generation", metav1.ConditionFalse, "MigrationInProgress",
+			fmt.Sprintf("Waiting for the migration from generation %d to %d to complete",
+				migration.FromGeneration, migration.ToGeneration))
+		return nil
 	}
 
-	// Update annotations if they changed
-	if mp.Spec.Annotations != nil {
-		needsUpdate := false
-		if secret.Annotations == nil {
-			secret.Annotations = make(map[string]string)
+	request, ok := mp.Annotations[secretsv1beta1.RegenerateAnnotation]
+	if ok && request != secret.Annotations[secretsv1beta1.RegenerateAnnotation] {
+		err := regenerateMasterPassword(ctx, r.Client, key, dataKey, newMasterPasswordCodec(r.KMS, mp),
+			mp.Spec.Format, mp.Spec.Length, map[string]string{secretsv1beta1.RegenerateAnnotation: request})
+		if err := r.reportGenerationRequest(mp, err, "Regenerated", "Regenerated the master password"); err != nil {
+			return err
 		}
-		for k, v := range mp.Spec.Annotations {
-			if secret.Annotations[k] != v {
-				secret.Annotations[k] = v
-				needsUpdate = true
-			}
+	}
+
+	request, ok = mp.Annotations[secretsv1beta1.RollbackAnnotation]
+	if ok && request != secret.Annotations[secretsv1beta1.RollbackAnnotation] {
+		generation, err := strconv.Atoi(request)
+		if err != nil || generation < 1 {
+			r.setCondition(mp, "Generation", metav1.ConditionFalse, "InvalidRollback",
+				fmt.Sprintf("%s must be a generation number, got %q", secretsv1beta1.RollbackAnnotation, request))
+			return nil
 		}
-		if needsUpdate {
-			if err := r.Update(ctx, secret); err != nil {
-				return fmt.Errorf("failed to update secret annotations: %w", err)
-			}
-			log.Info("Updated secret annotations", "secret", secretNamespace+"/"+secretName)
+		err = rollbackMasterPassword(ctx, r.Client, key, dataKey, generation,
+			map[string]string{secretsv1beta1.RollbackAnnotation: request})
+		message := fmt.Sprintf("Restored generation %d as a new primary generation", generation)
+		if err := r.reportGenerationRequest(mp, err, "RolledBack", message); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
+// pendingGenerationRequest reports whether the annotation of the MasterPassword holds a value
+// not applied to the secret yet
+func pendingGenerationRequest(mp *secretsv1beta1.MasterPassword, secret *corev1.Secret, annotation string) bool {
+	request, ok := mp.Annotations[annotation]
+	return ok && request != secret.Annotations[annotation]
+}
+
+// reportGenerationRequest sets the Generation condition from the outcome of a regeneration or
+// rollback. Conflicts are reported in the condition; other errors are returned to be retried
+func (r *MasterPasswordReconciler) reportGenerationRequest(
+	mp *secretsv1beta1.MasterPassword,
+	err error,
+	reason, message string,
+) error {
+	var conflict *conflictError
+	switch {
+	case err == nil:
+		r.setCondition(mp, "Generation", metav1.ConditionTrue, reason, message)
+	case errors.As(err, &conflict):
+		r.setCondition(mp, "Generation", metav1.ConditionFalse, conflict.reason, conflict.message)
+	default:
+		return err
+	}
 	return nil
 }
 
-// updateStatus updates the MasterPassword status
-func (r *MasterPasswordReconciler) updateStatus(ctx context.Context, mp *secretsv1alpha1.MasterPassword) error {
+// updateStatus updates the MasterPassword status and advances a migration to a new primary
+// master password. It returns when the next batch of the migration is due, or 0, and the error
+// of an unreachable derivation backend once it is reported
+func (r *MasterPasswordReconciler) updateStatus(
+	ctx context.Context,
+	mp *secretsv1beta1.MasterPassword,
+) (time.Duration, error) {
 	log := logf.FromContext(ctx)
 
-	secretName, secretNamespace := r.getSecretNameAndNamespace(mp)
+	var secretName, secretNamespace string
+	var password string
+	var passwordFingerprint string
+	primary, previous, sharesPresent := 1, 0, 0
+	// notReady is set when the master password cannot be used yet
+	var notReady *conflictError
+	// unavailable is returned after the status is updated, so it is retried
+	var unavailable error
+	switch {
+	case mp.Spec.InMemory():
+		var ok bool
+		if password, ok = r.Vault.Get(mp.Name, mp.UID); !ok {
+			notReady = &conflictError{
+				reason:  "Sealed",
+				message: fmt.Sprintf("Unseal the master password through the %s%s endpoint", unseal.Path, mp.Name),
+			}
+		}
+	case mp.Spec.Shamir != nil:
+		var err error
+		password, sharesPresent, err = readShamirMasterPassword(ctx, r.Client, mp.Spec.Shamir)
+		if err != nil && !errors.As(err, &notReady) {
+			return 0, err
+		}
+	case mp.Spec.Source != nil:
+		var err error
+		password, err = readSourceMasterPassword(ctx, r.Client, r.Sources, mp.Spec.Source)
+		if err != nil && !errors.As(err, &notReady) {
+			return 0, err
+		}
+	case mp.Spec.Deriver != nil:
+		// There is no master password; the fingerprint is keyed by the backend key instead.
+		// An unreachable backend is reported and retried with backoff
+		d, err := r.Derivers.backendDeriver(ctx, r.Client, mp)
+		if err == nil {
+			passwordFingerprint, err = deriverFingerprint(ctx, d)
+		}
+		if err != nil && !errors.As(err, &notReady) {
+			unavailable = err
+			notReady = &conflictError{reason: "DeriverUnavailable", message: err.Error()}
+		}
+	default:
+		secretName, secretNamespace = r.getSecretNameAndNamespace(mp)
+
+		secretKey := types.NamespacedName{Name: secretName, Namespace: secretNamespace}
+		var err error
+		password, err = readMasterPassword(ctx, r.Client, secretKey, mp.Spec.Secret.DataKey(),
+			newMasterPasswordCodec(r.KMS, mp))
+		if err != nil && !errors.As(err, &notReady) {
+			return 0, err
+		}
 
-	// Get the secret to calculate password hash
-	secret := &corev1.Secret{}
-	if err := r.Get(ctx, types.NamespacedName{Name: secretName, Namespace: secretNamespace}, secret); err != nil {
-		return fmt.Errorf("failed to get secret for hash calculation: %w", err)
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, secretKey, secret); err != nil {
+			return 0, fmt.Errorf("failed to get master password secret %s: %w", secretKey, err)
+		}
+		primary = secretGeneration(secret)
+		if _, ok := secret.Data[mp.Spec.Secret.DataKey()+secretsv1beta1.PreviousMasterPasswordSuffix]; ok {
+			previous = primary - 1
+		}
 	}
 
-	// Calculate password hash
-	passwordBytes, ok := secret.Data[masterPasswordKey]
-	if !ok {
-		return fmt.Errorf("secret missing %s key", masterPasswordKey)
+	// Fingerprint a master password that may be used, catching mistyped mnemonics and weak
+	// master passwords
+	if notReady == nil && mp.Spec.Deriver == nil {
+		normalized, err := checkMasterPassword(mp, password)
+		if err != nil && !errors.As(err, &notReady) {
+			return 0, err
+		}
+		if err == nil {
+			if passwordFingerprint, err = masterPasswordFingerprint(ctx, normalized); err != nil {
+				return 0, err
+			}
+		}
+		r.reportWeakMasterPassword(mp, notReady)
 	}
-	passwordHash := crypto.CalculatePasswordHash(string(passwordBytes))
 
 	// Count dependent DerivedSecrets
-	derivedSecrets := &secretsv1alpha1.DerivedSecretList{}
+	derivedSecrets := &secretsv1beta1.DerivedSecretList{}
 	if err := r.List(ctx, derivedSecrets); err != nil {
-		return fmt.Errorf("failed to list DerivedSecrets: %w", err)
+		return 0, fmt.Errorf("failed to list DerivedSecrets: %w", err)
 	}
 
 	dependentCount := 0
+	namespaces := make(map[string]bool)
 	for _, ds := range derivedSecrets.Items {
 		for _, keySpec := range ds.Spec.Keys {
-			mpName := keySpec.MasterPassword
-			if mpName == "" {
-				mpName = "default"
-			}
-			if mpName == mp.Name {
+			ref := ds.Spec.MasterPasswordRefFor(keySpec)
+			if ref.Kind == secretsv1beta1.KindMasterPassword && ref.Name == mp.Name {
 				dependentCount++
+				namespaces[ds.Namespace] = true
 				break
 			}
 		}
 	}
 
+	// ClusterDerivedSecrets derive in every namespace they write to
+	clusterDerivedSecrets := &secretsv1beta1.ClusterDerivedSecretList{}
+	if err := r.List(ctx, clusterDerivedSecrets); err != nil {
+		return 0, fmt.Errorf("failed to list ClusterDerivedSecrets: %w", err)
+	}
+	for _, cds := range clusterDerivedSecrets.Items {
+		for _, keySpec := range cds.Spec.Template.Keys {
+			ref := cds.Spec.Template.MasterPasswordRefFor(keySpec)
+			if ref.Kind == secretsv1beta1.KindMasterPassword && ref.Name == mp.Name {
+				for _, status := range cds.Status.Namespaces {
+					namespaces[status.Namespace] = true
+				}
+				break
+			}
+		}
+	}
+
+	// A new primary generation starts a migration if the previous one is still available
+	if mp.Spec.Migration != nil && mp.Status.PrimaryGeneration != 0 &&
+		mp.Status.PrimaryGeneration != primary && mp.Status.PrimaryGeneration == previous {
+		mp.Status.Migration = &secretsv1beta1.MigrationStatus{
+			FromGeneration: previous,
+			ToGeneration:   primary,
+		}
+	}
+	requeueAfter := advanceMigration(mp, previous, slices.Sorted(maps.Keys(namespaces)))
+
 	mp.Status.SecretName = secretName
 	mp.Status.SecretNamespace = secretNamespace
-	mp.Status.Ready = true
 	mp.Status.DependentSecrets = dependentCount
-	mp.Status.PasswordHash = passwordHash
-
-	r.setCondition(mp, "Ready", metav1.ConditionTrue, "SecretReady", "Master password secret is ready")
+	mp.Status.PasswordFingerprint = passwordFingerprint
+	mp.Status.PrimaryGeneration = primary
+	mp.Status.PreviousGeneration = previous
+	mp.Status.SharesPresent = sharesPresent
+
+	switch {
+	case notReady != nil:
+		r.setCondition(mp, "Ready", metav1.ConditionFalse, notReady.reason, notReady.message)
+	case mp.Spec.InMemory():
+		r.setCondition(mp, "Ready", metav1.ConditionTrue, "Unsealed", "Master password is unsealed in memory")
+	case mp.Spec.Shamir != nil:
+		r.setCondition(mp, "Ready", metav1.ConditionTrue, "SharesCombined",
+			fmt.Sprintf("Master password is combined from %d shares", sharesPresent))
+	case mp.Spec.Source != nil:
+		r.setCondition(mp, "Ready", metav1.ConditionTrue, "SourceReady", "Master password source is readable")
+	case mp.Spec.Deriver != nil:
+		r.setCondition(mp, "Ready", metav1.ConditionTrue, "DeriverReady", "Derivation backend derives secrets")
+	default:
+		r.setCondition(mp, "Ready", metav1.ConditionTrue, "SecretReady", "Master password secret is ready")
+	}
 
 	if err := r.Status().Update(ctx, mp); err != nil {
 		log.Error(err, "Failed to update status")
-		return err
+		return 0, err
 	}
 
-	return nil
+	return requeueAfter, unavailable
 }
 
-// getSecretNameAndNamespace returns the secret name and namespace for the MasterPassword
-func (r *MasterPasswordReconciler) getSecretNameAndNamespace(mp *secretsv1alpha1.MasterPassword) (string, string) {
-	secretName := mp.Name + "-mp"
-	secretNamespace := r.OperatorNamespace
+// advanceMigration migrates the next batch of namespaces once it is due, and completes the
+// migration when every namespace was migrated or it can no longer be served. It returns when
+// the next batch is due, or 0 if no migration is in progress
+func advanceMigration(mp *secretsv1beta1.MasterPassword, previous int, namespaces []string) time.Duration {
+	migration := mp.Status.Migration
+	if migration == nil || migration.CompletedAt != nil {
+		return 0
+	}
 
-	if mp.Spec.Secret != nil && mp.Spec.Secret.Name != "" {
-		secretName = mp.Spec.Secret.Name
+	now := metav1.Now()
+	// Without a migration spec or the previous generation every namespace moves at once
+	if mp.Spec.Migration == nil || migration.FromGeneration != previous {
+		migration.CompletedAt = &now
+		return 0
 	}
 
-	return secretName, secretNamespace
+	interval := mp.Spec.Migration.Interval.Duration
+	if migration.LastBatchTime != nil {
+		if wait := migration.LastBatchTime.Add(interval).Sub(now.Time); wait > 0 {
+			return wait
+		}
+	}
+
+	pending := slices.DeleteFunc(namespaces, func(namespace string) bool {
+		return slices.Contains(migration.MigratedNamespaces, namespace)
+	})
+	batch := pending[:min(mp.Spec.Migration.BatchSize, len(pending))]
+	migration.MigratedNamespaces = append(migration.MigratedNamespaces, batch...)
+	migration.TotalNamespaces = len(migration.MigratedNamespaces) + len(pending) - len(batch)
+	migration.LastBatchTime = &now
+	if len(batch) == len(pending) {
+		migration.CompletedAt = &now
+		return 0
+	}
+	return interval
+}
+
+// getSecretNameAndNamespace returns the secret name and namespace for the MasterPassword
+func (r *MasterPasswordReconciler) getSecretNameAndNamespace(mp *secretsv1beta1.MasterPassword) (string, string) {
+	return masterPasswordSecretName(mp.Name, mp.Spec.Secret),
+		masterPasswordSecretNamespace(mp.Spec.Secret, r.OperatorNamespace)
+}
+
+// reportWeakMasterPassword sets the WeakMasterPassword condition of a MasterPassword with a
+// validation policy from the conflict, if any, of checking its master password. Other conflicts
+// leave the condition unchanged, as the master password could not be checked
+func (r *MasterPasswordReconciler) reportWeakMasterPassword(
+	mp *secretsv1beta1.MasterPassword,
+	conflict *conflictError,
+) {
+	switch {
+	case mp.Spec.Validation == nil:
+		meta.RemoveStatusCondition(&mp.Status.Conditions, weakMasterPasswordReason)
+	case conflict == nil:
+		r.setCondition(mp, weakMasterPasswordReason, metav1.ConditionFalse, "PolicySatisfied",
+			"Master password meets the validation policy")
+	case conflict.reason == weakMasterPasswordReason:
+		r.setCondition(mp, weakMasterPasswordReason, metav1.ConditionTrue, "PolicyViolated", conflict.message)
+	}
 }
 
 // setCondition sets a condition on the MasterPassword
 func (r *MasterPasswordReconciler) setCondition(
-	mp *secretsv1alpha1.MasterPassword,
+	mp *secretsv1beta1.MasterPassword,
 	condType string,
 	status metav1.ConditionStatus,
 	reason, message string,
@@ -262,24 +516,24 @@ func (r *MasterPasswordReconciler) findMasterPasswordsForSecret() handler.EventH
 			return nil
 		}
 
-		// Only watch secrets in the operator namespace
-		if secret.Namespace != r.OperatorNamespace {
-			return nil
-		}
-
-		// Only process secrets managed by this operator
-		if secret.Labels == nil || secret.Labels["app.kubernetes.io/managed-by"] != "derived-secret-operator" {
-			return nil
-		}
-
-		// List all MasterPasswords to find which one corresponds to this secret
-		mpList := &secretsv1alpha1.MasterPasswordList{}
+		// Imported secrets may live in any namespace without the managed-by label,
+		// so list all MasterPasswords to find which one corresponds to this secret
+		mpList := &secretsv1beta1.MasterPasswordList{}
 		if err := r.List(ctx, mpList); err != nil {
 			return nil
 		}
 
+		// Restoring the sealing key may let sealed master passwords be unsealed
+		isSealingKey := secret.Name == sealingKeyName && secret.Namespace == r.OperatorNamespace
+
 		var requests []ctrl.Request
 		for _, mp := range mpList.Items {
+			// Changed shares may complete or break a Shamir master password
+			if (isSealingKey && mp.Spec.SealedValue != "") || referencesShare(mp.Spec.Shamir, secret) ||
+				referencesSourceSecret(mp.Spec.Source, secret) || referencesDeriverSecret(mp.Spec.Deriver, secret) {
+				requests = append(requests, ctrl.Request{NamespacedName: types.NamespacedName{Name: mp.Name}})
+				continue
+			}
 			secretName, secretNamespace := r.getSecretNameAndNamespace(&mp)
 			// Trigger reconcile if secret name/namespace matches this MasterPassword
 			if secret.Name == secretName && secret.Namespace == secretNamespace {
@@ -297,9 +551,16 @@ func (r *MasterPasswordReconciler) findMasterPasswordsForSecret() handler.EventH
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *MasterPasswordReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&secretsv1alpha1.MasterPassword{}).
-		Watches(&corev1.Secret{}, r.findMasterPasswordsForSecret()).
-		Named("masterpassword").
-		Complete(r)
+	// The sealing key is published before any master password can be sealed to it
+	if err := mgr.Add(manager.RunnableFunc(r.publishSealingKey)); err != nil {
+		return err
+	}
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&secretsv1beta1.MasterPassword{}).
+		Watches(&corev1.Secret{}, r.findMasterPasswordsForSecret())
+	if r.Vault != nil {
+		// Unsealing a master password makes it ready
+		b = b.WatchesRawSource(r.Vault.Source())
+	}
+	return b.Named("masterpassword").Complete(r)
 }
@@ -18,16 +18,35 @@ package controller
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
-	secretsv1alpha1 "github.com/oleksiyp/derived-secret-operator/api/v1alpha1"
+	secretsv1beta1 "github.com/oleksiyp/derived-secret-operator/api/v1beta1"
+	"github.com/oleksiyp/derived-secret-operator/internal/crypto"
+	"github.com/oleksiyp/derived-secret-operator/internal/deriver"
+	"github.com/oleksiyp/derived-secret-operator/internal/kms"
+	"github.com/oleksiyp/derived-secret-operator/internal/unseal"
 )
 
 var _ = Describe("MasterPassword Controller", func() {
@@ -40,13 +59,13 @@ var _ = Describe("MasterPassword Controller", func() {
 			Name: resourceName,
 			// MasterPassword is cluster-scoped, no namespace
 		}
-		masterpassword := &secretsv1alpha1.MasterPassword{}
+		masterpassword := &secretsv1beta1.MasterPassword{}
 
 		BeforeEach(func() {
 			By("creating the custom resource for the Kind MasterPassword")
 			err := k8sClient.Get(ctx, typeNamespacedName, masterpassword)
 			if err != nil && errors.IsNotFound(err) {
-				resource := &secretsv1alpha1.MasterPassword{
+				resource := &secretsv1beta1.MasterPassword{
 					ObjectMeta: metav1.ObjectMeta{
 						Name: resourceName,
 						// MasterPassword is cluster-scoped, no namespace
@@ -57,7 +76,7 @@ var _ = Describe("MasterPassword Controller", func() {
 		})
 
 		AfterEach(func() {
-			resource := &secretsv1alpha1.MasterPassword{}
+			resource := &secretsv1beta1.MasterPassword{}
 			err := k8sClient.Get(ctx, typeNamespacedName, resource)
 			Expect(err).NotTo(HaveOccurred())
 
@@ -77,5 +96,938 @@ var _ = Describe("MasterPassword Controller", func() {
 			})
 			Expect(err).NotTo(HaveOccurred())
 		})
+
+		It("should honor the secret namespace and key", func() {
+			const rootNamespace = "secrets-root"
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: rootNamespace}}
+			if err := k8sClient.Create(ctx, ns); err != nil {
+				Expect(errors.IsAlreadyExists(err)).To(BeTrue())
+			}
+
+			By("Pointing the MasterPassword at a secret outside the operator namespace")
+			resource := &secretsv1beta1.MasterPassword{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Secret = &secretsv1beta1.SecretReference{
+				Name:      "imported-root",
+				Namespace: rootNamespace,
+				Key:       "value",
+				Create:    true,
+			}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			controllerReconciler := &MasterPasswordReconciler{
+				Client:            k8sClient,
+				Scheme:            k8sClient.Scheme(),
+				OperatorNamespace: "default",
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "imported-root", Namespace: rootNamespace}, secret)).
+				To(Succeed())
+			Expect(secret.Data).To(HaveKey("value"))
+			Expect(secret.Data).NotTo(HaveKey(masterPasswordKey))
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Status.SecretNamespace).To(Equal(rootNamespace))
+		})
+
+		It("should keep DerivedSecrets pending until a Memory master password is unsealed", func() {
+			vault := unseal.NewVault()
+			mpReconciler := &MasterPasswordReconciler{
+				Client:            k8sClient,
+				Scheme:            k8sClient.Scheme(),
+				OperatorNamespace: "default",
+				Vault:             vault,
+			}
+			dsReconciler := &DerivedSecretReconciler{
+				Client:            k8sClient,
+				Scheme:            k8sClient.Scheme(),
+				OperatorNamespace: "default",
+				Vault:             vault,
+			}
+
+			memoryName := types.NamespacedName{Name: "memory-resource"}
+			memoryMP := &secretsv1beta1.MasterPassword{
+				ObjectMeta: metav1.ObjectMeta{Name: memoryName.Name},
+				Spec:       secretsv1beta1.MasterPasswordSpec{Storage: secretsv1beta1.MasterPasswordStorageMemory},
+			}
+			Expect(k8sClient.Create(ctx, memoryMP)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, memoryMP)).To(Succeed())
+			})
+			ds := &secretsv1beta1.DerivedSecret{
+				ObjectMeta: metav1.ObjectMeta{Name: "memory-app", Namespace: "default"},
+				Spec: secretsv1beta1.DerivedSecretSpec{
+					MasterPassword: memoryName.Name,
+					Keys: map[string]secretsv1beta1.DerivedKeySpec{
+						"password": {Type: secretsv1beta1.SecretTypePassword},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, ds)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, ds)).To(Succeed())
+			})
+			reconcileBoth := func() {
+				_, err := mpReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: memoryName})
+				Expect(err).NotTo(HaveOccurred())
+				_, err = dsReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(ds)})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(k8sClient.Get(ctx, memoryName, memoryMP)).To(Succeed())
+				Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(ds), ds)).To(Succeed())
+			}
+
+			By("Starting sealed")
+			reconcileBoth()
+			Expect(meta.FindStatusCondition(memoryMP.Status.Conditions, "Ready").Reason).To(Equal("Sealed"))
+			Expect(memoryMP.Status.SecretName).To(BeEmpty())
+			Expect(meta.FindStatusCondition(ds.Status.Conditions, "Pending").Reason).To(Equal("Sealed"))
+			Expect(meta.FindStatusCondition(ds.Status.Conditions, "Ready").Reason).To(Equal("Pending"))
+			Expect(meta.FindStatusCondition(ds.Status.Conditions, "Conflict")).To(BeNil())
+			err := k8sClient.Get(ctx, client.ObjectKeyFromObject(ds), &corev1.Secret{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+
+			By("Deriving once unsealed")
+			vault.Unseal(memoryName.Name, memoryMP.UID, "memory-master-password-for-testing")
+			reconcileBoth()
+			Expect(meta.FindStatusCondition(memoryMP.Status.Conditions, "Ready").Reason).To(Equal("Unsealed"))
+			Expect(meta.IsStatusConditionTrue(ds.Status.Conditions, "Ready")).To(BeTrue())
+			Expect(meta.FindStatusCondition(ds.Status.Conditions, "Pending")).To(BeNil())
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(ds), secret)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, secret)).To(Succeed())
+			})
+			Expect(secret.Data).To(HaveKey("password"))
+		})
+
+		It("should combine a Shamir master password from shares held in several namespaces", func() {
+			mpReconciler := &MasterPasswordReconciler{
+				Client:            k8sClient,
+				Scheme:            k8sClient.Scheme(),
+				OperatorNamespace: "default",
+			}
+			dsReconciler := &DerivedSecretReconciler{
+				Client:            k8sClient,
+				Scheme:            k8sClient.Scheme(),
+				OperatorNamespace: "default",
+			}
+
+			shares, err := crypto.SplitSecret([]byte("shamir-master-password-for-testing"), 3, 2)
+			Expect(err).NotTo(HaveOccurred())
+			refs := []secretsv1beta1.ShareReference{
+				{Name: "shamir-share-a", Namespace: "default"},
+				{Name: "shamir-share-b", Namespace: "kube-public"},
+				{Name: "shamir-share-c", Namespace: "kube-public", Key: "part"},
+			}
+			shamirName := types.NamespacedName{Name: "shamir-resource"}
+			shamirMP := &secretsv1beta1.MasterPassword{
+				ObjectMeta: metav1.ObjectMeta{Name: shamirName.Name},
+				Spec: secretsv1beta1.MasterPasswordSpec{
+					Shamir: &secretsv1beta1.ShamirSpec{Threshold: 2, Shares: refs},
+				},
+			}
+			Expect(k8sClient.Create(ctx, shamirMP)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, shamirMP)).To(Succeed())
+			})
+			ds := &secretsv1beta1.DerivedSecret{
+				ObjectMeta: metav1.ObjectMeta{Name: "shamir-app", Namespace: "default"},
+				Spec: secretsv1beta1.DerivedSecretSpec{
+					MasterPassword: shamirName.Name,
+					Keys: map[string]secretsv1beta1.DerivedKeySpec{
+						"password": {Type: secretsv1beta1.SecretTypePassword},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, ds)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, ds)).To(Succeed())
+			})
+			createShare := func(i int) {
+				share := &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: refs[i].Name, Namespace: refs[i].Namespace},
+					Data:       map[string][]byte{refs[i].DataKey(): []byte(shares[i])},
+				}
+				Expect(k8sClient.Create(ctx, share)).To(Succeed())
+				DeferCleanup(func() {
+					Expect(k8sClient.Delete(ctx, share)).To(Succeed())
+				})
+			}
+			reconcileBoth := func() {
+				_, err := mpReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: shamirName})
+				Expect(err).NotTo(HaveOccurred())
+				_, err = dsReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(ds)})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(k8sClient.Get(ctx, shamirName, shamirMP)).To(Succeed())
+				Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(ds), ds)).To(Succeed())
+			}
+
+			By("Waiting for enough shares")
+			createShare(0)
+			reconcileBoth()
+			Expect(shamirMP.Status.SharesPresent).To(Equal(1))
+			Expect(meta.FindStatusCondition(shamirMP.Status.Conditions, "Ready").Reason).
+				To(Equal("InsufficientShares"))
+			Expect(shamirMP.Status.SecretName).To(BeEmpty())
+			Expect(meta.FindStatusCondition(ds.Status.Conditions, "Pending").Reason).To(Equal("InsufficientShares"))
+
+			By("Deriving once the threshold is reached")
+			createShare(2)
+			reconcileBoth()
+			Expect(shamirMP.Status.SharesPresent).To(Equal(2))
+			Expect(meta.FindStatusCondition(shamirMP.Status.Conditions, "Ready").Reason).To(Equal("SharesCombined"))
+			Expect(masterPasswordFingerprint(ctx, "shamir-master-password-for-testing")).
+				To(Equal(shamirMP.Status.PasswordFingerprint))
+			Expect(meta.IsStatusConditionTrue(ds.Status.Conditions, "Ready")).To(BeTrue())
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(ds), secret)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, secret)).To(Succeed())
+			})
+			Expect(secret.Data).To(HaveKey("password"))
+
+			By("Never storing the combined master password")
+			err = k8sClient.Get(ctx, types.NamespacedName{Name: shamirName.Name + "-mp", Namespace: "default"},
+				&corev1.Secret{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should generate mnemonic master passwords and reject mistyped ones", func() {
+			mpReconciler := &MasterPasswordReconciler{
+				Client:            k8sClient,
+				Scheme:            k8sClient.Scheme(),
+				OperatorNamespace: "default",
+			}
+
+			By("Generating a 24 word mnemonic")
+			mnemonicName := types.NamespacedName{Name: "mnemonic-resource"}
+			mnemonicMP := &secretsv1beta1.MasterPassword{
+				ObjectMeta: metav1.ObjectMeta{Name: mnemonicName.Name},
+				Spec:       secretsv1beta1.MasterPasswordSpec{Format: secretsv1beta1.MasterPasswordFormatMnemonic},
+			}
+			Expect(k8sClient.Create(ctx, mnemonicMP)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, mnemonicMP)).To(Succeed())
+			})
+			_, err := mpReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: mnemonicName})
+			Expect(err).NotTo(HaveOccurred())
+
+			secret := &corev1.Secret{}
+			secretKey := types.NamespacedName{Name: mnemonicName.Name + "-mp", Namespace: "default"}
+			Expect(k8sClient.Get(ctx, secretKey, secret)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, secret)).To(Succeed())
+			})
+			mnemonic, err := crypto.NormalizeMnemonic(string(secret.Data[masterPasswordKey]))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(strings.Fields(mnemonic)).To(HaveLen(crypto.MnemonicWords))
+			Expect(k8sClient.Get(ctx, mnemonicName, mnemonicMP)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(mnemonicMP.Status.Conditions, "Ready")).To(BeTrue())
+
+			By("Rejecting an imported mnemonic with a typo")
+			typo := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "typo-mnemonic", Namespace: "default"},
+				StringData: map[string]string{masterPasswordKey: strings.Repeat("abandon ", 23) + "arm"},
+			}
+			Expect(k8sClient.Create(ctx, typo)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, typo)).To(Succeed())
+			})
+			typoName := types.NamespacedName{Name: "typo-resource"}
+			typoMP := &secretsv1beta1.MasterPassword{
+				ObjectMeta: metav1.ObjectMeta{Name: typoName.Name},
+				Spec: secretsv1beta1.MasterPasswordSpec{
+					Secret: &secretsv1beta1.SecretReference{Name: typo.Name, Namespace: "default"},
+					Format: secretsv1beta1.MasterPasswordFormatMnemonic,
+				},
+			}
+			Expect(k8sClient.Create(ctx, typoMP)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, typoMP)).To(Succeed())
+			})
+			_, err = mpReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typoName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typoName, typoMP)).To(Succeed())
+			condition := meta.FindStatusCondition(typoMP.Status.Conditions, "Ready")
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal("InvalidMnemonic"))
+			Expect(typoMP.Status.PasswordFingerprint).To(BeEmpty())
+		})
+
+		It("should block derivation from a weak master password read from its source", func() {
+			sources := SourcePolicy{EnvPrefix: "DERIVED_SECRET_OPERATOR_TEST_"}
+			mpReconciler := &MasterPasswordReconciler{
+				Client:            k8sClient,
+				Scheme:            k8sClient.Scheme(),
+				OperatorNamespace: "default",
+				Sources:           sources,
+			}
+			dsReconciler := &DerivedSecretReconciler{
+				Client:            k8sClient,
+				Scheme:            k8sClient.Scheme(),
+				OperatorNamespace: "default",
+				Sources:           sources,
+			}
+
+			const env = "DERIVED_SECRET_OPERATOR_TEST_MASTER_PASSWORD"
+			Expect(os.Setenv(env, "changeme")).To(Succeed())
+			DeferCleanup(func() {
+				Expect(os.Unsetenv(env)).To(Succeed())
+			})
+			sourceName := types.NamespacedName{Name: "source-resource"}
+			sourceMP := &secretsv1beta1.MasterPassword{
+				ObjectMeta: metav1.ObjectMeta{Name: sourceName.Name},
+				Spec: secretsv1beta1.MasterPasswordSpec{
+					Source:     &secretsv1beta1.MasterPasswordSource{Env: env},
+					Validation: &secretsv1beta1.MasterPasswordValidation{},
+				},
+			}
+			Expect(k8sClient.Create(ctx, sourceMP)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, sourceMP)).To(Succeed())
+			})
+			Expect(sourceMP.Spec.Validation.MinLength).To(Equal(16))
+			ds := &secretsv1beta1.DerivedSecret{
+				ObjectMeta: metav1.ObjectMeta{Name: "source-app", Namespace: "default"},
+				Spec: secretsv1beta1.DerivedSecretSpec{
+					MasterPassword: sourceName.Name,
+					Keys: map[string]secretsv1beta1.DerivedKeySpec{
+						"password": {Type: secretsv1beta1.SecretTypePassword},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, ds)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, ds)).To(Succeed())
+			})
+			reconcileBoth := func() {
+				_, err := mpReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: sourceName})
+				Expect(err).NotTo(HaveOccurred())
+				_, err = dsReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(ds)})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(k8sClient.Get(ctx, sourceName, sourceMP)).To(Succeed())
+				Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(ds), ds)).To(Succeed())
+			}
+
+			By("Rejecting a weak master password")
+			reconcileBoth()
+			Expect(meta.IsStatusConditionTrue(sourceMP.Status.Conditions, "WeakMasterPassword")).To(BeTrue())
+			Expect(meta.FindStatusCondition(sourceMP.Status.Conditions, "Ready").Reason).To(Equal("WeakMasterPassword"))
+			Expect(meta.FindStatusCondition(ds.Status.Conditions, "Pending").Reason).To(Equal("WeakMasterPassword"))
+			err := k8sClient.Get(ctx, client.ObjectKeyFromObject(ds), &corev1.Secret{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+
+			By("Deriving from a strong master password")
+			Expect(os.Setenv(env, "Xk9#mQ2$vL7pR4@wN8zT5&bH3")).To(Succeed())
+			reconcileBoth()
+			Expect(meta.IsStatusConditionFalse(sourceMP.Status.Conditions, "WeakMasterPassword")).To(BeTrue())
+			Expect(meta.FindStatusCondition(sourceMP.Status.Conditions, "Ready").Reason).To(Equal("SourceReady"))
+			Expect(sourceMP.Status.SecretName).To(BeEmpty())
+			Expect(meta.IsStatusConditionTrue(ds.Status.Conditions, "Ready")).To(BeTrue())
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(ds), secret)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, secret)).To(Succeed())
+			})
+
+			setSource := func(source secretsv1beta1.MasterPasswordSource) {
+				Expect(k8sClient.Get(ctx, sourceName, sourceMP)).To(Succeed())
+				sourceMP.Spec.Source = &source
+				Expect(k8sClient.Update(ctx, sourceMP)).To(Succeed())
+				reconcileBoth()
+			}
+
+			By("Refusing environment variables without the allowed prefix")
+			Expect(os.Setenv("OTHER_"+env, "Xk9#mQ2$vL7pR4@wN8zT5&bH3")).To(Succeed())
+			DeferCleanup(func() {
+				Expect(os.Unsetenv("OTHER_" + env)).To(Succeed())
+			})
+			setSource(secretsv1beta1.MasterPasswordSource{Env: "OTHER_" + env})
+			Expect(meta.FindStatusCondition(sourceMP.Status.Conditions, "Ready").Reason).To(Equal("SourceNotAllowed"))
+			Expect(meta.FindStatusCondition(ds.Status.Conditions, "Pending").Reason).To(Equal("SourceNotAllowed"))
+
+			By("Reading files from the allowed directory only")
+			allowedDir, outsideDir := GinkgoT().TempDir(), GinkgoT().TempDir()
+			for _, dir := range []string{allowedDir, outsideDir} {
+				Expect(os.WriteFile(dir+"/value", []byte("Xk9#mQ2$vL7pR4@wN8zT5&bH3\n"), 0o600)).To(Succeed())
+			}
+			Expect(os.Symlink(outsideDir+"/value", allowedDir+"/link")).To(Succeed())
+			mpReconciler.Sources.FileDir = allowedDir
+			dsReconciler.Sources.FileDir = allowedDir
+			escaping := allowedDir + "/../" + filepath.Base(outsideDir) + "/value"
+			for _, file := range []string{outsideDir + "/value", allowedDir + "/link", escaping} {
+				setSource(secretsv1beta1.MasterPasswordSource{File: file})
+				Expect(meta.FindStatusCondition(sourceMP.Status.Conditions, "Ready").Reason).To(Equal("SourceNotAllowed"))
+			}
+			setSource(secretsv1beta1.MasterPasswordSource{File: allowedDir + "/value"})
+			Expect(meta.FindStatusCondition(sourceMP.Status.Conditions, "Ready").Reason).To(Equal("SourceReady"))
+			Expect(meta.IsStatusConditionTrue(ds.Status.Conditions, "Ready")).To(BeTrue())
+		})
+
+		It("should derive secrets with a key held by Vault Transit", func() {
+			derivers := NewDeriverCache()
+			mpReconciler := &MasterPasswordReconciler{
+				Client:            k8sClient,
+				Scheme:            k8sClient.Scheme(),
+				OperatorNamespace: "default",
+				Derivers:          derivers,
+			}
+			dsReconciler := &DerivedSecretReconciler{
+				Client:            k8sClient,
+				Scheme:            k8sClient.Scheme(),
+				OperatorNamespace: "default",
+				Derivers:          derivers,
+			}
+
+			// A Transit engine computing HMACs with a key the operator never sees
+			transit := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				var body struct {
+					Input string `json:"input"`
+				}
+				if req.Header.Get("X-Vault-Token") != "vault-token" || json.NewDecoder(req.Body).Decode(&body) != nil {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				input, err := base64.StdEncoding.DecodeString(body.Input)
+				if err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				mac := hmac.New(sha256.New, []byte("transit-key"))
+				mac.Write(input)
+				_, _ = fmt.Fprintf(w, `{"data":{"hmac":"vault:v1:%s"}}`, base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+			}))
+			DeferCleanup(transit.Close)
+
+			deriverName := types.NamespacedName{Name: "vault-resource"}
+			deriverMP := &secretsv1beta1.MasterPassword{
+				ObjectMeta: metav1.ObjectMeta{Name: deriverName.Name},
+				Spec: secretsv1beta1.MasterPasswordSpec{
+					Deriver: &secretsv1beta1.DeriverSpec{
+						Vault: &secretsv1beta1.VaultTransitDeriver{
+							Address:        transit.URL,
+							Key:            "derived",
+							TokenSecretRef: secretsv1beta1.SecretKeyReference{Name: "vault-token", Namespace: "default", Key: "token"},
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, deriverMP)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, deriverMP)).To(Succeed())
+			})
+			ds := &secretsv1beta1.DerivedSecret{
+				ObjectMeta: metav1.ObjectMeta{Name: "vault-app", Namespace: "default"},
+				Spec: secretsv1beta1.DerivedSecretSpec{
+					MasterPassword: deriverName.Name,
+					Keys: map[string]secretsv1beta1.DerivedKeySpec{
+						"password": {Type: secretsv1beta1.SecretTypePassword},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, ds)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, ds)).To(Succeed())
+			})
+			reconcileBoth := func() {
+				_, err := mpReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: deriverName})
+				Expect(err).NotTo(HaveOccurred())
+				_, err = dsReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(ds)})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(k8sClient.Get(ctx, deriverName, deriverMP)).To(Succeed())
+				Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(ds), ds)).To(Succeed())
+			}
+
+			By("Reporting a missing Vault token")
+			reconcileBoth()
+			Expect(meta.FindStatusCondition(deriverMP.Status.Conditions, "Ready").Reason).To(Equal("DeriverUnavailable"))
+			Expect(meta.FindStatusCondition(ds.Status.Conditions, "Pending").Reason).To(Equal("DeriverUnavailable"))
+
+			By("Deriving through Vault once the token is provided")
+			token := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "vault-token", Namespace: "default"},
+				StringData: map[string]string{"token": "vault-token"},
+			}
+			Expect(k8sClient.Create(ctx, token)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, token)).To(Succeed())
+			})
+			reconcileBoth()
+			Expect(meta.FindStatusCondition(deriverMP.Status.Conditions, "Ready").Reason).To(Equal("DeriverReady"))
+			Expect(deriverMP.Status.SecretName).To(BeEmpty())
+			Expect(meta.IsStatusConditionTrue(ds.Status.Conditions, "Ready")).To(BeTrue())
+
+			vault, err := deriver.NewVaultTransit(deriver.VaultTransitConfig{
+				Address: transit.URL, Key: "derived", Token: "vault-token",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			want, err := crypto.DeriveSecretWith(ctx, vault,
+				derivationContext(ds, "password", ds.Spec.Keys["password"]), 26)
+			Expect(err).NotTo(HaveOccurred())
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(ds), secret)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, secret)).To(Succeed())
+			})
+			Expect(string(secret.Data["password"])).To(Equal(want))
+
+			By("Fingerprinting with a key derived through Vault")
+			fingerprintKey, err := crypto.FingerprintKey(ctx, vault)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(deriverMP.Status.PasswordFingerprint).To(Equal(crypto.MasterFingerprint(fingerprintKey)))
+			Expect(crypto.VerifyFingerprint(fingerprintKey, crypto.BuildContext(ds.Namespace, ds.Name, "password"),
+				want, ds.Status.KeyFingerprints["password"])).To(BeTrue())
+
+			By("Reusing the Vault client until the MasterPassword changes")
+			cached := derivers.derivers[deriverName.Name].deriver
+			reconcileBoth()
+			Expect(derivers.derivers).To(HaveLen(1))
+			Expect(derivers.derivers[deriverName.Name].deriver).To(BeIdenticalTo(cached))
+			meta.SetStatusCondition(&deriverMP.Status.Conditions, metav1.Condition{
+				Type: "Audited", Status: metav1.ConditionTrue, Reason: "Audited",
+			})
+			Expect(k8sClient.Status().Update(ctx, deriverMP)).To(Succeed())
+			reconcileBoth()
+			Expect(derivers.derivers[deriverName.Name].deriver).To(BeIdenticalTo(cached))
+
+			By("Retrying an unreachable Vault")
+			unreachable := httptest.NewServer(http.NotFoundHandler())
+			unreachable.Close()
+			Expect(k8sClient.Get(ctx, deriverName, deriverMP)).To(Succeed())
+			deriverMP.Spec.Deriver.Vault.Address = unreachable.URL
+			Expect(k8sClient.Update(ctx, deriverMP)).To(Succeed())
+			_, err = mpReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: deriverName})
+			Expect(err).To(HaveOccurred())
+			_, err = dsReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(ds)})
+			Expect(err).To(HaveOccurred())
+			Expect(k8sClient.Get(ctx, deriverName, deriverMP)).To(Succeed())
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(ds), ds)).To(Succeed())
+			Expect(meta.FindStatusCondition(deriverMP.Status.Conditions, "Ready").Reason).To(Equal("DeriverUnavailable"))
+			Expect(meta.FindStatusCondition(ds.Status.Conditions, "Ready").Reason).To(Equal("ReconciliationFailed"))
+			Expect(meta.IsStatusConditionTrue(ds.Status.Conditions, "Pending")).To(BeFalse())
+			Expect(derivers.derivers[deriverName.Name].deriver).NotTo(BeIdenticalTo(cached))
+
+			By("Reporting PKCS#11 backends the operator was built without")
+			if !deriver.PKCS11Supported {
+				Expect(k8sClient.Get(ctx, deriverName, deriverMP)).To(Succeed())
+				deriverMP.Spec.Deriver = &secretsv1beta1.DeriverSpec{
+					PKCS11: &secretsv1beta1.PKCS11Deriver{
+						Module:       "/usr/lib/softhsm/libsofthsm2.so",
+						TokenLabel:   "derived",
+						KeyLabel:     "derived-key",
+						PINSecretRef: secretsv1beta1.SecretKeyReference{Name: "pin", Namespace: "default", Key: "pin"},
+					},
+				}
+				Expect(k8sClient.Update(ctx, deriverMP)).To(Succeed())
+				reconcileBoth()
+				Expect(meta.FindStatusCondition(deriverMP.Status.Conditions, "Ready").Reason).
+					To(Equal("PKCS11Unsupported"))
+			}
+		})
+
+		It("should unseal a sealed master password into its secret", func() {
+			mpReconciler := &MasterPasswordReconciler{
+				Client:            k8sClient,
+				Scheme:            k8sClient.Scheme(),
+				OperatorNamespace: "default",
+			}
+			_, err := mpReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mpReconciler.publishSealingKey(ctx)).To(Succeed())
+
+			By("Sealing a master password to the published public key")
+			configMap := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: sealingKeyName, Namespace: "default"}, configMap)).
+				To(Succeed())
+			sealedName := types.NamespacedName{Name: "sealed-resource"}
+			sealed, err := crypto.Seal(configMap.Data[sealingPublicKeyKey], sealedName.Name,
+				[]byte("sealed-master-password-for-testing"))
+			Expect(err).NotTo(HaveOccurred())
+			sealedMP := &secretsv1beta1.MasterPassword{
+				ObjectMeta: metav1.ObjectMeta{Name: sealedName.Name},
+				Spec:       secretsv1beta1.MasterPasswordSpec{SealedValue: sealed},
+			}
+			Expect(k8sClient.Create(ctx, sealedMP)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, sealedMP)).To(Succeed())
+			})
+			_, err = mpReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: sealedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			secret := &corev1.Secret{}
+			secretKey := types.NamespacedName{Name: sealedName.Name + "-mp", Namespace: "default"}
+			Expect(k8sClient.Get(ctx, secretKey, secret)).To(Succeed())
+			Expect(secret.Data[masterPasswordKey]).To(Equal([]byte("sealed-master-password-for-testing")))
+			Expect(secretGeneration(secret)).To(Equal(1))
+
+			By("Replacing the master password with a new sealed value")
+			Expect(k8sClient.Get(ctx, sealedName, sealedMP)).To(Succeed())
+			sealedMP.Spec.SealedValue, err = crypto.Seal(configMap.Data[sealingPublicKeyKey], sealedName.Name,
+				[]byte("resealed-master-password"))
+			Expect(err).NotTo(HaveOccurred())
+			sealedMP.Annotations = map[string]string{secretsv1beta1.RegenerateAnnotation: "1"}
+			Expect(k8sClient.Update(ctx, sealedMP)).To(Succeed())
+			_, err = mpReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: sealedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, secretKey, secret)).To(Succeed())
+			Expect(secret.Data[masterPasswordKey]).To(Equal([]byte("resealed-master-password")))
+			Expect(secret.Data[masterPasswordKey+secretsv1beta1.PreviousMasterPasswordSuffix]).
+				To(Equal([]byte("sealed-master-password-for-testing")))
+			Expect(secretGeneration(secret)).To(Equal(2))
+
+			By("Refusing to regenerate a sealed master password")
+			Expect(k8sClient.Get(ctx, sealedName, sealedMP)).To(Succeed())
+			condition := meta.FindStatusCondition(sealedMP.Status.Conditions, "Generation")
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Reason).To(Equal("SealedMasterPassword"))
+
+			By("Reporting values sealed to another key")
+			_, otherKey, err := crypto.GenerateSealingKey()
+			Expect(err).NotTo(HaveOccurred())
+			sealedMP.Spec.SealedValue, err = crypto.Seal(otherKey, sealedName.Name, []byte("another-master-password"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Update(ctx, sealedMP)).To(Succeed())
+			_, err = mpReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: sealedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, sealedName, sealedMP)).To(Succeed())
+			Expect(meta.FindStatusCondition(sealedMP.Status.Conditions, "Ready").Reason).To(Equal("UnsealFailed"))
+
+			By("Reporting values sealed for another MasterPassword")
+			sealedMP.Spec.SealedValue, err = crypto.Seal(configMap.Data[sealingPublicKeyKey], "another-resource",
+				[]byte("another-master-password"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Update(ctx, sealedMP)).To(Succeed())
+			_, err = mpReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: sealedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, sealedName, sealedMP)).To(Succeed())
+			Expect(meta.FindStatusCondition(sealedMP.Status.Conditions, "Ready").Reason).To(Equal("UnsealFailed"))
+			Expect(k8sClient.Get(ctx, secretKey, secret)).To(Succeed())
+			Expect(secret.Data[masterPasswordKey]).To(Equal([]byte("resealed-master-password")))
+		})
+
+		It("should encrypt the master password secret with a KMS", func() {
+			localKey, err := kms.GenerateLocalKey()
+			Expect(err).NotTo(HaveOccurred())
+			localService, err := kms.NewLocalService(localKey)
+			Expect(err).NotTo(HaveOccurred())
+			kmsClient := kms.NewClient(localService, time.Minute)
+			mpReconciler := &MasterPasswordReconciler{
+				Client:            k8sClient,
+				Scheme:            k8sClient.Scheme(),
+				OperatorNamespace: "default",
+			}
+
+			kmsName := types.NamespacedName{Name: "kms-resource"}
+			kmsMP := &secretsv1beta1.MasterPassword{ObjectMeta: metav1.ObjectMeta{Name: kmsName.Name}}
+			Expect(k8sClient.Create(ctx, kmsMP)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, kmsMP)).To(Succeed())
+			})
+			reconcileKMS := func(encryption secretsv1beta1.MasterPasswordEncryption) *corev1.Secret {
+				Expect(k8sClient.Get(ctx, kmsName, kmsMP)).To(Succeed())
+				kmsMP.Spec.Encryption = encryption
+				Expect(k8sClient.Update(ctx, kmsMP)).To(Succeed())
+				_, err := mpReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: kmsName})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(k8sClient.Get(ctx, kmsName, kmsMP)).To(Succeed())
+				secret := &corev1.Secret{}
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: kmsName.Name + "-mp", Namespace: "default"}, secret)).
+					To(Succeed())
+				return secret
+			}
+			plaintext := reconcileKMS("").Data[masterPasswordKey]
+
+			By("Reporting KMS encryption without a KMS plugin")
+			secret := reconcileKMS(secretsv1beta1.MasterPasswordEncryptionKMS)
+			Expect(meta.FindStatusCondition(kmsMP.Status.Conditions, "Ready").Reason).To(Equal("KMSUnavailable"))
+			Expect(secret.Data[masterPasswordKey]).To(Equal(plaintext))
+
+			By("Encrypting the master password in place")
+			mpReconciler.KMS = kmsClient
+			secret = reconcileKMS(secretsv1beta1.MasterPasswordEncryptionKMS)
+			Expect(meta.IsStatusConditionTrue(kmsMP.Status.Conditions, "Ready")).To(BeTrue())
+			Expect(kms.IsEnvelope(secret.Data[masterPasswordKey])).To(BeTrue())
+			Expect(masterPasswordFingerprint(ctx, string(plaintext))).To(Equal(kmsMP.Status.PasswordFingerprint))
+			history := &corev1.Secret{}
+			historyKey := types.NamespacedName{Name: masterPasswordHistorySecretName(secret.Name), Namespace: "default"}
+			Expect(k8sClient.Get(ctx, historyKey, history)).To(Succeed())
+			for _, value := range history.Data {
+				Expect(kms.IsEnvelope(value)).To(BeTrue())
+			}
+
+			By("Decrypting the master password for derivation")
+			codec := newMasterPasswordCodec(kmsClient, kmsMP)
+			password, generation, err := readMasterPasswordGeneration(ctx, k8sClient,
+				client.ObjectKeyFromObject(secret), masterPasswordKey, codec, 0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(password).To(Equal(string(plaintext)))
+			Expect(generation).To(Equal(1))
+			_, _, err = readMasterPasswordGeneration(ctx, k8sClient,
+				client.ObjectKeyFromObject(secret), masterPasswordKey, masterPasswordCodec{}, 0)
+			Expect(err).To(MatchError(ContainSubstring("no KMS plugin")))
+
+			By("Encrypting regenerated master passwords")
+			Expect(k8sClient.Get(ctx, kmsName, kmsMP)).To(Succeed())
+			kmsMP.Annotations = map[string]string{secretsv1beta1.RegenerateAnnotation: "1"}
+			Expect(k8sClient.Update(ctx, kmsMP)).To(Succeed())
+			secret = reconcileKMS(secretsv1beta1.MasterPasswordEncryptionKMS)
+			Expect(secretGeneration(secret)).To(Equal(2))
+			Expect(kms.IsEnvelope(secret.Data[masterPasswordKey])).To(BeTrue())
+
+			By("Decrypting the master password once encryption is turned off")
+			secret = reconcileKMS(secretsv1beta1.MasterPasswordEncryptionNone)
+			Expect(kms.IsEnvelope(secret.Data[masterPasswordKey])).To(BeFalse())
+			Expect(secret.Data[masterPasswordKey]).NotTo(Equal(plaintext))
+			Expect(secret.Data[masterPasswordKey+secretsv1beta1.PreviousMasterPasswordSuffix]).To(Equal(plaintext))
+			Expect(k8sClient.Get(ctx, historyKey, history)).To(Succeed())
+			Expect(history.Data["1"]).To(Equal(plaintext))
+		})
+
+		It("should regenerate the master password on request and roll it back from the history", func() {
+			mpReconciler := &MasterPasswordReconciler{
+				Client:            k8sClient,
+				Scheme:            k8sClient.Scheme(),
+				OperatorNamespace: "default",
+			}
+			resource := &secretsv1beta1.MasterPassword{}
+			secret := &corev1.Secret{}
+			secretKey := types.NamespacedName{Name: resourceName + "-mp", Namespace: "default"}
+			request := func(annotation, value string) {
+				Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+				if resource.Annotations == nil {
+					resource.Annotations = map[string]string{}
+				}
+				resource.Annotations[annotation] = value
+				resource.Spec.HistoryLimit = 2
+				Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+				_, err := mpReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+				Expect(k8sClient.Get(ctx, secretKey, secret)).To(Succeed())
+			}
+			generationReason := func() string {
+				condition := meta.FindStatusCondition(resource.Status.Conditions, "Generation")
+				Expect(condition).NotTo(BeNil())
+				return condition.Reason
+			}
+
+			_, err := mpReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, secretKey, secret)).To(Succeed())
+			first := secretGeneration(secret)
+			firstPassword := secret.Data[masterPasswordKey]
+
+			By("Regenerating the master password")
+			request(secretsv1beta1.RegenerateAnnotation, "1")
+			Expect(generationReason()).To(Equal("Regenerated"))
+			Expect(secretGeneration(secret)).To(Equal(first + 1))
+			Expect(secret.Data[masterPasswordKey]).NotTo(Equal(firstPassword))
+			Expect(resource.Status.PrimaryGeneration).To(Equal(first + 1))
+			Expect(resource.Status.HistoryGenerations).To(Equal([]int{first, first + 1}))
+
+			By("Applying each regenerate request once")
+			_, err = mpReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, secretKey, secret)).To(Succeed())
+			Expect(secretGeneration(secret)).To(Equal(first + 1))
+
+			By("Keeping only the most recent generations in the history")
+			request(secretsv1beta1.RegenerateAnnotation, "2")
+			Expect(secretGeneration(secret)).To(Equal(first + 2))
+			Expect(resource.Status.HistoryGenerations).To(Equal([]int{first + 1, first + 2}))
+			history := &corev1.Secret{}
+			historyKey := types.NamespacedName{Name: masterPasswordHistorySecretName(secretKey.Name), Namespace: "default"}
+			Expect(k8sClient.Get(ctx, historyKey, history)).To(Succeed())
+			Expect(history.Data).NotTo(HaveKey(strconv.Itoa(first)))
+
+			By("Refusing to roll back to a generation dropped from the history")
+			request(secretsv1beta1.RollbackAnnotation, strconv.Itoa(first))
+			Expect(generationReason()).To(Equal("GenerationUnavailable"))
+			Expect(secretGeneration(secret)).To(Equal(first + 2))
+
+			By("Rolling back to a generation kept in the history")
+			request(secretsv1beta1.RollbackAnnotation, strconv.Itoa(first+1))
+			Expect(generationReason()).To(Equal("RolledBack"))
+			Expect(secretGeneration(secret)).To(Equal(first + 3))
+			Expect(secret.Data[masterPasswordKey]).To(Equal(history.Data[strconv.Itoa(first+1)]))
+
+			By("Refusing to roll back to the primary generation")
+			request(secretsv1beta1.RollbackAnnotation, strconv.Itoa(first+3))
+			Expect(generationReason()).To(Equal("GenerationIsPrimary"))
+			Expect(secretGeneration(secret)).To(Equal(first + 3))
+
+			By("Leaving the history alone when the previous generation does not fit into it")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.HistoryLimit = 1
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			_, err = mpReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, historyKey, history)).To(Succeed())
+			Expect(history.Data).To(HaveLen(1))
+			Expect(history.Data).To(HaveKey(strconv.Itoa(first + 3)))
+			resourceVersion := history.ResourceVersion
+			_, err = mpReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, historyKey, history)).To(Succeed())
+			Expect(history.ResourceVersion).To(Equal(resourceVersion))
+		})
+
+		It("should only serve the primary and previous generations to DerivedSecrets", func() {
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "generations-mp",
+					Namespace:   "default",
+					Labels:      map[string]string{managedByLabel: managedByValue},
+					Annotations: map[string]string{secretsv1beta1.MasterPasswordGenerationAnnotation: "3"},
+				},
+				Data: map[string][]byte{
+					masterPasswordKey: []byte("third"),
+					masterPasswordKey + secretsv1beta1.PreviousMasterPasswordSuffix: []byte("second"),
+				},
+			}
+			history := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: masterPasswordHistorySecretName(secret.Name), Namespace: "default"},
+				Data:       map[string][]byte{"1": []byte("first"), "2": []byte("second"), "3": []byte("third")},
+			}
+			for _, s := range []*corev1.Secret{secret, history} {
+				Expect(k8sClient.Create(ctx, s)).To(Succeed())
+				DeferCleanup(func() {
+					Expect(k8sClient.Delete(ctx, s)).To(Succeed())
+				})
+			}
+
+			read := func(generation int) (string, error) {
+				password, _, err := readMasterPasswordGeneration(ctx, k8sClient, client.ObjectKeyFromObject(secret),
+					masterPasswordKey, masterPasswordCodec{}, generation)
+				return password, err
+			}
+			Expect(read(3)).To(Equal("third"))
+			Expect(read(2)).To(Equal("second"))
+			_, err := read(1)
+			Expect(err).To(BeAssignableToTypeOf(&conflictError{}))
+			Expect(err.(*conflictError).reason).To(Equal("GenerationUnavailable"))
+		})
+
+		It("should migrate namespaces to a new primary master password in batches", func() {
+			const otherNamespace = "migrate-b"
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: otherNamespace}}
+			if err := k8sClient.Create(ctx, ns); err != nil {
+				Expect(errors.IsAlreadyExists(err)).To(BeTrue())
+			}
+
+			resource := &secretsv1beta1.MasterPassword{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Migration = &secretsv1beta1.MigrationSpec{
+				BatchSize: 1,
+				Interval:  metav1.Duration{Duration: time.Hour},
+			}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			mpReconciler := &MasterPasswordReconciler{
+				Client:            k8sClient,
+				Scheme:            k8sClient.Scheme(),
+				OperatorNamespace: "default",
+			}
+			reconcileMasterPassword := func() time.Duration {
+				result, err := mpReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+				return result.RequeueAfter
+			}
+			reconcileMasterPassword()
+			secretKey := types.NamespacedName{Name: resourceName + "-mp", Namespace: "default"}
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, secretKey, secret)).To(Succeed())
+			primary := secretGeneration(secret)
+			Expect(resource.Status.PrimaryGeneration).To(Equal(primary))
+
+			By("Creating DerivedSecrets in two namespaces")
+			dsReconciler := &DerivedSecretReconciler{
+				Client:            k8sClient,
+				Scheme:            k8sClient.Scheme(),
+				OperatorNamespace: "default",
+			}
+			var derivedSecrets []*secretsv1beta1.DerivedSecret
+			for _, namespace := range []string{"default", otherNamespace} {
+				ds := &secretsv1beta1.DerivedSecret{
+					ObjectMeta: metav1.ObjectMeta{Name: "migrated-app", Namespace: namespace},
+					Spec: secretsv1beta1.DerivedSecretSpec{
+						MasterPassword: resourceName,
+						Keys: map[string]secretsv1beta1.DerivedKeySpec{
+							"password": {Type: secretsv1beta1.SecretTypePassword},
+						},
+					},
+				}
+				Expect(k8sClient.Create(ctx, ds)).To(Succeed())
+				DeferCleanup(func() {
+					Expect(k8sClient.Delete(ctx, ds)).To(Succeed())
+					Expect(k8sClient.Delete(ctx, &corev1.Secret{ObjectMeta: ds.ObjectMeta})).To(Succeed())
+				})
+				derivedSecrets = append(derivedSecrets, ds)
+			}
+			derivedGeneration := func(ds *secretsv1beta1.DerivedSecret) int {
+				_, err := dsReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(ds)})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(ds), ds)).To(Succeed())
+				return ds.Status.MasterPasswordGenerations[resourceName]
+			}
+
+			By("Regenerating the master password")
+			Expect(regenerateMasterPassword(ctx, k8sClient, secretKey, masterPasswordKey, masterPasswordCodec{},
+				"", 0, nil)).To(Succeed())
+			Expect(derivedGeneration(derivedSecrets[1])).To(Equal(primary))
+
+			By("Migrating the first batch")
+			Expect(reconcileMasterPassword()).To(Equal(time.Hour))
+			Expect(resource.Status.PrimaryGeneration).To(Equal(primary + 1))
+			Expect(resource.Status.PreviousGeneration).To(Equal(primary))
+			Expect(resource.Status.Migration.MigratedNamespaces).To(Equal([]string{"default"}))
+			Expect(resource.Status.Migration.TotalNamespaces).To(Equal(2))
+			Expect(derivedGeneration(derivedSecrets[0])).To(Equal(primary + 1))
+			Expect(derivedGeneration(derivedSecrets[1])).To(Equal(primary))
+
+			By("Pinning a DerivedSecret to the new generation")
+			derivedSecrets[1].Annotations = map[string]string{
+				secretsv1beta1.PinnedGenerationAnnotation: strconv.Itoa(primary + 1),
+			}
+			Expect(k8sClient.Update(ctx, derivedSecrets[1])).To(Succeed())
+			Expect(derivedGeneration(derivedSecrets[1])).To(Equal(primary + 1))
+
+			By("Refusing a generation the secret no longer holds")
+			derivedSecrets[1].Spec.MasterPasswordGeneration = primary + 5
+			Expect(k8sClient.Update(ctx, derivedSecrets[1])).To(Succeed())
+			derivedGeneration(derivedSecrets[1])
+			pending := meta.FindStatusCondition(derivedSecrets[1].Status.Conditions, "Pending")
+			Expect(pending).NotTo(BeNil())
+			Expect(pending.Reason).To(Equal("GenerationUnavailable"))
+			Expect(meta.FindStatusCondition(derivedSecrets[1].Status.Conditions, "Conflict")).To(BeNil())
+
+			By("Holding back a regeneration until the migration completes")
+			resource.Annotations = map[string]string{secretsv1beta1.RegenerateAnnotation: "during-migration"}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			reconcileMasterPassword()
+			generation := meta.FindStatusCondition(resource.Status.Conditions, "Generation")
+			Expect(generation).NotTo(BeNil())
+			Expect(generation.Reason).To(Equal("MigrationInProgress"))
+			Expect(resource.Status.PrimaryGeneration).To(Equal(primary + 1))
+			Expect(resource.Status.Migration.FromGeneration).To(Equal(primary))
+			Expect(resource.Status.Migration.MigratedNamespaces).To(Equal([]string{"default"}))
+
+			By("Applying the regeneration once the migration completed")
+			resource.Spec.Migration = nil
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			reconcileMasterPassword()
+			Expect(resource.Status.Migration.CompletedAt).NotTo(BeNil())
+			reconcileMasterPassword()
+			generation = meta.FindStatusCondition(resource.Status.Conditions, "Generation")
+			Expect(generation.Reason).To(Equal("Regenerated"))
+			Expect(resource.Status.PrimaryGeneration).To(Equal(primary + 2))
+		})
 	})
 })
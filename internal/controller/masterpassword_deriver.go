@@ -0,0 +1,203 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	secretsv1beta1 "github.com/oleksiyp/derived-secret-operator/api/v1beta1"
+	"github.com/oleksiyp/derived-secret-operator/internal/crypto"
+	"github.com/oleksiyp/derived-secret-operator/internal/deriver"
+)
+
+// DeriverCache holds the derivers of MasterPasswords with a derivation backend, so their
+// connections are reused across reconciles. A deriver is replaced when the spec of its
+// MasterPassword or its credential Secret changes
+type DeriverCache struct {
+	mu sync.Mutex
+	// derivers holds the deriver of each MasterPassword, by name
+	derivers map[string]cachedDeriver
+}
+
+// cachedDeriver is a deriver and the versions of the objects it was built from
+type cachedDeriver struct {
+	version string
+	deriver crypto.Deriver
+}
+
+// NewDeriverCache returns an empty DeriverCache
+func NewDeriverCache() *DeriverCache {
+	return &DeriverCache{derivers: map[string]cachedDeriver{}}
+}
+
+// Forget closes and drops the deriver of the named MasterPassword, if any
+func (c *DeriverCache) Forget(name string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.derivers[name]; ok {
+		closeDeriver(cached.deriver)
+		delete(c.derivers, name)
+	}
+}
+
+// backendDeriver returns the Deriver of a MasterPassword whose secrets are derived by an external
+// backend, reusing the cached one while the MasterPassword spec and its credential are unchanged. A nil
+// cache builds a new deriver on every call.
+// Missing credentials and an unsupported backend are conflicts until fixed; an unreachable
+// backend is an error, so it is retried with backoff
+func (c *DeriverCache) backendDeriver(
+	ctx context.Context,
+	reader client.Reader,
+	mp *secretsv1beta1.MasterPassword,
+) (crypto.Deriver, error) {
+	spec := mp.Spec.Deriver
+	ref := deriverCredentialRef(spec)
+	switch {
+	case ref == nil:
+		return nil, deriverUnavailable("no derivation backend is configured")
+	case spec.PKCS11 != nil && !deriver.PKCS11Supported:
+		return nil, &conflictError{
+			reason:  "PKCS11Unsupported",
+			message: "the operator was built without PKCS#11 support",
+		}
+	}
+	credential, credentialVersion, err := readDeriverCredential(ctx, reader, *ref)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return newBackendDeriver(spec, credential)
+	}
+
+	// Status updates leave the generation alone, so they keep the deriver
+	version := fmt.Sprintf("%s/%d/%s", mp.UID, mp.Generation, credentialVersion)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached, ok := c.derivers[mp.Name]
+	if ok && cached.version == version {
+		return cached.deriver, nil
+	}
+	d, err := newBackendDeriver(spec, credential)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		closeDeriver(cached.deriver)
+	}
+	c.derivers[mp.Name] = cachedDeriver{version: version, deriver: d}
+	return d, nil
+}
+
+// newBackendDeriver returns the Deriver of a derivation backend authenticated with credential
+func newBackendDeriver(spec *secretsv1beta1.DeriverSpec, credential string) (crypto.Deriver, error) {
+	if vault := spec.Vault; vault != nil {
+		d, err := deriver.NewVaultTransit(deriver.VaultTransitConfig{
+			Address:    vault.Address,
+			Mount:      vault.Mount,
+			Key:        vault.Key,
+			KeyVersion: vault.KeyVersion,
+			Namespace:  vault.Namespace,
+			Token:      credential,
+			CABundle:   vault.CABundle,
+		})
+		if err != nil {
+			return nil, deriverUnavailable("%v", err)
+		}
+		return d, nil
+	}
+	p11 := spec.PKCS11
+	d, err := deriver.NewPKCS11(p11.Module, p11.TokenLabel, p11.KeyLabel, credential)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PKCS#11 token %s: %w", p11.TokenLabel, err)
+	}
+	return d, nil
+}
+
+// closeDeriver releases the connections of a deriver that holds any
+func closeDeriver(d crypto.Deriver) {
+	if closer, ok := d.(io.Closer); ok {
+		_ = closer.Close()
+	}
+}
+
+// readDeriverCredential reads the credential of a derivation backend from a Secret key, and
+// returns it with the resource version of the Secret
+func readDeriverCredential(
+	ctx context.Context,
+	c client.Reader,
+	ref secretsv1beta1.SecretKeyReference,
+) (string, string, error) {
+	key := types.NamespacedName{Name: ref.Name, Namespace: ref.Namespace}
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, key, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", "", deriverUnavailable("secret %s does not exist", key)
+		}
+		return "", "", fmt.Errorf("failed to get deriver credential secret %s: %w", key, err)
+	}
+	value, ok := secret.Data[ref.Key]
+	if !ok || len(value) == 0 {
+		return "", "", deriverUnavailable("secret %s has no key %s", key, ref.Key)
+	}
+	return string(value), secret.ResourceVersion, nil
+}
+
+// deriverUnavailable returns the conflict reported when a derivation backend is misconfigured
+func deriverUnavailable(format string, args ...any) error {
+	return &conflictError{reason: "DeriverUnavailable", message: fmt.Sprintf(format, args...)}
+}
+
+// deriverFingerprint derives the fingerprint key with a derivation backend to check it works, and
+// returns the fingerprint of the backend key. It changes whenever the backend key does.
+func deriverFingerprint(ctx context.Context, d crypto.Deriver) (string, error) {
+	key, err := crypto.FingerprintKey(ctx, d)
+	if err != nil {
+		return "", fmt.Errorf("derivation backend is unavailable: %w", err)
+	}
+	return crypto.MasterFingerprint(key), nil
+}
+
+// deriverCredentialRef returns the Secret key holding the credential of the derivation backend,
+// or nil
+func deriverCredentialRef(spec *secretsv1beta1.DeriverSpec) *secretsv1beta1.SecretKeyReference {
+	switch {
+	case spec == nil:
+		return nil
+	case spec.Vault != nil:
+		return &spec.Vault.TokenSecretRef
+	case spec.PKCS11 != nil:
+		return &spec.PKCS11.PINSecretRef
+	}
+	return nil
+}
+
+// referencesDeriverSecret reports whether the secret holds a credential of the derivation backend
+func referencesDeriverSecret(spec *secretsv1beta1.DeriverSpec, secret *corev1.Secret) bool {
+	ref := deriverCredentialRef(spec)
+	return ref != nil && ref.Name == secret.Name && ref.Namespace == secret.Namespace
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	secretsv1beta1 "github.com/oleksiyp/derived-secret-operator/api/v1beta1"
+	"github.com/oleksiyp/derived-secret-operator/internal/kms"
+)
+
+// masterPasswordCodec encodes master passwords as they are stored in their secret: as KMS
+// envelopes when encrypt is set, or as plain text. Envelopes are decoded whatever encrypt is,
+// so changing the encryption of a MasterPassword never makes its secret unreadable
+type masterPasswordCodec struct {
+	// kms encrypts and decrypts envelopes; nil if the operator has no KMS plugin
+	kms     *kms.Client
+	encrypt bool
+}
+
+// newMasterPasswordCodec returns the codec of the master passwords of a MasterPassword
+func newMasterPasswordCodec(kmsClient *kms.Client, mp *secretsv1beta1.MasterPassword) masterPasswordCodec {
+	return masterPasswordCodec{kms: kmsClient, encrypt: mp.Spec.Encrypted()}
+}
+
+// kmsUnavailable returns the conflict reported when an envelope is used without a KMS plugin
+func kmsUnavailable() error {
+	return &conflictError{
+		reason:  "KMSUnavailable",
+		message: "the master password is encrypted with a KMS but the operator has no KMS plugin configured",
+	}
+}
+
+// encode returns the value storing password in a secret
+func (c masterPasswordCodec) encode(ctx context.Context, password []byte) ([]byte, error) {
+	if !c.encrypt {
+		return password, nil
+	}
+	if c.kms == nil {
+		return nil, kmsUnavailable()
+	}
+	value, err := c.kms.Encrypt(ctx, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt master password: %w", err)
+	}
+	return value, nil
+}
+
+// decode returns the master password stored as value in a secret. Decrypted envelopes are
+// cached briefly by the KMS client, so deriving secrets does not call the plugin every time
+func (c masterPasswordCodec) decode(ctx context.Context, value []byte) ([]byte, error) {
+	if !kms.IsEnvelope(value) {
+		return value, nil
+	}
+	if c.kms == nil {
+		return nil, kmsUnavailable()
+	}
+	password, err := c.kms.Decrypt(ctx, value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt master password: %w", err)
+	}
+	return password, nil
+}
+
+// recode returns value stored the way the codec stores master passwords, and whether it changed
+func (c masterPasswordCodec) recode(ctx context.Context, value []byte) ([]byte, bool, error) {
+	if kms.IsEnvelope(value) == c.encrypt {
+		return value, false, nil
+	}
+	password, err := c.decode(ctx, value)
+	if err != nil {
+		return nil, false, err
+	}
+	value, err = c.encode(ctx, password)
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// recodeMasterPasswordSecret encrypts the master passwords of a secret generated by the operator
+// in place, or decrypts them once encryption is turned off: the primary and previous generations
+// and every generation kept in the history secret. Imported secrets are left as they are, since
+// their owners may read them too
+func recodeMasterPasswordSecret(
+	ctx context.Context,
+	c client.Client,
+	key types.NamespacedName,
+	dataKey string,
+	codec masterPasswordCodec,
+) error {
+	recodeKeys := func(secret *corev1.Secret, keys func(dataKey string) bool) (bool, error) {
+		changed := false
+		for k, value := range secret.Data {
+			if !keys(k) {
+				continue
+			}
+			recoded, ok, err := codec.recode(ctx, value)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				secret.Data[k] = recoded
+				changed = true
+			}
+		}
+		return changed, nil
+	}
+
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, key, secret); err != nil {
+		return fmt.Errorf("failed to get master password secret %s: %w", key, err)
+	}
+	if secret.Labels[managedByLabel] != managedByValue {
+		return nil
+	}
+	changed, err := recodeKeys(secret, func(k string) bool {
+		return k == dataKey || k == dataKey+secretsv1beta1.PreviousMasterPasswordSuffix
+	})
+	if err != nil {
+		return err
+	}
+	if changed {
+		if err := c.Update(ctx, secret); err != nil {
+			return fmt.Errorf("failed to update master password secret %s: %w", key, err)
+		}
+		logf.FromContext(ctx).Info("Re-encoded master password secret", "secret", key.String(),
+			"encrypted", codec.encrypt)
+	}
+
+	history := &corev1.Secret{}
+	historyKey := types.NamespacedName{Name: masterPasswordHistorySecretName(key.Name), Namespace: key.Namespace}
+	if err := c.Get(ctx, historyKey, history); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	changed, err = recodeKeys(history, func(string) bool { return true })
+	if err != nil {
+		return err
+	}
+	if changed {
+		if err := c.Update(ctx, history); err != nil {
+			return fmt.Errorf("failed to update master password history secret %s: %w", historyKey, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,542 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	secretsv1beta1 "github.com/oleksiyp/derived-secret-operator/api/v1beta1"
+	"github.com/oleksiyp/derived-secret-operator/internal/crypto"
+	"github.com/oleksiyp/derived-secret-operator/internal/policy"
+)
+
+const (
+	masterPasswordKey = secretsv1beta1.DefaultMasterPasswordKey
+	defaultLength     = 86
+
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedByValue = "derived-secret-operator"
+
+	// weakMasterPasswordReason is the reason, and the condition type, reporting a master password
+	// that does not meet the validation policy of its MasterPassword
+	weakMasterPasswordReason = policy.ReasonWeakMasterPassword
+)
+
+// masterPasswordSecretName returns the name of the secret holding the master password
+// of the MasterPassword or NamespaceMasterPassword with the given name
+func masterPasswordSecretName(name string, ref *secretsv1beta1.SecretReference) string {
+	if ref != nil && ref.Name != "" {
+		return ref.Name
+	}
+	return name + "-mp"
+}
+
+// masterPasswordSecretNamespace returns the namespace of the secret holding the master password
+// of a MasterPassword, defaulting to the operator namespace
+func masterPasswordSecretNamespace(ref *secretsv1beta1.SecretReference, operatorNamespace string) string {
+	if ref != nil && ref.Namespace != "" {
+		return ref.Namespace
+	}
+	return operatorNamespace
+}
+
+// generateMasterPassword generates a master password in the given format. Base62 master
+// passwords have the given length, defaulting to defaultLength
+func generateMasterPassword(format secretsv1beta1.MasterPasswordFormat, length int) (string, error) {
+	if format == secretsv1beta1.MasterPasswordFormatMnemonic {
+		return crypto.GenerateMnemonic()
+	}
+	if length == 0 {
+		length = defaultLength
+	}
+	return crypto.GenerateRandomPassword(length)
+}
+
+// checkMasterPassword checks a master password with policy.CheckMasterPassword before it is used
+// and returns it in canonical form. A rejected master password is a conflict
+func checkMasterPassword(mp *secretsv1beta1.MasterPassword, password string) (string, error) {
+	normalized, err := policy.CheckMasterPassword(&mp.Spec, password)
+	var rejected *policy.Error
+	if errors.As(err, &rejected) {
+		return "", &conflictError{
+			reason:  rejected.Reason,
+			message: fmt.Sprintf("MasterPassword %s: %v", mp.Name, rejected.Err),
+		}
+	}
+	return normalized, err
+}
+
+// masterPasswordFingerprint returns the fingerprint recorded in the status of a master password.
+func masterPasswordFingerprint(ctx context.Context, password string) (string, error) {
+	key, err := crypto.FingerprintKey(ctx, crypto.NewArgon2idDeriver(password))
+	if err != nil {
+		return "", fmt.Errorf("failed to fingerprint master password: %w", err)
+	}
+	return crypto.MasterFingerprint(key), nil
+}
+
+// ensureMasterPasswordSecret ensures the master password secret exists and is up to date,
+// generating a master password in the given format, stored by codec, if the secret may be created
+func ensureMasterPasswordSecret(
+	ctx context.Context,
+	c client.Client,
+	key types.NamespacedName,
+	ref *secretsv1beta1.SecretReference,
+	codec masterPasswordCodec,
+	format secretsv1beta1.MasterPasswordFormat,
+	length int,
+	annotations map[string]string,
+) error {
+	log := logf.FromContext(ctx)
+	dataKey := ref.DataKey()
+
+	secret := &corev1.Secret{}
+	err := c.Get(ctx, key, secret)
+
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get secret: %w", err)
+		}
+
+		// Secret doesn't exist, check if we should create it
+		if ref != nil && !ref.Create {
+			return fmt.Errorf("secret %s does not exist and create is false", key)
+		}
+
+		// Generate a new master password
+		password, err := generateMasterPassword(format, length)
+		if err != nil {
+			return fmt.Errorf("failed to generate master password: %w", err)
+		}
+		value, err := codec.encode(ctx, []byte(password))
+		if err != nil {
+			return err
+		}
+
+		// Create the secret
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        key.Name,
+				Namespace:   key.Namespace,
+				Labels:      map[string]string{managedByLabel: managedByValue},
+				Annotations: annotations,
+			},
+			Type: corev1.SecretTypeOpaque,
+			Data: map[string][]byte{
+				dataKey: value,
+			},
+		}
+
+		if err := c.Create(ctx, secret); err != nil {
+			return fmt.Errorf("failed to create secret: %w", err)
+		}
+
+		log.Info("Created master password secret", "secret", key.String())
+		return nil
+	}
+
+	// Secret exists, ensure it has the master password key
+	if _, ok := secret.Data[dataKey]; !ok {
+		return fmt.Errorf("secret %s exists but missing %s key", key, dataKey)
+	}
+
+	// Update annotations if they changed
+	if annotations != nil {
+		needsUpdate := false
+		if secret.Annotations == nil {
+			secret.Annotations = make(map[string]string)
+		}
+		for k, v := range annotations {
+			if secret.Annotations[k] != v {
+				secret.Annotations[k] = v
+				needsUpdate = true
+			}
+		}
+		if needsUpdate {
+			if err := c.Update(ctx, secret); err != nil {
+				return fmt.Errorf("failed to update secret annotations: %w", err)
+			}
+			log.Info("Updated secret annotations", "secret", key.String())
+		}
+	}
+
+	return nil
+}
+
+// ensureUnsealedMasterPasswordSecret ensures the secret holds the given unsealed master password,
+// stored by codec. A changed sealed value becomes a new primary generation, keeping the replaced
+// one as the previous generation
+func ensureUnsealedMasterPasswordSecret(
+	ctx context.Context,
+	c client.Client,
+	key types.NamespacedName,
+	dataKey string,
+	codec masterPasswordCodec,
+	password []byte,
+	annotations map[string]string,
+) error {
+	secret := &corev1.Secret{}
+	err := c.Get(ctx, key, secret)
+	if client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("failed to get secret: %w", err)
+	}
+	if apierrors.IsNotFound(err) {
+		value, err := codec.encode(ctx, password)
+		if err != nil {
+			return err
+		}
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        key.Name,
+				Namespace:   key.Namespace,
+				Labels:      map[string]string{managedByLabel: managedByValue},
+				Annotations: annotations,
+			},
+			Type: corev1.SecretTypeOpaque,
+			Data: map[string][]byte{dataKey: value},
+		}
+		if err := c.Create(ctx, secret); err != nil {
+			return fmt.Errorf("failed to create secret: %w", err)
+		}
+		logf.FromContext(ctx).Info("Created master password secret from sealed value", "secret", key.String())
+		return nil
+	}
+
+	current, err := codec.decode(ctx, secret.Data[dataKey])
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(current, password) {
+		if secret.Annotations == nil {
+			secret.Annotations = make(map[string]string)
+		}
+		needsUpdate := false
+		for k, v := range annotations {
+			if secret.Annotations[k] != v {
+				secret.Annotations[k] = v
+				needsUpdate = true
+			}
+		}
+		if !needsUpdate {
+			return nil
+		}
+		if err := c.Update(ctx, secret); err != nil {
+			return fmt.Errorf("failed to update secret annotations: %w", err)
+		}
+		return nil
+	}
+
+	return updateMasterPassword(ctx, c, key, annotations, func(secret *corev1.Secret) (string, error) {
+		value, err := codec.encode(ctx, password)
+		if err != nil {
+			return "", err
+		}
+		replaceMasterPassword(secret, dataKey, value)
+		return "Replaced master password with the sealed value", nil
+	})
+}
+
+// readMasterPassword reads the master password from the given secret data key, decoding it
+// with codec
+func readMasterPassword(
+	ctx context.Context,
+	c client.Reader,
+	key types.NamespacedName,
+	dataKey string,
+	codec masterPasswordCodec,
+) (string, error) {
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, key, secret); err != nil {
+		return "", fmt.Errorf("failed to get master password secret %s: %w", key, err)
+	}
+
+	passwordBytes, ok := secret.Data[dataKey]
+	if !ok {
+		return "", fmt.Errorf("master password secret %s missing key %s", key, dataKey)
+	}
+	passwordBytes, err := codec.decode(ctx, passwordBytes)
+	if err != nil {
+		return "", err
+	}
+
+	return string(passwordBytes), nil
+}
+
+// secretGeneration returns the generation of the primary master password held by a secret
+func secretGeneration(secret *corev1.Secret) int {
+	generation, err := strconv.Atoi(secret.Annotations[secretsv1beta1.MasterPasswordGenerationAnnotation])
+	if err != nil || generation < 1 {
+		return 1
+	}
+	return generation
+}
+
+// readMasterPasswordGeneration reads the given generation of the master password, which must
+// be the primary or previous one held by the secret. Older generations are only kept in the
+// history secret to be rolled back to, as they may have been replaced for failing the policy.
+// Generation 0 reads the primary one.
+// It returns the master password, decoded with codec, and its generation
+func readMasterPasswordGeneration(
+	ctx context.Context,
+	c client.Reader,
+	key types.NamespacedName,
+	dataKey string,
+	codec masterPasswordCodec,
+	generation int,
+) (string, int, error) {
+	decode := func(value []byte, generation int) (string, int, error) {
+		password, err := codec.decode(ctx, value)
+		if err != nil {
+			return "", 0, err
+		}
+		return string(password), generation, nil
+	}
+
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, key, secret); err != nil {
+		return "", 0, fmt.Errorf("failed to get master password secret %s: %w", key, err)
+	}
+
+	primary := secretGeneration(secret)
+	if generation == 0 || generation == primary {
+		passwordBytes, ok := secret.Data[dataKey]
+		if !ok {
+			return "", 0, fmt.Errorf("master password secret %s missing key %s", key, dataKey)
+		}
+		return decode(passwordBytes, primary)
+	}
+	if passwordBytes, ok := secret.Data[dataKey+secretsv1beta1.PreviousMasterPasswordSuffix]; ok &&
+		generation == primary-1 {
+		return decode(passwordBytes, generation)
+	}
+	return "", 0, &conflictError{
+		reason: "GenerationUnavailable",
+		message: fmt.Sprintf("master password secret %s only serves its primary generation %d and the "+
+			"previous one, not generation %d", key, primary, generation),
+	}
+}
+
+// regenerateMasterPassword replaces the master password of a secret generated by the operator
+// with a new one in the given format, stored by codec, keeping the replaced one as the previous
+// generation. annotations are set on the secret along with the new master password.
+// Imported master passwords are rotated by their owners
+func regenerateMasterPassword(
+	ctx context.Context,
+	c client.Client,
+	key types.NamespacedName,
+	dataKey string,
+	codec masterPasswordCodec,
+	format secretsv1beta1.MasterPasswordFormat,
+	length int,
+	annotations map[string]string,
+) error {
+	return updateMasterPassword(ctx, c, key, annotations, func(secret *corev1.Secret) (string, error) {
+		password, err := generateMasterPassword(format, length)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate master password: %w", err)
+		}
+		value, err := codec.encode(ctx, []byte(password))
+		if err != nil {
+			return "", err
+		}
+		replaceMasterPassword(secret, dataKey, value)
+		return "Regenerated master password", nil
+	})
+}
+
+// rollbackMasterPassword restores the given generation of the master password from its history
+// secret. The restored value becomes a new primary generation, keeping the replaced one as the
+// previous generation; the primary generation itself cannot be restored. annotations are set on
+// the secret along with the restored master password
+func rollbackMasterPassword(
+	ctx context.Context,
+	c client.Client,
+	key types.NamespacedName,
+	dataKey string,
+	generation int,
+	annotations map[string]string,
+) error {
+	history := &corev1.Secret{}
+	historyKey := types.NamespacedName{Name: masterPasswordHistorySecretName(key.Name), Namespace: key.Namespace}
+	if err := c.Get(ctx, historyKey, history); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("failed to get master password history secret %s: %w", historyKey, err)
+	}
+	password, ok := history.Data[strconv.Itoa(generation)]
+	if !ok {
+		return &conflictError{
+			reason:  "GenerationUnavailable",
+			message: fmt.Sprintf("master password history %s does not hold generation %d", historyKey, generation),
+		}
+	}
+
+	return updateMasterPassword(ctx, c, key, annotations, func(secret *corev1.Secret) (string, error) {
+		if generation == secretGeneration(secret) {
+			return "", &conflictError{
+				reason:  "GenerationIsPrimary",
+				message: fmt.Sprintf("generation %d is already the primary master password", generation),
+			}
+		}
+		replaceMasterPassword(secret, dataKey, password)
+		return fmt.Sprintf("Restored master password generation %d", generation), nil
+	})
+}
+
+// updateMasterPassword applies update and annotations to a master password secret generated by
+// the operator and stores it. update returns the message logged once the secret is stored
+func updateMasterPassword(
+	ctx context.Context,
+	c client.Client,
+	key types.NamespacedName,
+	annotations map[string]string,
+	update func(secret *corev1.Secret) (string, error),
+) error {
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, key, secret); err != nil {
+		return fmt.Errorf("failed to get master password secret %s: %w", key, err)
+	}
+	if secret.Labels[managedByLabel] != managedByValue {
+		return &conflictError{
+			reason:  "ImportedMasterPassword",
+			message: fmt.Sprintf("master password secret %s was not generated by the operator", key),
+		}
+	}
+
+	message, err := update(secret)
+	if err != nil {
+		return err
+	}
+	maps.Copy(secret.Annotations, annotations)
+	if err := c.Update(ctx, secret); err != nil {
+		return fmt.Errorf("failed to update master password secret %s: %w", key, err)
+	}
+
+	logf.FromContext(ctx).Info(message, "secret", key.String(), "generation", secretGeneration(secret))
+	return nil
+}
+
+// replaceMasterPassword makes password the next primary generation of the master password in
+// secret, keeping the replaced one as the previous generation
+func replaceMasterPassword(secret *corev1.Secret, dataKey string, password []byte) {
+	if secret.Data == nil {
+		secret.Data = make(map[string][]byte)
+	}
+	if secret.Annotations == nil {
+		secret.Annotations = make(map[string]string)
+	}
+	generation := secretGeneration(secret)
+	secret.Data[dataKey+secretsv1beta1.PreviousMasterPasswordSuffix] = secret.Data[dataKey]
+	secret.Data[dataKey] = password
+	secret.Annotations[secretsv1beta1.MasterPasswordGenerationAnnotation] = strconv.Itoa(generation + 1)
+}
+
+// masterPasswordHistorySecretName returns the name of the secret keeping the history of the
+// master password held by the named secret
+func masterPasswordHistorySecretName(secretName string) string {
+	return secretName + "-history"
+}
+
+// recordMasterPasswordHistory copies the primary and previous generations of a master password
+// generated by the operator into its history secret, keyed by generation, and drops all but
+// the limit most recent generations. It returns the generations held by the history secret
+func recordMasterPasswordHistory(
+	ctx context.Context,
+	c client.Client,
+	key types.NamespacedName,
+	dataKey string,
+	limit int,
+) ([]int, error) {
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, key, secret); err != nil {
+		return nil, fmt.Errorf("failed to get master password secret %s: %w", key, err)
+	}
+	// Imported master passwords are not copied around
+	if secret.Labels[managedByLabel] != managedByValue {
+		return nil, nil
+	}
+
+	historyKey := types.NamespacedName{Name: masterPasswordHistorySecretName(key.Name), Namespace: key.Namespace}
+	history := &corev1.Secret{}
+	err := c.Get(ctx, historyKey, history)
+	if client.IgnoreNotFound(err) != nil {
+		return nil, fmt.Errorf("failed to get master password history secret %s: %w", historyKey, err)
+	}
+	create := apierrors.IsNotFound(err)
+	if create {
+		history = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      historyKey.Name,
+				Namespace: historyKey.Namespace,
+				Labels:    map[string]string{managedByLabel: managedByValue},
+			},
+			Type: corev1.SecretTypeOpaque,
+		}
+	}
+	// The generations are recorded and pruned on a copy, so a generation pruning drops right away
+	// does not count as a change
+	data := maps.Clone(history.Data)
+	if data == nil {
+		data = make(map[string][]byte)
+	}
+	primary := secretGeneration(secret)
+	if previous, ok := secret.Data[dataKey+secretsv1beta1.PreviousMasterPasswordSuffix]; ok && primary > 1 {
+		data[strconv.Itoa(primary-1)] = previous
+	}
+	data[strconv.Itoa(primary)] = secret.Data[dataKey]
+
+	var generations []int
+	for generationKey := range data {
+		generation, err := strconv.Atoi(generationKey)
+		if err != nil {
+			continue
+		}
+		generations = append(generations, generation)
+	}
+	slices.Sort(generations)
+	if len(generations) > limit {
+		for _, generation := range generations[:len(generations)-limit] {
+			delete(data, strconv.Itoa(generation))
+		}
+		generations = generations[len(generations)-limit:]
+	}
+	changed := !maps.EqualFunc(data, history.Data, bytes.Equal)
+	history.Data = data
+
+	switch {
+	case create:
+		if err := c.Create(ctx, history); err != nil {
+			return nil, fmt.Errorf("failed to create master password history secret %s: %w", historyKey, err)
+		}
+	case changed:
+		if err := c.Update(ctx, history); err != nil {
+			return nil, fmt.Errorf("failed to update master password history secret %s: %w", historyKey, err)
+		}
+	}
+	return generations, nil
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	secretsv1beta1 "github.com/oleksiyp/derived-secret-operator/api/v1beta1"
+	"github.com/oleksiyp/derived-secret-operator/internal/crypto"
+)
+
+// readShamirMasterPassword gathers the shares of a master password from their secrets and
+// combines them in memory. It returns the master password and the number of shares present;
+// missing secrets and keys are skipped, and too few or mismatched shares are a conflict
+func readShamirMasterPassword(
+	ctx context.Context,
+	c client.Reader,
+	spec *secretsv1beta1.ShamirSpec,
+) (string, int, error) {
+	var shares []string
+	for _, ref := range spec.Shares {
+		secret := &corev1.Secret{}
+		key := types.NamespacedName{Name: ref.Name, Namespace: ref.Namespace}
+		if err := c.Get(ctx, key, secret); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return "", 0, fmt.Errorf("failed to get share secret %s: %w", key, err)
+		}
+		if share := strings.TrimSpace(string(secret.Data[ref.DataKey()])); share != "" {
+			shares = append(shares, share)
+		}
+	}
+
+	if len(shares) < spec.Threshold {
+		return "", len(shares), &conflictError{
+			reason:  "InsufficientShares",
+			message: fmt.Sprintf("%d of the %d required shares are present", len(shares), spec.Threshold),
+		}
+	}
+	password, err := crypto.CombineShares(shares)
+	if err != nil {
+		return "", len(shares), &conflictError{reason: "InvalidShares", message: err.Error()}
+	}
+	return string(password), len(shares), nil
+}
+
+// referencesShare reports whether the secret holds one of the shares of the ShamirSpec
+func referencesShare(spec *secretsv1beta1.ShamirSpec, secret *corev1.Secret) bool {
+	if spec == nil {
+		return false
+	}
+	for _, ref := range spec.Shares {
+		if ref.Name == secret.Name && ref.Namespace == secret.Namespace {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	secretsv1beta1 "github.com/oleksiyp/derived-secret-operator/api/v1beta1"
+)
+
+// SourcePolicy restricts the files and environment variables of the operator pod that
+// MasterPassword sources may read, so a MasterPassword cannot read any other secret of the pod
+type SourcePolicy struct {
+	// FileDir is the directory source files must be in; empty allows no files
+	FileDir string
+	// EnvPrefix is the prefix source environment variables must have; empty allows no variables
+	EnvPrefix string
+}
+
+// readSourceMasterPassword reads an externally managed master password from its source. A
+// missing Secret, key, file or environment variable, or one the policy does not allow, is a
+// conflict until it is provided
+func readSourceMasterPassword(
+	ctx context.Context,
+	c client.Reader,
+	policy SourcePolicy,
+	source *secretsv1beta1.MasterPasswordSource,
+) (string, error) {
+	var password string
+	switch {
+	case source.SecretKeyRef != nil:
+		ref := source.SecretKeyRef
+		key := types.NamespacedName{Name: ref.Name, Namespace: ref.Namespace}
+		secret := &corev1.Secret{}
+		if err := c.Get(ctx, key, secret); err != nil {
+			if apierrors.IsNotFound(err) {
+				return "", sourceUnavailable("secret %s does not exist", key)
+			}
+			return "", fmt.Errorf("failed to get master password secret %s: %w", key, err)
+		}
+		password = string(secret.Data[ref.Key])
+	case source.File != "":
+		file, err := policy.allowedFile(source.File)
+		if err != nil {
+			return "", err
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", sourceUnavailable("failed to read file %s: %v", source.File, err)
+		}
+		// Files are often written with a trailing newline
+		password = strings.TrimRight(string(data), "\r\n")
+	case source.Env != "":
+		if policy.EnvPrefix == "" || !strings.HasPrefix(source.Env, policy.EnvPrefix) {
+			return "", sourceNotAllowed("environment variable %s does not start with the allowed prefix %q",
+				source.Env, policy.EnvPrefix)
+		}
+		password = os.Getenv(source.Env)
+	}
+
+	if password == "" {
+		return "", sourceUnavailable("the master password source is empty")
+	}
+	return password, nil
+}
+
+// allowedFile resolves the symlinks of a source file, such as those of a mounted Secret, and
+// returns the file if it is in the allowed directory
+func (p SourcePolicy) allowedFile(file string) (string, error) {
+	if p.FileDir == "" {
+		return "", sourceNotAllowed("master password files are not allowed")
+	}
+	dir, err := filepath.EvalSymlinks(p.FileDir)
+	if err != nil {
+		return "", sourceUnavailable("failed to resolve directory %s: %v", p.FileDir, err)
+	}
+	resolved, err := filepath.EvalSymlinks(file)
+	if err != nil {
+		return "", sourceUnavailable("failed to resolve file %s: %v", file, err)
+	}
+	if rel, err := filepath.Rel(dir, resolved); err != nil || rel == "." || !filepath.IsLocal(rel) {
+		return "", sourceNotAllowed("file %s is not in the allowed directory %s", file, p.FileDir)
+	}
+	return resolved, nil
+}
+
+// sourceUnavailable returns the conflict reported when a master password source cannot be read
+func sourceUnavailable(format string, args ...any) error {
+	return &conflictError{reason: "SourceUnavailable", message: fmt.Sprintf(format, args...)}
+}
+
+// sourceNotAllowed returns the conflict reported when the policy does not allow a master
+// password source
+func sourceNotAllowed(format string, args ...any) error {
+	return &conflictError{reason: "SourceNotAllowed", message: fmt.Sprintf(format, args...)}
+}
+
+// referencesSourceSecret reports whether the secret holds the master password of the source
+func referencesSourceSecret(source *secretsv1beta1.MasterPasswordSource, secret *corev1.Secret) bool {
+	if source == nil || source.SecretKeyRef == nil {
+		return false
+	}
+	return source.SecretKeyRef.Name == secret.Name && source.SecretKeyRef.Namespace == secret.Namespace
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	secretsv1beta1 "github.com/oleksiyp/derived-secret-operator/api/v1beta1"
+)
+
+// NamespaceMasterPasswordReconciler reconciles a NamespaceMasterPassword object
+type NamespaceMasterPasswordReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=secrets.oleksiyp.dev,resources=namespacemasterpasswords,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=secrets.oleksiyp.dev,resources=namespacemasterpasswords/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=secrets.oleksiyp.dev,resources=derivedsecrets,verbs=get;list;watch
+
+// Reconcile is part of the main kubernetes reconciliation loop which aims to
+// move the current state of the cluster closer to the desired state.
+func (r *NamespaceMasterPasswordReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	// Fetch the NamespaceMasterPassword instance
+	nsmp := &secretsv1beta1.NamespaceMasterPassword{}
+	err := r.Get(ctx, req.NamespacedName, nsmp)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Info("NamespaceMasterPassword resource not found. Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get NamespaceMasterPassword")
+		return ctrl.Result{}, err
+	}
+
+	// Reconcile the secret in the tenant namespace
+	secretKey := types.NamespacedName{
+		Name:      masterPasswordSecretName(nsmp.Name, nsmp.Spec.Secret),
+		Namespace: nsmp.Namespace,
+	}
+	err = ensureMasterPasswordSecret(ctx, r.Client, secretKey, nsmp.Spec.Secret, masterPasswordCodec{},
+		secretsv1beta1.MasterPasswordFormatBase62, nsmp.Spec.Length, nsmp.Spec.Annotations)
+	if err != nil {
+		log.Error(err, "Failed to reconcile secret")
+		r.setCondition(nsmp, "Ready", metav1.ConditionFalse, "SecretReconciliationFailed", err.Error())
+		if err := r.Status().Update(ctx, nsmp); err != nil {
+			log.Error(err, "Failed to update status")
+		}
+		return ctrl.Result{}, err
+	}
+
+	// Update status
+	if err := r.updateStatus(ctx, nsmp, secretKey); err != nil {
+		log.Error(err, "Failed to update status")
+		return ctrl.Result{}, err
+	}
+
+	log.Info("Successfully reconciled NamespaceMasterPassword")
+	return ctrl.Result{}, nil
+}
+
+// updateStatus updates the NamespaceMasterPassword status
+func (r *NamespaceMasterPasswordReconciler) updateStatus(
+	ctx context.Context,
+	nsmp *secretsv1beta1.NamespaceMasterPassword,
+	secretKey types.NamespacedName,
+) error {
+	// Fingerprint the master password
+	password, err := readMasterPassword(ctx, r.Client, secretKey, nsmp.Spec.Secret.DataKey(), masterPasswordCodec{})
+	if err != nil {
+		return err
+	}
+	fingerprint, err := masterPasswordFingerprint(ctx, password)
+	if err != nil {
+		return err
+	}
+
+	// Count dependent DerivedSecrets, which can only live in the same namespace
+	derivedSecrets := &secretsv1beta1.DerivedSecretList{}
+	if err := r.List(ctx, derivedSecrets, client.InNamespace(nsmp.Namespace)); err != nil {
+		return fmt.Errorf("failed to list DerivedSecrets: %w", err)
+	}
+
+	want := secretsv1beta1.MasterPasswordReference{Kind: secretsv1beta1.KindNamespaceMasterPassword, Name: nsmp.Name}
+	dependentCount := 0
+	for _, ds := range derivedSecrets.Items {
+		for _, keySpec := range ds.Spec.Keys {
+			if ds.Spec.MasterPasswordRefFor(keySpec) == want {
+				dependentCount++
+				break
+			}
+		}
+	}
+
+	nsmp.Status.SecretName = secretKey.Name
+	nsmp.Status.DependentSecrets = dependentCount
+	nsmp.Status.PasswordFingerprint = fingerprint
+
+	r.setCondition(nsmp, "Ready", metav1.ConditionTrue, "SecretReady", "Master password secret is ready")
+
+	return r.Status().Update(ctx, nsmp)
+}
+
+// setCondition sets a condition on the NamespaceMasterPassword
+func (r *NamespaceMasterPasswordReconciler) setCondition(
+	nsmp *secretsv1beta1.NamespaceMasterPassword,
+	condType string,
+	status metav1.ConditionStatus,
+	reason, message string,
+) {
+	condition := metav1.Condition{
+		Type:               condType,
+		Status:             status,
+		ObservedGeneration: nsmp.Generation,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	}
+	meta.SetStatusCondition(&nsmp.Status.Conditions, condition)
+}
+
+// findNamespaceMasterPasswordsForSecret returns an event handler that maps Secret events
+// to reconcile requests for the NamespaceMasterPasswords in the same namespace using it
+func (r *NamespaceMasterPasswordReconciler) findNamespaceMasterPasswordsForSecret() handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []ctrl.Request {
+		secret, ok := obj.(*corev1.Secret)
+		if !ok {
+			return nil
+		}
+
+		nsmpList := &secretsv1beta1.NamespaceMasterPasswordList{}
+		if err := r.List(ctx, nsmpList, client.InNamespace(secret.Namespace)); err != nil {
+			return nil
+		}
+
+		var requests []ctrl.Request
+		for _, nsmp := range nsmpList.Items {
+			if masterPasswordSecretName(nsmp.Name, nsmp.Spec.Secret) == secret.Name {
+				requests = append(requests, ctrl.Request{
+					NamespacedName: types.NamespacedName{Name: nsmp.Name, Namespace: nsmp.Namespace},
+				})
+			}
+		}
+
+		return requests
+	})
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *NamespaceMasterPasswordReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&secretsv1beta1.NamespaceMasterPassword{}).
+		Watches(&corev1.Secret{}, r.findNamespaceMasterPasswordsForSecret()).
+		Named("namespacemasterpassword").
+		Complete(r)
+}
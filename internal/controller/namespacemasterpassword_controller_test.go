@@ -0,0 +1,117 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	secretsv1beta1 "github.com/oleksiyp/derived-secret-operator/api/v1beta1"
+)
+
+var _ = Describe("NamespaceMasterPassword Controller", func() {
+	Context("When reconciling a resource", func() {
+		const resourceName = "tenant-root"
+		const derivedSecretName = "tenant-app"
+
+		ctx := context.Background()
+
+		typeNamespacedName := types.NamespacedName{
+			Name:      resourceName,
+			Namespace: "default",
+		}
+		namespacemasterpassword := &secretsv1beta1.NamespaceMasterPassword{}
+
+		BeforeEach(func() {
+			By("creating the custom resource for the Kind NamespaceMasterPassword")
+			err := k8sClient.Get(ctx, typeNamespacedName, namespacemasterpassword)
+			if err != nil && errors.IsNotFound(err) {
+				resource := &secretsv1beta1.NamespaceMasterPassword{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      resourceName,
+						Namespace: "default",
+					},
+				}
+				Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+			}
+		})
+
+		AfterEach(func() {
+			resource := &secretsv1beta1.NamespaceMasterPassword{}
+			err := k8sClient.Get(ctx, typeNamespacedName, resource)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Cleanup the specific resource instance NamespaceMasterPassword")
+			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+		})
+		It("should create the secret in its own namespace and derive from it", func() {
+			By("Reconciling the created resource")
+			controllerReconciler := &NamespaceMasterPasswordReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-mp", Namespace: "default"}, secret)).
+				To(Succeed())
+			Expect(secret.Data).To(HaveKey(masterPasswordKey))
+
+			By("Deriving a secret from the NamespaceMasterPassword")
+			ds := &secretsv1beta1.DerivedSecret{
+				ObjectMeta: metav1.ObjectMeta{Name: derivedSecretName, Namespace: "default"},
+				Spec: secretsv1beta1.DerivedSecretSpec{
+					MasterPassword:     resourceName,
+					MasterPasswordKind: secretsv1beta1.KindNamespaceMasterPassword,
+					Keys: map[string]secretsv1beta1.DerivedKeySpec{
+						"password": {Type: secretsv1beta1.SecretTypePassword},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, ds)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, ds)).To(Succeed())
+			})
+
+			dsReconciler := &DerivedSecretReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				// The operator namespace is not used for NamespaceMasterPasswords
+				OperatorNamespace: "unused",
+			}
+			dsName := types.NamespacedName{Name: derivedSecretName, Namespace: "default"}
+			_, err = dsReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: dsName})
+			Expect(err).NotTo(HaveOccurred())
+
+			derived := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, dsName, derived)).To(Succeed())
+			Expect(derived.Data["password"]).To(HaveLen(26))
+		})
+	})
+})
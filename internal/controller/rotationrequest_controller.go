@@ -0,0 +1,295 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	secretsv1beta1 "github.com/oleksiyp/derived-secret-operator/api/v1beta1"
+	"github.com/oleksiyp/derived-secret-operator/internal/kms"
+)
+
+// RotationRequestReconciler reconciles a RotationRequest object
+type RotationRequestReconciler struct {
+	client.Client
+	Scheme            *runtime.Scheme
+	OperatorNamespace string
+	// KMS encrypts the master passwords of MasterPasswords with KMS encryption; nil if none is configured
+	KMS *kms.Client
+}
+
+// +kubebuilder:rbac:groups=secrets.oleksiyp.dev,resources=rotationrequests,verbs=get;list;watch
+// +kubebuilder:rbac:groups=secrets.oleksiyp.dev,resources=rotationrequests/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=secrets.oleksiyp.dev,resources=derivedsecrets,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=secrets.oleksiyp.dev,resources=masterpasswords,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;update;patch
+
+// Reconcile is part of the main kubernetes reconciliation loop which aims to
+// move the current state of the cluster closer to the desired state.
+func (r *RotationRequestReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	rr := &secretsv1beta1.RotationRequest{}
+	if err := r.Get(ctx, req.NamespacedName, rr); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Info("RotationRequest resource not found. Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get RotationRequest")
+		return ctrl.Result{}, err
+	}
+
+	// A rotation is applied once; the request then stays as the audit record
+	if rr.Status.CompletedAt != nil {
+		return ctrl.Result{}, nil
+	}
+
+	rr.Status.RequestedBy = rr.Annotations[secretsv1beta1.RequestedByAnnotation]
+	if !rr.Approved() {
+		r.setCondition(rr, "Complete", metav1.ConditionFalse, "PendingApproval",
+			fmt.Sprintf("Waiting for approval by a user other than %s", rr.Status.RequestedBy))
+		return ctrl.Result{}, r.Status().Update(ctx, rr)
+	}
+
+	if err := r.rotate(ctx, rr); err != nil {
+		// Conflicts are not retried; changes to the target trigger a new reconcile
+		var conflict *conflictError
+		if errors.As(err, &conflict) {
+			log.Info("Refusing to rotate", "reason", conflict.reason, "message", conflict.message)
+			r.setCondition(rr, "Complete", metav1.ConditionFalse, conflict.reason, conflict.message)
+			return ctrl.Result{}, r.Status().Update(ctx, rr)
+		}
+
+		log.Error(err, "Failed to rotate")
+		r.setCondition(rr, "Complete", metav1.ConditionFalse, "RotationFailed", err.Error())
+		if err := r.Status().Update(ctx, rr); err != nil {
+			log.Error(err, "Failed to update status")
+		}
+		return ctrl.Result{}, err
+	}
+
+	now := metav1.Now()
+	rr.Status.CompletedAt = &now
+	r.setCondition(rr, "Complete", metav1.ConditionTrue, "Rotated",
+		fmt.Sprintf("Rotated %s %s", rr.Spec.Kind, rr.Spec.Name))
+	if err := r.Status().Update(ctx, rr); err != nil {
+		log.Error(err, "Failed to update status")
+		return ctrl.Result{}, err
+	}
+
+	log.Info("Rotated", "kind", rr.Spec.Kind, "name", rr.Spec.Name,
+		"requestedBy", rr.Status.RequestedBy, "approvedBy", rr.Status.ApprovedBy)
+	return ctrl.Result{}, nil
+}
+
+// rotate applies the rotation requested by rr
+func (r *RotationRequestReconciler) rotate(ctx context.Context, rr *secretsv1beta1.RotationRequest) error {
+	if rr.Spec.Kind == secretsv1beta1.RotationTargetMasterPassword {
+		return r.rotateMasterPassword(ctx, rr)
+	}
+	return r.rotateDerivedSecret(ctx, rr)
+}
+
+// rotateDerivedSecret bumps the versions of the requested keys of a DerivedSecret. The target
+// versions are recorded in the status first, so a retried rotation does not bump them twice
+func (r *RotationRequestReconciler) rotateDerivedSecret(
+	ctx context.Context,
+	rr *secretsv1beta1.RotationRequest,
+) error {
+	ds := &secretsv1beta1.DerivedSecret{}
+	if err := r.Get(ctx, types.NamespacedName{Name: rr.Spec.Name, Namespace: rr.Namespace}, ds); err != nil {
+		if apierrors.IsNotFound(err) {
+			return &conflictError{
+				reason:  "TargetNotFound",
+				message: fmt.Sprintf("DerivedSecret %s does not exist", rr.Spec.Name),
+			}
+		}
+		return fmt.Errorf("failed to get DerivedSecret %s: %w", rr.Spec.Name, err)
+	}
+	if ds.Status.StagedRotation != nil {
+		return &conflictError{
+			reason:  "RotationInProgress",
+			message: fmt.Sprintf("DerivedSecret %s has a staged rotation in progress", ds.Name),
+		}
+	}
+
+	if rr.Status.KeyVersions == nil {
+		fromVersions, err := keyVersions(ds, rr.Spec.Keys)
+		if err != nil {
+			return &conflictError{reason: "InvalidKeys", message: err.Error()}
+		}
+		rr.Status.KeyVersions = make(map[string]int, len(fromVersions))
+		for keyName, from := range fromVersions {
+			rr.Status.KeyVersions[keyName] = from + 1
+		}
+		if err := r.Status().Update(ctx, rr); err != nil {
+			return fmt.Errorf("failed to record key versions: %w", err)
+		}
+	}
+
+	for keyName, version := range rr.Status.KeyVersions {
+		if keySpec, ok := ds.Spec.Keys[keyName]; ok {
+			keySpec.Version = version
+			ds.Spec.Keys[keyName] = keySpec
+		}
+	}
+	if err := r.Update(ctx, ds); err != nil {
+		return fmt.Errorf("failed to update DerivedSecret %s: %w", ds.Name, err)
+	}
+	return nil
+}
+
+// rotateMasterPassword regenerates the master password of a MasterPassword, which rotates
+// every secret derived from it. The master password is regenerated once per request
+func (r *RotationRequestReconciler) rotateMasterPassword(
+	ctx context.Context,
+	rr *secretsv1beta1.RotationRequest,
+) error {
+	mp := &secretsv1beta1.MasterPassword{}
+	if err := r.Get(ctx, types.NamespacedName{Name: rr.Spec.Name}, mp); err != nil {
+		if apierrors.IsNotFound(err) {
+			return &conflictError{
+				reason:  "TargetNotFound",
+				message: fmt.Sprintf("MasterPassword %s does not exist", rr.Spec.Name),
+			}
+		}
+		return fmt.Errorf("failed to get MasterPassword %s: %w", rr.Spec.Name, err)
+	}
+
+	if mp.Spec.InMemory() {
+		return &conflictError{
+			reason:  "InMemoryMasterPassword",
+			message: fmt.Sprintf("MasterPassword %s is kept in memory and cannot be regenerated", mp.Name),
+		}
+	}
+	if mp.Spec.Deriver != nil {
+		return &conflictError{
+			reason:  "DeriverMasterPassword",
+			message: fmt.Sprintf("MasterPassword %s derives with a key held by its backend and is rotated there", mp.Name),
+		}
+	}
+	if mp.Spec.Source != nil {
+		return &conflictError{
+			reason:  "ExternalMasterPassword",
+			message: fmt.Sprintf("MasterPassword %s is read from an external source and is rotated by its owner", mp.Name),
+		}
+	}
+	if mp.Spec.Shamir != nil {
+		return &conflictError{
+			reason:  "ShamirMasterPassword",
+			message: fmt.Sprintf("MasterPassword %s is combined from shares and is replaced by splitting a new value", mp.Name),
+		}
+	}
+	if mp.Spec.SealedValue != "" {
+		return &conflictError{
+			reason:  "SealedMasterPassword",
+			message: fmt.Sprintf("MasterPassword %s is sealed and is replaced by sealing a new value", mp.Name),
+		}
+	}
+	key := types.NamespacedName{
+		Name:      masterPasswordSecretName(mp.Name, mp.Spec.Secret),
+		Namespace: masterPasswordSecretNamespace(mp.Spec.Secret, r.OperatorNamespace),
+	}
+	// The request is recorded on the secret along with the new master password, so a retry after
+	// a failed status update does not push the previous generation out
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, key, secret); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("failed to get master password secret %s: %w", key, err)
+	}
+	if secret.Annotations[secretsv1beta1.RotationRequestAnnotation] == string(rr.UID) {
+		return nil
+	}
+
+	// A new primary generation would drop the generation namespaces not migrated yet derive from;
+	// the completed migration updates the MasterPassword, which retries the request
+	if migration := mp.Status.Migration; migration != nil && migration.CompletedAt == nil {
+		return &conflictError{
+			reason: "MigrationInProgress",
+			message: fmt.Sprintf("MasterPassword %s is migrating from generation %d to %d",
+				mp.Name, migration.FromGeneration, migration.ToGeneration),
+		}
+	}
+
+	return regenerateMasterPassword(ctx, r.Client, key, mp.Spec.Secret.DataKey(), newMasterPasswordCodec(r.KMS, mp),
+		mp.Spec.Format, mp.Spec.Length, map[string]string{secretsv1beta1.RotationRequestAnnotation: string(rr.UID)})
+}
+
+// setCondition sets a condition on the RotationRequest
+func (r *RotationRequestReconciler) setCondition(
+	rr *secretsv1beta1.RotationRequest,
+	condType string,
+	status metav1.ConditionStatus,
+	reason, message string,
+) {
+	condition := metav1.Condition{
+		Type:               condType,
+		Status:             status,
+		ObservedGeneration: rr.Generation,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	}
+	meta.SetStatusCondition(&rr.Status.Conditions, condition)
+}
+
+// findRotationRequestsForTarget returns an event handler that maps DerivedSecret and
+// MasterPassword events to the pending RotationRequests targeting them
+func (r *RotationRequestReconciler) findRotationRequestsForTarget(
+	kind secretsv1beta1.RotationTargetKind,
+) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []ctrl.Request {
+		rrList := &secretsv1beta1.RotationRequestList{}
+		if err := r.List(ctx, rrList, client.InNamespace(obj.GetNamespace())); err != nil {
+			return nil
+		}
+
+		var requests []ctrl.Request
+		for _, rr := range rrList.Items {
+			if rr.Spec.Kind == kind && rr.Spec.Name == obj.GetName() && rr.Status.CompletedAt == nil {
+				requests = append(requests, ctrl.Request{
+					NamespacedName: types.NamespacedName{Name: rr.Name, Namespace: rr.Namespace},
+				})
+			}
+		}
+		return requests
+	})
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *RotationRequestReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&secretsv1beta1.RotationRequest{}).
+		Watches(&secretsv1beta1.DerivedSecret{},
+			r.findRotationRequestsForTarget(secretsv1beta1.RotationTargetDerivedSecret)).
+		Watches(&secretsv1beta1.MasterPassword{},
+			r.findRotationRequestsForTarget(secretsv1beta1.RotationTargetMasterPassword)).
+		Named("rotationrequest").
+		Complete(r)
+}
@@ -0,0 +1,231 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	secretsv1beta1 "github.com/oleksiyp/derived-secret-operator/api/v1beta1"
+)
+
+var _ = Describe("RotationRequest Controller", func() {
+	Context("When reconciling a resource", func() {
+		const resourceName = "rotate"
+		const masterPasswordName = "rotated-root"
+		const derivedSecretName = "rotated-app"
+
+		ctx := context.Background()
+
+		typeNamespacedName := types.NamespacedName{Name: resourceName, Namespace: "default"}
+		masterPasswordSecret := &corev1.Secret{}
+
+		BeforeEach(func() {
+			By("creating the MasterPassword and its generated secret")
+			masterPasswordSecret = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      masterPasswordName + "-mp",
+					Namespace: "default",
+					Labels:    map[string]string{managedByLabel: managedByValue},
+				},
+				Data: map[string][]byte{
+					masterPasswordKey: []byte("rotated-master-password-for-testing-only"),
+				},
+			}
+			Expect(k8sClient.Create(ctx, masterPasswordSecret)).To(Succeed())
+			masterPassword := &secretsv1beta1.MasterPassword{
+				ObjectMeta: metav1.ObjectMeta{Name: masterPasswordName},
+			}
+			Expect(k8sClient.Create(ctx, masterPassword)).To(Succeed())
+
+			By("creating the DerivedSecret")
+			ds := &secretsv1beta1.DerivedSecret{
+				ObjectMeta: metav1.ObjectMeta{Name: derivedSecretName, Namespace: "default"},
+				Spec: secretsv1beta1.DerivedSecretSpec{
+					MasterPassword: masterPasswordName,
+					Keys: map[string]secretsv1beta1.DerivedKeySpec{
+						"password": {Type: secretsv1beta1.SecretTypePassword},
+						"token":    {Type: secretsv1beta1.SecretTypeEncryptionKey},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, ds)).To(Succeed())
+
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, ds)).To(Succeed())
+				Expect(k8sClient.Delete(ctx, masterPassword)).To(Succeed())
+				Expect(k8sClient.Delete(ctx, masterPasswordSecret)).To(Succeed())
+			})
+		})
+
+		createRequest := func(spec secretsv1beta1.RotationRequestSpec) *secretsv1beta1.RotationRequest {
+			rr := &secretsv1beta1.RotationRequest{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        resourceName,
+					Namespace:   "default",
+					Annotations: map[string]string{secretsv1beta1.RequestedByAnnotation: "alice"},
+				},
+				Spec: spec,
+			}
+			Expect(k8sClient.Create(ctx, rr)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, rr)).To(Succeed())
+			})
+			return rr
+		}
+
+		reconcileRequest := func() *secretsv1beta1.RotationRequest {
+			controllerReconciler := &RotationRequestReconciler{
+				Client:            k8sClient,
+				Scheme:            k8sClient.Scheme(),
+				OperatorNamespace: "default",
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			rr := &secretsv1beta1.RotationRequest{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, rr)).To(Succeed())
+			return rr
+		}
+
+		approve := func(rr *secretsv1beta1.RotationRequest) {
+			Expect(k8sClient.Get(ctx, typeNamespacedName, rr)).To(Succeed())
+			meta.SetStatusCondition(&rr.Status.Conditions, metav1.Condition{
+				Type:   "Approved",
+				Status: metav1.ConditionTrue,
+				Reason: "Approved",
+			})
+			rr.Status.ApprovedBy = "bob"
+			Expect(k8sClient.Status().Update(ctx, rr)).To(Succeed())
+		}
+
+		completeReason := func(rr *secretsv1beta1.RotationRequest) string {
+			condition := meta.FindStatusCondition(rr.Status.Conditions, "Complete")
+			Expect(condition).NotTo(BeNil())
+			return condition.Reason
+		}
+
+		It("should bump the requested DerivedSecret keys once approved", func() {
+			rr := createRequest(secretsv1beta1.RotationRequestSpec{
+				Kind: secretsv1beta1.RotationTargetDerivedSecret,
+				Name: derivedSecretName,
+				Keys: []string{"password"},
+			})
+
+			By("Waiting for approval")
+			rr = reconcileRequest()
+			Expect(completeReason(rr)).To(Equal("PendingApproval"))
+			Expect(rr.Status.RequestedBy).To(Equal("alice"))
+
+			By("Rotating after approval")
+			approve(rr)
+			rr = reconcileRequest()
+			Expect(completeReason(rr)).To(Equal("Rotated"))
+			Expect(rr.Status.CompletedAt).NotTo(BeNil())
+			Expect(rr.Status.KeyVersions).To(Equal(map[string]int{"password": 1}))
+
+			ds := &secretsv1beta1.DerivedSecret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: derivedSecretName, Namespace: "default"}, ds)).
+				To(Succeed())
+			Expect(ds.Spec.Keys["password"].Version).To(Equal(1))
+			Expect(ds.Spec.Keys["token"].Version).To(Equal(0))
+
+			By("Applying the rotation only once")
+			reconcileRequest()
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: derivedSecretName, Namespace: "default"}, ds)).
+				To(Succeed())
+			Expect(ds.Spec.Keys["password"].Version).To(Equal(1))
+		})
+
+		It("should regenerate a generated master password once approved", func() {
+			rr := createRequest(secretsv1beta1.RotationRequestSpec{
+				Kind: secretsv1beta1.RotationTargetMasterPassword,
+				Name: masterPasswordName,
+			})
+			approve(rr)
+			rr = reconcileRequest()
+			Expect(completeReason(rr)).To(Equal("Rotated"))
+
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(masterPasswordSecret), secret)).To(Succeed())
+			Expect(secret.Data[masterPasswordKey]).NotTo(Equal([]byte("rotated-master-password-for-testing-only")))
+			Expect(secret.Data[masterPasswordKey+secretsv1beta1.PreviousMasterPasswordSuffix]).
+				To(Equal([]byte("rotated-master-password-for-testing-only")))
+			Expect(secret.Annotations).To(HaveKeyWithValue(secretsv1beta1.MasterPasswordGenerationAnnotation, "2"))
+
+			By("Not regenerating again when the status update is retried")
+			rr.Status.CompletedAt = nil
+			Expect(k8sClient.Status().Update(ctx, rr)).To(Succeed())
+			rr = reconcileRequest()
+			Expect(completeReason(rr)).To(Equal("Rotated"))
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(masterPasswordSecret), secret)).To(Succeed())
+			Expect(secret.Annotations).To(HaveKeyWithValue(secretsv1beta1.MasterPasswordGenerationAnnotation, "2"))
+			Expect(secret.Data[masterPasswordKey+secretsv1beta1.PreviousMasterPasswordSuffix]).
+				To(Equal([]byte("rotated-master-password-for-testing-only")))
+		})
+
+		It("should wait for a running migration before regenerating the master password", func() {
+			masterPassword := &secretsv1beta1.MasterPassword{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: masterPasswordName}, masterPassword)).To(Succeed())
+			masterPassword.Status.Migration = &secretsv1beta1.MigrationStatus{FromGeneration: 1, ToGeneration: 2}
+			Expect(k8sClient.Status().Update(ctx, masterPassword)).To(Succeed())
+
+			rr := createRequest(secretsv1beta1.RotationRequestSpec{
+				Kind: secretsv1beta1.RotationTargetMasterPassword,
+				Name: masterPasswordName,
+			})
+			approve(rr)
+			rr = reconcileRequest()
+			Expect(completeReason(rr)).To(Equal("MigrationInProgress"))
+			Expect(rr.Status.CompletedAt).To(BeNil())
+
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(masterPasswordSecret), secret)).To(Succeed())
+			Expect(secret.Data[masterPasswordKey]).To(Equal([]byte("rotated-master-password-for-testing-only")))
+
+			By("Regenerating once the migration completed")
+			now := metav1.Now()
+			masterPassword.Status.Migration.CompletedAt = &now
+			Expect(k8sClient.Status().Update(ctx, masterPassword)).To(Succeed())
+			rr = reconcileRequest()
+			Expect(completeReason(rr)).To(Equal("Rotated"))
+		})
+
+		It("should refuse to regenerate an imported master password", func() {
+			delete(masterPasswordSecret.Labels, managedByLabel)
+			Expect(k8sClient.Update(ctx, masterPasswordSecret)).To(Succeed())
+
+			rr := createRequest(secretsv1beta1.RotationRequestSpec{
+				Kind: secretsv1beta1.RotationTargetMasterPassword,
+				Name: masterPasswordName,
+			})
+			approve(rr)
+			rr = reconcileRequest()
+			Expect(completeReason(rr)).To(Equal("ImportedMasterPassword"))
+			Expect(rr.Status.CompletedAt).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,141 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/oleksiyp/derived-secret-operator/internal/crypto"
+)
+
+const (
+	// sealingKeyName is the name of the Secret holding the keypair master passwords are sealed
+	// to, and of the ConfigMap publishing its public key
+	sealingKeyName = "derived-secret-operator-sealing-key"
+
+	sealingPrivateKeyKey = "privateKey"
+	sealingPublicKeyKey  = "publicKey"
+
+	// sealingKeyRetryInterval is how often publishing the sealing key is retried
+	sealingKeyRetryInterval = 10 * time.Second
+)
+
+// publishSealingKey generates the sealing key once the operator leads, retrying until it
+// succeeds, so the public key is published before any master password is sealed to it
+func (r *MasterPasswordReconciler) publishSealingKey(ctx context.Context) error {
+	log := logf.FromContext(ctx)
+	err := wait.PollUntilContextCancel(ctx, sealingKeyRetryInterval, true, func(ctx context.Context) (bool, error) {
+		if _, err := ensureSealingKey(ctx, r.Client, r.OperatorNamespace); err != nil {
+			log.Error(err, "Failed to publish the sealing key")
+			return false, nil
+		}
+		return true, nil
+	})
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// ensureSealingKey returns the private key master passwords are sealed to, generating the
+// keypair in the given namespace on first use. The public key is published in a ConfigMap,
+// so sealing a master password does not need access to the Secret
+func ensureSealingKey(ctx context.Context, c client.Client, namespace string) (string, error) {
+	key := types.NamespacedName{Name: sealingKeyName, Namespace: namespace}
+
+	secret := &corev1.Secret{}
+	err := c.Get(ctx, key, secret)
+	if client.IgnoreNotFound(err) != nil {
+		return "", fmt.Errorf("failed to get sealing key secret %s: %w", key, err)
+	}
+	if apierrors.IsNotFound(err) {
+		privateKey, publicKey, err := crypto.GenerateSealingKey()
+		if err != nil {
+			return "", err
+		}
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      key.Name,
+				Namespace: key.Namespace,
+				Labels:    map[string]string{managedByLabel: managedByValue},
+			},
+			Type: corev1.SecretTypeOpaque,
+			Data: map[string][]byte{
+				sealingPrivateKeyKey: []byte(privateKey),
+				sealingPublicKeyKey:  []byte(publicKey),
+			},
+		}
+		if err := c.Create(ctx, secret); err != nil {
+			return "", fmt.Errorf("failed to create sealing key secret %s: %w", key, err)
+		}
+		logf.FromContext(ctx).Info("Generated sealing key", "secret", key.String())
+	}
+
+	privateKey, ok := secret.Data[sealingPrivateKeyKey]
+	if !ok {
+		return "", fmt.Errorf("sealing key secret %s missing key %s", key, sealingPrivateKeyKey)
+	}
+	if err := publishSealingPublicKey(ctx, c, key, string(secret.Data[sealingPublicKeyKey])); err != nil {
+		return "", err
+	}
+	return string(privateKey), nil
+}
+
+// publishSealingPublicKey ensures the sealing key ConfigMap holds the given public key
+func publishSealingPublicKey(ctx context.Context, c client.Client, key types.NamespacedName, publicKey string) error {
+	configMap := &corev1.ConfigMap{}
+	err := c.Get(ctx, key, configMap)
+	if client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("failed to get sealing key configmap %s: %w", key, err)
+	}
+	if apierrors.IsNotFound(err) {
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      key.Name,
+				Namespace: key.Namespace,
+				Labels:    map[string]string{managedByLabel: managedByValue},
+			},
+			Data: map[string]string{sealingPublicKeyKey: publicKey},
+		}
+		if err := c.Create(ctx, configMap); err != nil {
+			return fmt.Errorf("failed to create sealing key configmap %s: %w", key, err)
+		}
+		return nil
+	}
+
+	if configMap.Data[sealingPublicKeyKey] == publicKey {
+		return nil
+	}
+	if configMap.Data == nil {
+		configMap.Data = make(map[string]string)
+	}
+	configMap.Data[sealingPublicKeyKey] = publicKey
+	if err := c.Update(ctx, configMap); err != nil {
+		return fmt.Errorf("failed to update sealing key configmap %s: %w", key, err)
+	}
+	return nil
+}
@@ -17,12 +17,12 @@ limitations under the License.
 package crypto
 
 import (
+	"context"
 	"crypto/rand"
-	"crypto/sha256"
 	"encoding/base64"
-	"encoding/binary"
 	"fmt"
 	"math/big"
+	"time"
 
 	"golang.org/x/crypto/argon2"
 )
@@ -38,26 +38,56 @@ const (
 	base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
 )
 
+// Deriver computes the keyed pseudorandom function secrets are derived with. Derive returns
+// argon2KeyLen bytes for input, which is the derivation context or the context followed by the
+// previous output. Implementations must return the same bytes for the same input every time.
+type Deriver interface {
+	Derive(ctx context.Context, input []byte) ([]byte, error)
+}
+
+// Argon2idDeriver derives secrets from a master password with Argon2id, using the input as salt.
+type Argon2idDeriver struct {
+	masterPassword []byte
+}
+
+// NewArgon2idDeriver returns a Deriver keyed by the master password.
+func NewArgon2idDeriver(masterPassword string) *Argon2idDeriver {
+	return &Argon2idDeriver{masterPassword: []byte(masterPassword)}
+}
+
+// Derive derives argon2KeyLen bytes from the master password with input as salt.
+func (d *Argon2idDeriver) Derive(_ context.Context, input []byte) ([]byte, error) {
+	return argon2.IDKey(
+		d.masterPassword,
+		input,
+		argon2Time,
+		argon2Memory,
+		argon2Threads,
+		argon2KeyLen,
+	), nil
+}
+
 // DeriveSecret derives a secret using Argon2id with the given master password and context.
 // The context is used as the salt for the KDF.
 // The derived secret is encoded as Base62 (A-Za-z0-9) and truncated/padded to the specified length.
-func DeriveSecret(masterPassword, context string, length int) (string, error) {
+func DeriveSecret(masterPassword, derivationContext string, length int) (string, error) {
+	return DeriveSecretWith(context.Background(), NewArgon2idDeriver(masterPassword), derivationContext, length)
+}
+
+// DeriveSecretWith derives a secret for the derivation context with the given Deriver.
+// The derived secret is encoded as Base62 (A-Za-z0-9) and truncated/padded to the specified length.
+func DeriveSecretWith(ctx context.Context, deriver Deriver, derivationContext string, length int) (string, error) {
 	if length < 22 || length > 256 {
 		return "", fmt.Errorf("length must be between 22 and 256, got %d", length)
 	}
 
 	// Use context as salt
-	salt := []byte(context)
+	salt := []byte(derivationContext)
 
-	// Derive key using Argon2id
-	derivedKey := argon2.IDKey(
-		[]byte(masterPassword),
-		salt,
-		argon2Time,
-		argon2Memory,
-		argon2Threads,
-		argon2KeyLen,
-	)
+	derivedKey, err := derive(ctx, deriver, salt)
+	if err != nil {
+		return "", err
+	}
 
 	// Convert to base62
 	// Use the derived key as a seed to generate base62 characters deterministically
@@ -77,20 +107,27 @@ func DeriveSecret(masterPassword, context string, length int) (string, error) {
 		if i > 0 && i%argon2KeyLen == 0 {
 			// Use previous result as additional context for more bytes
 			newSalt := append(salt, derivedKey...)
-			derivedKey = argon2.IDKey(
-				[]byte(masterPassword),
-				newSalt,
-				argon2Time,
-				argon2Memory,
-				argon2Threads,
-				argon2KeyLen,
-			)
+			if derivedKey, err = derive(ctx, deriver, newSalt); err != nil {
+				return "", err
+			}
 		}
 	}
 
 	return string(result), nil
 }
 
+// derive calls the Deriver and checks it returned enough bytes.
+func derive(ctx context.Context, deriver Deriver, input []byte) ([]byte, error) {
+	output, err := deriver.Derive(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive secret: %w", err)
+	}
+	if len(output) < argon2KeyLen {
+		return nil, fmt.Errorf("deriver returned %d bytes, need %d", len(output), argon2KeyLen)
+	}
+	return output[:argon2KeyLen], nil
+}
+
 // GenerateRandomPassword generates a cryptographically secure random password
 // of the specified length using Base62 alphabet.
 func GenerateRandomPassword(length int) (string, error) {
@@ -149,12 +186,27 @@ func BuildContext(namespace, name, key string) string {
 	return fmt.Sprintf("%s/%s/%s", namespace, name, key)
 }
 
-// CalculatePasswordHash calculates a hash (0-999) from a password for tracking updates without revealing the password.
-// This is used to detect password changes without exposing sensitive data in the status field.
-func CalculatePasswordHash(password string) int32 {
-	hash := sha256.Sum256([]byte(password))
-	// Use first 4 bytes to get a uint32, then mod 1000 to get 0-999
-	value := binary.BigEndian.Uint32(hash[:4])
-	// #nosec G115 -- value % 1000 is always in range [0, 999], safe to convert to int32
-	return int32(value % 1000)
+// VersionContext mixes a key version into a derivation context. Version 0 leaves the
+// context unchanged, so keys derived before versioning keep their values.
+func VersionContext(context string, version int) string {
+	if version == 0 {
+		return context
+	}
+	return fmt.Sprintf("%s@v%d", context, version)
+}
+
+// RotationEpoch returns the epoch bucket the given time falls into for a rotation interval.
+// Buckets are counted from the Unix epoch, so every replica agrees on the current one.
+func RotationEpoch(now time.Time, interval time.Duration) int64 {
+	return now.Unix() / int64(interval/time.Second)
+}
+
+// EpochStart returns the time an epoch bucket starts.
+func EpochStart(epoch int64, interval time.Duration) time.Time {
+	return time.Unix(epoch*int64(interval/time.Second), 0)
+}
+
+// EpochContext mixes a rotation epoch into a derivation context.
+func EpochContext(context string, epoch int64) string {
+	return fmt.Sprintf("%s#%d", context, epoch)
 }
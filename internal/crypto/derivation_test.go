@@ -17,8 +17,12 @@ limitations under the License.
 package crypto
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestDeriveSecret(t *testing.T) {
@@ -107,6 +111,51 @@ func TestDeriveSecret(t *testing.T) {
 	}
 }
 
+// hmacDeriver derives with HMAC-SHA256, as the external derivation backends do
+type hmacDeriver struct {
+	key  []byte
+	size int
+}
+
+func (d hmacDeriver) Derive(_ context.Context, input []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, d.key)
+	mac.Write(input)
+	return mac.Sum(nil)[:d.size], nil
+}
+
+func TestDeriveSecretWith(t *testing.T) {
+	ctx := context.Background()
+
+	// Argon2id secrets derived before derivers were pluggable keep their values
+	got, err := DeriveSecretWith(ctx, NewArgon2idDeriver("test-master-password"), "namespace/name/key1", 48)
+	if err != nil {
+		t.Fatalf("DeriveSecretWith() error = %v", err)
+	}
+	if want := "qkkXx60ak4XxBK6xgcbjP4Kf5Q7jt04bBNJ1vgNK3d7j8zIO"; got != want {
+		t.Errorf("DeriveSecretWith() = %q, want %q", got, want)
+	}
+
+	deriver := hmacDeriver{key: []byte("backend-key"), size: sha256.Size}
+	first, err := DeriveSecretWith(ctx, deriver, "namespace/name/key1", 100)
+	if err != nil {
+		t.Fatalf("DeriveSecretWith() error = %v", err)
+	}
+	second, err := DeriveSecretWith(ctx, deriver, "namespace/name/key1", 100)
+	if err != nil || second != first || len(first) != 100 {
+		t.Errorf("DeriveSecretWith() = %q, %v, want the same 100 characters %q", second, err, first)
+	}
+	other, err := DeriveSecretWith(ctx, hmacDeriver{key: []byte("another-key"), size: sha256.Size},
+		"namespace/name/key1", 100)
+	if err != nil || other == first {
+		t.Errorf("DeriveSecretWith() with another key = %q, %v, want another secret", other, err)
+	}
+
+	if _, err := DeriveSecretWith(ctx, hmacDeriver{key: []byte("backend-key"), size: 16},
+		"namespace/name/key1", 26); err == nil {
+		t.Errorf("DeriveSecretWith() accepted a deriver returning too few bytes")
+	}
+}
+
 func TestGenerateRandomPassword(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -238,3 +287,37 @@ func TestBuildContext(t *testing.T) {
 		})
 	}
 }
+
+func TestVersionContext(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+		want    string
+	}{
+		{name: "unversioned", version: 0, want: "ns/app/key"},
+		{name: "versioned", version: 3, want: "ns/app/key@v3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VersionContext("ns/app/key", tt.version); got != tt.want {
+				t.Errorf("VersionContext() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRotationEpoch(t *testing.T) {
+	interval := 90 * 24 * time.Hour
+	start := EpochStart(RotationEpoch(time.Unix(1700000000, 0), interval), interval)
+
+	if got := RotationEpoch(start, interval); got != RotationEpoch(start.Add(interval-time.Second), interval) {
+		t.Errorf("RotationEpoch() changed within a bucket")
+	}
+	if got, want := RotationEpoch(start.Add(interval), interval), RotationEpoch(start, interval)+1; got != want {
+		t.Errorf("RotationEpoch() at the next boundary = %v, want %v", got, want)
+	}
+	if got := EpochContext("ns/app/key", 42); got != "ns/app/key#42" {
+		t.Errorf("EpochContext() = %v, want ns/app/key#42", got)
+	}
+}
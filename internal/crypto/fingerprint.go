@@ -0,0 +1,80 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+)
+
+const (
+	// ReservedContextPrefix starts the derivation contexts the operator derives for itself.
+	// No DerivedSecret may derive them, or it would publish a value derived for the operator.
+	ReservedContextPrefix = "derived-secret-operator:"
+
+	// fingerprintKeyContext is the derivation context of the key fingerprints are computed with.
+	fingerprintKeyContext = ReservedContextPrefix + "fingerprint"
+
+	// masterFingerprintContext is the context the fingerprint of a master itself is computed for.
+	masterFingerprintContext = ReservedContextPrefix + "master"
+
+	// FingerprintPrefix identifies the algorithm of a fingerprint.
+	FingerprintPrefix = "hmac-sha256:"
+
+	// fingerprintSize is the number of HMAC bytes kept in a fingerprint. 128 bits make a
+	// collision between two values of a key practically impossible.
+	fingerprintSize = 16
+)
+
+// FingerprintKey derives the key the fingerprints of values derived by the Deriver are computed
+// with. Without the master the fingerprints can neither be computed nor checked, so they reveal
+// nothing about short values.
+func FingerprintKey(ctx context.Context, deriver Deriver) ([]byte, error) {
+	return derive(ctx, deriver, []byte(fingerprintKeyContext))
+}
+
+// Fingerprint returns the fingerprint of a value recorded for the given context, such as the
+// namespace, name and key of a DerivedSecret key. Binding the context keeps equal values of
+// different keys from having equal fingerprints.
+func Fingerprint(key []byte, context, value string) string {
+	mac := hmac.New(sha256.New, key)
+	// The context is length prefixed, so no context and value pair runs into another
+	var length [8]byte
+	binary.BigEndian.PutUint64(length[:], uint64(len(context)))
+	mac.Write(length[:])
+	mac.Write([]byte(context))
+	mac.Write([]byte(value))
+	return FingerprintPrefix + hex.EncodeToString(mac.Sum(nil)[:fingerprintSize])
+}
+
+// MasterFingerprint returns the fingerprint of the master the key was derived from. It changes
+// whenever the master does.
+func MasterFingerprint(key []byte) string {
+	return Fingerprint(key, masterFingerprintContext, "")
+}
+
+// VerifyFingerprint reports whether value matches the fingerprint recorded for the context.
+func VerifyFingerprint(key []byte, context, value, fingerprint string) bool {
+	if !strings.HasPrefix(fingerprint, FingerprintPrefix) {
+		return false
+	}
+	return hmac.Equal([]byte(Fingerprint(key, context, value)), []byte(fingerprint))
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	ctx := context.Background()
+	key, err := FingerprintKey(ctx, NewArgon2idDeriver("test-master-password"))
+	if err != nil {
+		t.Fatalf("FingerprintKey() error = %v", err)
+	}
+	other, err := FingerprintKey(ctx, NewArgon2idDeriver("other-master-password"))
+	if err != nil {
+		t.Fatalf("FingerprintKey() error = %v", err)
+	}
+
+	fingerprint := Fingerprint(key, "namespace/name/key1", "value")
+	if !strings.HasPrefix(fingerprint, FingerprintPrefix) || len(fingerprint) != len(FingerprintPrefix)+32 {
+		t.Errorf("Fingerprint() = %q, want %s followed by 128 bits in hex", fingerprint, FingerprintPrefix)
+	}
+	if strings.Contains(fingerprint, "value") {
+		t.Errorf("Fingerprint() = %q reveals the value", fingerprint)
+	}
+	if !VerifyFingerprint(key, "namespace/name/key1", "value", fingerprint) {
+		t.Errorf("VerifyFingerprint() = false for the fingerprinted value")
+	}
+
+	tests := []struct {
+		name    string
+		key     []byte
+		context string
+		value   string
+	}{
+		{name: "another value", key: key, context: "namespace/name/key1", value: "value2"},
+		{name: "another context", key: key, context: "namespace/name/key2", value: "value"},
+		{name: "context and value shifted", key: key, context: "namespace/name/key1v", value: "alue"},
+		{name: "another master", key: other, context: "namespace/name/key1", value: "value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if VerifyFingerprint(tt.key, tt.context, tt.value, fingerprint) {
+				t.Errorf("VerifyFingerprint() = true, want false")
+			}
+		})
+	}
+
+	if VerifyFingerprint(key, "namespace/name/key1", "value", strings.TrimPrefix(fingerprint, FingerprintPrefix)) {
+		t.Errorf("VerifyFingerprint() accepted a fingerprint without the algorithm prefix")
+	}
+	if MasterFingerprint(key) == MasterFingerprint(other) {
+		t.Errorf("MasterFingerprint() is equal for different masters")
+	}
+}
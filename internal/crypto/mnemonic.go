@@ -0,0 +1,112 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"fmt"
+	"strings"
+)
+
+const (
+	// MnemonicWords is the number of words of a mnemonic master password.
+	MnemonicWords = 24
+
+	// mnemonicEntropySize is the entropy of a mnemonic in bytes. With one checksum byte it
+	// fills 24 words of 11 bits.
+	mnemonicEntropySize = 32
+
+	// mnemonicWordBits is the number of bits a word encodes.
+	mnemonicWordBits = 11
+)
+
+// bip39EnglishList is the English word list of BIP39
+//
+//go:embed bip39_english.txt
+var bip39EnglishList string
+
+var (
+	bip39English = strings.Fields(bip39EnglishList)
+	bip39Index   = wordIndex(bip39English)
+)
+
+// wordIndex maps each word of a word list to its position.
+func wordIndex(words []string) map[string]int {
+	index := make(map[string]int, len(words))
+	for i, word := range words {
+		index[word] = i
+	}
+	return index
+}
+
+// GenerateMnemonic generates a master password of 256 random bits as a 24 word BIP39 English
+// mnemonic, which can be written down and typed back.
+func GenerateMnemonic() (string, error) {
+	entropy, err := GenerateRandomBytes(mnemonicEntropySize)
+	if err != nil {
+		return "", err
+	}
+	return encodeMnemonic(entropy), nil
+}
+
+// encodeMnemonic encodes 32 bytes of entropy followed by the first byte of their SHA-256
+// as 24 words.
+func encodeMnemonic(entropy []byte) string {
+	checksum := sha256.Sum256(entropy)
+	bits := append(append([]byte{}, entropy...), checksum[0])
+
+	words := make([]string, MnemonicWords)
+	for i := range words {
+		index := 0
+		for b := i * mnemonicWordBits; b < (i+1)*mnemonicWordBits; b++ {
+			index = index<<1 | int(bits[b/8]>>(7-b%8)&1)
+		}
+		words[i] = bip39English[index]
+	}
+	return strings.Join(words, " ")
+}
+
+// NormalizeMnemonic validates a typed 24 word BIP39 English mnemonic and returns it in canonical
+// form: lower case words separated by single spaces. Unknown words and words that do not match
+// the checksum, such as typos or swapped words, are rejected.
+func NormalizeMnemonic(mnemonic string) (string, error) {
+	words := strings.Fields(strings.ToLower(mnemonic))
+	if len(words) != MnemonicWords {
+		return "", fmt.Errorf("a mnemonic has %d words, got %d", MnemonicWords, len(words))
+	}
+
+	bits := make([]byte, mnemonicEntropySize+1)
+	for i, word := range words {
+		index, ok := bip39Index[word]
+		if !ok {
+			return "", fmt.Errorf("word %d %q is not in the BIP39 English word list", i+1, word)
+		}
+		for j := range mnemonicWordBits {
+			if index>>(mnemonicWordBits-1-j)&1 == 1 {
+				b := i*mnemonicWordBits + j
+				bits[b/8] |= 1 << (7 - b%8)
+			}
+		}
+	}
+
+	checksum := sha256.Sum256(bits[:mnemonicEntropySize])
+	if checksum[0] != bits[mnemonicEntropySize] {
+		return "", fmt.Errorf("the mnemonic checksum does not match; a word is mistyped or out of order")
+	}
+	return strings.Join(words, " "), nil
+}
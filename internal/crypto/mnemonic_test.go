@@ -0,0 +1,104 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestBIP39EnglishWordList(t *testing.T) {
+	// SHA-256 of english.txt in the BIP39 repository
+	const want = "2f5eed53a4727b4bf8880d8f3f199efc90e58503646d9ff8eff3a2ed3b24dbda"
+	sum := sha256.Sum256([]byte(bip39EnglishList))
+	if got := hex.EncodeToString(sum[:]); got != want {
+		t.Errorf("word list SHA-256 = %s, want %s", got, want)
+	}
+	if len(bip39English) != 2048 {
+		t.Errorf("word list has %d words, want 2048", len(bip39English))
+	}
+}
+
+func TestEncodeMnemonic(t *testing.T) {
+	repeat := func(words string, n int, last string) string {
+		return strings.TrimSpace(strings.Repeat(words+" ", n)) + " " + last
+	}
+	// Test vectors of the BIP39 reference implementation
+	tests := []struct {
+		entropy byte
+		want    string
+	}{
+		{0x00, repeat("abandon", 23, "art")},
+		{0x7f, repeat("legal winner thank year wave sausage worth useful", 2, "legal winner thank year wave "+
+			"sausage worth title")},
+		{0x80, repeat("letter advice cage absurd amount doctor acoustic avoid", 2, "letter advice cage absurd "+
+			"amount doctor acoustic bless")},
+		{0xff, repeat("zoo", 23, "vote")},
+	}
+	for _, tt := range tests {
+		got := encodeMnemonic(bytes.Repeat([]byte{tt.entropy}, mnemonicEntropySize))
+		if got != tt.want {
+			t.Errorf("encodeMnemonic(%#x...) = %q, want %q", tt.entropy, got, tt.want)
+		}
+		if normalized, err := NormalizeMnemonic(got); err != nil || normalized != got {
+			t.Errorf("NormalizeMnemonic(%q) = %q, %v", got, normalized, err)
+		}
+	}
+}
+
+func TestGenerateMnemonic(t *testing.T) {
+	mnemonic, err := GenerateMnemonic()
+	if err != nil {
+		t.Fatalf("GenerateMnemonic() error = %v", err)
+	}
+	if words := strings.Fields(mnemonic); len(words) != MnemonicWords {
+		t.Errorf("GenerateMnemonic() returned %d words, want %d", len(words), MnemonicWords)
+	}
+	if _, err := NormalizeMnemonic(mnemonic); err != nil {
+		t.Errorf("NormalizeMnemonic(GenerateMnemonic()) error = %v", err)
+	}
+
+	// Typed mnemonics may differ in case and whitespace
+	typed := "  " + strings.ToUpper(strings.ReplaceAll(mnemonic, " ", "\n\t ")) + "\n"
+	if normalized, err := NormalizeMnemonic(typed); err != nil || normalized != mnemonic {
+		t.Errorf("NormalizeMnemonic(typed) = %q, %v, want %q", normalized, err, mnemonic)
+	}
+}
+
+func TestNormalizeMnemonicRejectsTypos(t *testing.T) {
+	valid := strings.Repeat("abandon ", 23) + "art"
+	tests := map[string]string{
+		"too short":     strings.Repeat("abandon ", 11) + "about",
+		"unknown word":  strings.Repeat("abandon ", 23) + "artt",
+		"wrong word":    strings.Repeat("abandon ", 23) + "zoo",
+		"swapped words": "art " + strings.Repeat("abandon ", 23),
+		"empty":         "",
+	}
+	for name, mnemonic := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NormalizeMnemonic(mnemonic); err == nil {
+				t.Errorf("NormalizeMnemonic(%q) succeeded", mnemonic)
+			}
+		})
+	}
+	if _, err := NormalizeMnemonic(valid); err != nil {
+		t.Errorf("NormalizeMnemonic(valid) error = %v", err)
+	}
+}
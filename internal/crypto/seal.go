@@ -0,0 +1,133 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	// sealInfo binds the keys derived for sealing to their purpose.
+	sealInfo = "derived-secret-operator sealed master password v1"
+
+	// x25519KeySize is the size of an X25519 public key.
+	x25519KeySize = 32
+)
+
+// GenerateSealingKey generates an X25519 keypair for sealing master passwords.
+// Both keys are returned Base64 encoded.
+func GenerateSealingKey() (privateKey, publicKey string, err error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate sealing key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(key.Bytes()),
+		base64.StdEncoding.EncodeToString(key.PublicKey().Bytes()), nil
+}
+
+// Seal encrypts plaintext to the given Base64 encoded X25519 public key. An ephemeral key
+// agreement derives a ChaCha20-Poly1305 key, so each sealed value is only readable with the
+// private key. The context, such as the name of the MasterPassword the value is sealed for, is
+// authenticated with the value, so it only unseals with the same context.
+// The result is the Base64 encoded ephemeral public key followed by the ciphertext.
+func Seal(publicKey, context string, plaintext []byte) (string, error) {
+	recipient, err := decodeSealingKey(publicKey, ecdh.X25519().NewPublicKey)
+	if err != nil {
+		return "", err
+	}
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+	aead, err := sealingAEAD(ephemeral, recipient, ephemeral.PublicKey().Bytes(), recipient.Bytes())
+	if err != nil {
+		return "", err
+	}
+
+	// Every message has its own key, so a zero nonce is never reused
+	nonce := make([]byte, aead.NonceSize())
+	sealed := aead.Seal(ephemeral.PublicKey().Bytes(), nonce, plaintext, []byte(context))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Unseal decrypts a value sealed by Seal for the given context with the given Base64 encoded
+// X25519 private key.
+func Unseal(privateKey, context, sealed string) ([]byte, error) {
+	key, err := decodeSealingKey(privateKey, ecdh.X25519().NewPrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, fmt.Errorf("sealed value is not Base64 encoded: %w", err)
+	}
+	if len(data) < x25519KeySize+chacha20poly1305.Overhead {
+		return nil, fmt.Errorf("sealed value is too short")
+	}
+	ephemeral, err := ecdh.X25519().NewPublicKey(data[:x25519KeySize])
+	if err != nil {
+		return nil, fmt.Errorf("invalid ephemeral key: %w", err)
+	}
+	aead, err := sealingAEAD(key, ephemeral, ephemeral.Bytes(), key.PublicKey().Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := aead.Open(nil, make([]byte, aead.NonceSize()), data[x25519KeySize:], []byte(context))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unseal value, it was sealed to another key or for another %q, "+
+			"or altered: %w", context, err)
+	}
+	return plaintext, nil
+}
+
+// decodeSealingKey decodes a Base64 encoded X25519 key.
+func decodeSealingKey[K any](encoded string, parse func([]byte) (K, error)) (K, error) {
+	var zero K
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return zero, fmt.Errorf("sealing key is not Base64 encoded: %w", err)
+	}
+	key, err := parse(data)
+	if err != nil {
+		return zero, fmt.Errorf("invalid sealing key: %w", err)
+	}
+	return key, nil
+}
+
+// sealingAEAD derives the cipher of a sealed value from the key agreement between its ephemeral
+// key and the recipient key. Both public keys salt the derivation, binding it to the message.
+func sealingAEAD(key *ecdh.PrivateKey, peer *ecdh.PublicKey, ephemeral, recipient []byte) (cipher.AEAD, error) {
+	shared, err := key.ECDH(peer)
+	if err != nil {
+		return nil, fmt.Errorf("key agreement failed: %w", err)
+	}
+	salt := append(append([]byte{}, ephemeral...), recipient...)
+	symmetric, err := hkdf.Key(sha256.New, shared, salt, sealInfo, chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive sealing key: %w", err)
+	}
+	return chacha20poly1305.New(symmetric)
+}
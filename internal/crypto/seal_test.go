@@ -0,0 +1,85 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestSealUnseal(t *testing.T) {
+	privateKey, publicKey, err := GenerateSealingKey()
+	if err != nil {
+		t.Fatalf("GenerateSealingKey() error = %v", err)
+	}
+
+	sealed, err := Seal(publicKey, "root", []byte("root-master-password"))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	again, err := Seal(publicKey, "root", []byte("root-master-password"))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if sealed == again {
+		t.Errorf("Seal() returned the same value twice, want a fresh ephemeral key per value")
+	}
+
+	for _, value := range []string{sealed, again} {
+		plaintext, err := Unseal(privateKey, "root", value)
+		if err != nil {
+			t.Fatalf("Unseal() error = %v", err)
+		}
+		if string(plaintext) != "root-master-password" {
+			t.Errorf("Unseal() = %q, want %q", plaintext, "root-master-password")
+		}
+	}
+}
+
+func TestUnsealRejectsOtherKeysAndTampering(t *testing.T) {
+	privateKey, publicKey, err := GenerateSealingKey()
+	if err != nil {
+		t.Fatalf("GenerateSealingKey() error = %v", err)
+	}
+	otherKey, _, err := GenerateSealingKey()
+	if err != nil {
+		t.Fatalf("GenerateSealingKey() error = %v", err)
+	}
+	sealed, err := Seal(publicKey, "root", []byte("root-master-password"))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+
+	if _, err := Unseal(otherKey, "root", sealed); err == nil {
+		t.Errorf("Unseal() with another key succeeded, want an error")
+	}
+	if _, err := Unseal(privateKey, "other", sealed); err == nil {
+		t.Errorf("Unseal() for another context succeeded, want an error")
+	}
+
+	data, _ := base64.StdEncoding.DecodeString(sealed)
+	data[len(data)-1] ^= 1
+	if _, err := Unseal(privateKey, "root", base64.StdEncoding.EncodeToString(data)); err == nil {
+		t.Errorf("Unseal() of an altered value succeeded, want an error")
+	}
+	if _, err := Unseal(privateKey, "root", "not base64!"); err == nil {
+		t.Errorf("Unseal() of an invalid value succeeded, want an error")
+	}
+	if _, err := Seal("c2hvcnQ=", "root", []byte("x")); err == nil {
+		t.Errorf("Seal() to an invalid key succeeded, want an error")
+	}
+}
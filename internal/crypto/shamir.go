@@ -0,0 +1,159 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+)
+
+// shareChecksumSize is the size of the checksum appended to a secret before it is split, so
+// combining wrong or corrupted shares is detected.
+const shareChecksumSize = 8
+
+// gf256Exp and gf256Log are the exponent and logarithm tables of GF(2^8) with the AES
+// polynomial x^8 + x^4 + x^3 + x + 1 and generator 3.
+var gf256Exp, gf256Log = gf256Tables()
+
+// gf256Tables computes the exponent table, repeated once so products need no reduction,
+// and the logarithm table.
+func gf256Tables() (exp [510]byte, log [256]byte) {
+	x := byte(1)
+	for i := range 255 {
+		exp[i] = x
+		exp[i+255] = x
+		log[x] = byte(i)
+		// Multiply by the generator 3: x*2 + x, reduced by the polynomial
+		double := x << 1
+		if x&0x80 != 0 {
+			double ^= 0x1b
+		}
+		x ^= double
+	}
+	return exp, log
+}
+
+// gf256Mul multiplies two elements of GF(2^8).
+func gf256Mul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gf256Exp[int(gf256Log[a])+int(gf256Log[b])]
+}
+
+// gf256Div divides two elements of GF(2^8); b must not be 0.
+func gf256Div(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gf256Exp[int(gf256Log[a])+255-int(gf256Log[b])]
+}
+
+// SplitSecret splits secret into n Shamir shares, any threshold of which reconstruct it.
+// Each share is Base64 encoded and holds its x coordinate followed by one byte per secret byte.
+func SplitSecret(secret []byte, n, threshold int) ([]string, error) {
+	if threshold < 2 || threshold > n || n > 255 {
+		return nil, fmt.Errorf("need 2 <= threshold <= shares <= 255, got threshold %d of %d shares", threshold, n)
+	}
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("secret must not be empty")
+	}
+
+	checksum := sha256.Sum256(secret)
+	payload := append(append([]byte{}, secret...), checksum[:shareChecksumSize]...)
+
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, 1+len(payload))
+		shares[i][0] = byte(i + 1)
+	}
+
+	// Each byte is the constant term of its own random polynomial of degree threshold-1
+	coefficients := make([]byte, threshold-1)
+	for j, value := range payload {
+		if _, err := rand.Read(coefficients); err != nil {
+			return nil, fmt.Errorf("failed to generate random coefficients: %w", err)
+		}
+		for _, share := range shares {
+			x := share[0]
+			// Horner's method
+			y := byte(0)
+			for k := len(coefficients) - 1; k >= 0; k-- {
+				y = gf256Mul(y, x) ^ coefficients[k]
+			}
+			share[1+j] = gf256Mul(y, x) ^ value
+		}
+	}
+
+	encoded := make([]string, n)
+	for i, share := range shares {
+		encoded[i] = base64.StdEncoding.EncodeToString(share)
+	}
+	return encoded, nil
+}
+
+// CombineShares reconstructs a secret split by SplitSecret from at least threshold of its
+// shares. It fails if the shares are too few, malformed or belong to different secrets.
+func CombineShares(encoded []string) ([]byte, error) {
+	if len(encoded) < 2 {
+		return nil, fmt.Errorf("need at least 2 shares, got %d", len(encoded))
+	}
+
+	shares := make([][]byte, len(encoded))
+	seen := make(map[byte]bool, len(encoded))
+	for i, value := range encoded {
+		share, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("share %d is not Base64 encoded: %w", i+1, err)
+		}
+		if len(share) < 2+shareChecksumSize || share[0] == 0 {
+			return nil, fmt.Errorf("share %d is malformed", i+1)
+		}
+		if i > 0 && len(share) != len(shares[0]) {
+			return nil, fmt.Errorf("share %d has a different length than share 1", i+1)
+		}
+		if seen[share[0]] {
+			return nil, fmt.Errorf("share %d duplicates another share", i+1)
+		}
+		seen[share[0]] = true
+		shares[i] = share
+	}
+
+	// Lagrange interpolation at x = 0
+	payload := make([]byte, len(shares[0])-1)
+	for i, share := range shares {
+		basis := byte(1)
+		for j, other := range shares {
+			if i != j {
+				basis = gf256Mul(basis, gf256Div(other[0], other[0]^share[0]))
+			}
+		}
+		for k := range payload {
+			payload[k] ^= gf256Mul(share[1+k], basis)
+		}
+	}
+
+	secret := payload[:len(payload)-shareChecksumSize]
+	checksum := sha256.Sum256(secret)
+	if !bytes.Equal(checksum[:shareChecksumSize], payload[len(secret):]) {
+		return nil, fmt.Errorf("shares do not reconstruct a valid secret; they are too few or do not belong together")
+	}
+	return secret, nil
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"testing"
+)
+
+func TestSplitCombineShares(t *testing.T) {
+	secret := []byte("shamir-master-password-for-testing")
+	shares, err := SplitSecret(secret, 5, 3)
+	if err != nil {
+		t.Fatalf("SplitSecret() error = %v", err)
+	}
+	if len(shares) != 5 {
+		t.Fatalf("SplitSecret() returned %d shares, want 5", len(shares))
+	}
+
+	// Every subset of at least three shares reconstructs the secret
+	for mask := range 1 << len(shares) {
+		var subset []string
+		for i, share := range shares {
+			if mask&(1<<i) != 0 {
+				subset = append(subset, share)
+			}
+		}
+		if len(subset) < 3 {
+			continue
+		}
+		got, err := CombineShares(subset)
+		if err != nil {
+			t.Fatalf("CombineShares(%05b) error = %v", mask, err)
+		}
+		if string(got) != string(secret) {
+			t.Errorf("CombineShares(%05b) = %q, want %q", mask, got, secret)
+		}
+	}
+}
+
+func TestCombineSharesRejectsInvalidShares(t *testing.T) {
+	shares, err := SplitSecret([]byte("shamir-master-password-for-testing"), 5, 3)
+	if err != nil {
+		t.Fatalf("SplitSecret() error = %v", err)
+	}
+	other, err := SplitSecret([]byte("another-master-password-for-test"), 5, 3)
+	if err != nil {
+		t.Fatalf("SplitSecret() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		shares []string
+	}{
+		{name: "below threshold", shares: shares[:2]},
+		{name: "single share", shares: shares[:1]},
+		{name: "duplicate share", shares: []string{shares[0], shares[1], shares[0]}},
+		{name: "shares of another secret", shares: []string{shares[0], shares[1], other[2]}},
+		{name: "not base64", shares: []string{shares[0], shares[1], "not base64!"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := CombineShares(tt.shares); err == nil {
+				t.Errorf("CombineShares() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestSplitSecretValidatesThreshold(t *testing.T) {
+	for _, tc := range []struct{ n, threshold int }{{3, 1}, {3, 4}, {256, 2}} {
+		if _, err := SplitSecret([]byte("secret"), tc.n, tc.threshold); err == nil {
+			t.Errorf("SplitSecret(n=%d, threshold=%d) succeeded, want an error", tc.n, tc.threshold)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// commonPasswords are rejected as master passwords whatever the policy.
+var commonPasswords = []string{
+	"123456", "123456789", "12345678", "1234567890", "qwerty", "qwertyuiop", "password", "password1",
+	"passw0rd", "p@ssw0rd", "letmein", "welcome", "changeme", "changeit", "admin", "administrator",
+	"secret", "default", "master", "masterpassword", "iloveyou", "trustno1", "abc123", "111111",
+	"000000", "dragon", "monkey", "football", "baseball", "sunshine", "princess", "superman",
+	"kubernetes", "correcthorsebatterystaple",
+}
+
+// StrengthPolicy holds the requirements a master password provided by a user must meet.
+type StrengthPolicy struct {
+	// MinLength is the minimum number of characters.
+	MinLength int
+	// MinEntropyBits is the minimum entropy estimated by EstimateEntropy.
+	MinEntropyBits int
+	// Banned lists passwords rejected in addition to a built-in list of common passwords.
+	Banned []string
+}
+
+// Check returns an error describing why the password does not meet the policy. Banned passwords
+// are matched ignoring case.
+func (p StrengthPolicy) Check(password string) error {
+	if length := utf8.RuneCountInString(password); length < p.MinLength {
+		return fmt.Errorf("the master password has %d characters, at least %d are required", length, p.MinLength)
+	}
+	if isBanned(password, p.Banned) {
+		return fmt.Errorf("the master password is on the list of banned passwords")
+	}
+	if entropy := EstimateEntropy(password); entropy < float64(p.MinEntropyBits) {
+		return fmt.Errorf("the master password has an estimated entropy of %.0f bits, at least %d are required",
+			entropy, p.MinEntropyBits)
+	}
+	return nil
+}
+
+// isBanned reports whether the password is a common password or one of banned, ignoring case.
+func isBanned(password string, banned []string) bool {
+	lower := strings.ToLower(password)
+	if slices.Contains(commonPasswords, lower) {
+		return true
+	}
+	return slices.ContainsFunc(banned, func(b string) bool {
+		return strings.EqualFold(b, password)
+	})
+}
+
+// EstimateEntropy estimates the entropy of a password in bits as its length times the Shannon
+// entropy of its character frequencies. Repeated characters and patterns such as "abab" count
+// for little, and short passwords are underestimated rather than overestimated.
+func EstimateEntropy(password string) float64 {
+	counts := make(map[rune]int)
+	length := 0
+	for _, r := range password {
+		counts[r]++
+		length++
+	}
+
+	perCharacter := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(length)
+		perCharacter -= p * math.Log2(p)
+	}
+	return float64(length) * perCharacter
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEstimateEntropy(t *testing.T) {
+	tests := []struct {
+		password string
+		min, max float64
+	}{
+		{password: "", min: 0, max: 0},
+		{password: "aaaaaaaaaaaaaaaaaaaaaaaa", min: 0, max: 0},
+		{password: "abababababababab", min: 16, max: 16},
+		{password: "abcdefgh", min: 24, max: 24},
+	}
+	for _, tt := range tests {
+		if got := EstimateEntropy(tt.password); got < tt.min || got > tt.max {
+			t.Errorf("EstimateEntropy(%q) = %.1f, want between %.0f and %.0f", tt.password, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestStrengthPolicyCheck(t *testing.T) {
+	policy := StrengthPolicy{MinLength: 16, MinEntropyBits: 64, Banned: []string{"Platform-Root-2024-Password"}}
+	generated, err := GenerateRandomPassword(86)
+	if err != nil {
+		t.Fatalf("GenerateRandomPassword() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		wantErr  string
+	}{
+		{name: "generated", password: generated},
+		{name: "too short", password: "x", wantErr: "at least 16"},
+		{name: "common", password: "CorrectHorseBatteryStaple", wantErr: "banned"},
+		{name: "banned", password: "platform-root-2024-password", wantErr: "banned"},
+		{name: "low entropy", password: strings.Repeat("ab", 10), wantErr: "entropy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Check(tt.password)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Check() error = %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("Check() error = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+//go:build pkcs11 && cgo
+// +build pkcs11,cgo
+
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deriver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+)
+
+// PKCS11Supported reports whether the operator was built with PKCS#11 support
+const PKCS11Supported = true
+
+var (
+	// modulesMu guards modules
+	modulesMu sync.Mutex
+	// modules holds the PKCS#11 libraries loaded and initialized so far, by path. A library is
+	// initialized once per process, so it is never finalized
+	modules = map[string]*pkcs11.Ctx{}
+)
+
+// loadModule returns the initialized PKCS#11 library at path
+func loadModule(path string) (*pkcs11.Ctx, error) {
+	modulesMu.Lock()
+	defer modulesMu.Unlock()
+	if module, ok := modules[path]; ok {
+		return module, nil
+	}
+	module := pkcs11.New(path)
+	if module == nil {
+		return nil, fmt.Errorf("failed to load PKCS#11 module %s", path)
+	}
+	if err := module.Initialize(); err != nil && !isPKCS11Error(err, pkcs11.CKR_CRYPTOKI_ALREADY_INITIALIZED) {
+		module.Destroy()
+		return nil, fmt.Errorf("failed to initialize PKCS#11 module %s: %w", path, err)
+	}
+	modules[path] = module
+	return module, nil
+}
+
+// isPKCS11Error reports whether err is the PKCS#11 return value code
+func isPKCS11Error(err error, code uint) bool {
+	var p11Err pkcs11.Error
+	return errors.As(err, &p11Err) && uint(p11Err) == code
+}
+
+// PKCS11 derives secrets with HMAC-SHA256 of a secret key held by a PKCS#11 token
+type PKCS11 struct {
+	module   *pkcs11.Ctx
+	slot     uint
+	keyLabel string
+	pin      string
+}
+
+// NewPKCS11 returns a deriver signing with the key labelled keyLabel on the token labelled
+// tokenLabel, loaded from the PKCS#11 library at modulePath and logged into with pin
+func NewPKCS11(modulePath, tokenLabel, keyLabel, pin string) (*PKCS11, error) {
+	module, err := loadModule(modulePath)
+	if err != nil {
+		return nil, err
+	}
+	slots, err := module.GetSlotList(true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PKCS#11 slots: %w", err)
+	}
+	for _, slot := range slots {
+		info, err := module.GetTokenInfo(slot)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get PKCS#11 token info: %w", err)
+		}
+		if info.Label == tokenLabel {
+			return &PKCS11{module: module, slot: slot, keyLabel: keyLabel, pin: pin}, nil
+		}
+	}
+	return nil, fmt.Errorf("no PKCS#11 token is labelled %q", tokenLabel)
+}
+
+// Derive returns the HMAC-SHA256 of input with the key. Every call uses its own session, so
+// concurrent reconciles do not share one
+func (p *PKCS11) Derive(_ context.Context, input []byte) ([]byte, error) {
+	session, err := p.module.OpenSession(p.slot, pkcs11.CKF_SERIAL_SESSION)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PKCS#11 session: %w", err)
+	}
+	defer p.module.CloseSession(session) //nolint:errcheck
+
+	// Login state is shared by every session of the token
+	err = p.module.Login(session, pkcs11.CKU_USER, p.pin)
+	if err != nil && !isPKCS11Error(err, pkcs11.CKR_USER_ALREADY_LOGGED_IN) {
+		return nil, fmt.Errorf("failed to log into the PKCS#11 token: %w", err)
+	}
+
+	key, err := p.findKey(session)
+	if err != nil {
+		return nil, err
+	}
+	mechanism := []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_SHA256_HMAC, nil)}
+	if err := p.module.SignInit(session, mechanism, key); err != nil {
+		return nil, fmt.Errorf("failed to start PKCS#11 HMAC: %w", err)
+	}
+	hmac, err := p.module.Sign(session, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute PKCS#11 HMAC: %w", err)
+	}
+	return hmac, nil
+}
+
+// findKey returns the handle of the secret key labelled keyLabel
+func (p *PKCS11) findKey(session pkcs11.SessionHandle) (pkcs11.ObjectHandle, error) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, p.keyLabel),
+	}
+	if err := p.module.FindObjectsInit(session, template); err != nil {
+		return 0, fmt.Errorf("failed to search PKCS#11 keys: %w", err)
+	}
+	keys, _, err := p.module.FindObjects(session, 2)
+	if finalErr := p.module.FindObjectsFinal(session); err == nil {
+		err = finalErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to search PKCS#11 keys: %w", err)
+	}
+	switch len(keys) {
+	case 0:
+		return 0, fmt.Errorf("no PKCS#11 secret key is labelled %q", p.keyLabel)
+	case 1:
+		return keys[0], nil
+	default:
+		return 0, fmt.Errorf("several PKCS#11 secret keys are labelled %q", p.keyLabel)
+	}
+}
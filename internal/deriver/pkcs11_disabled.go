@@ -0,0 +1,41 @@
+//go:build !pkcs11 || !cgo
+// +build !pkcs11 !cgo
+
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deriver
+
+import (
+	"context"
+	"fmt"
+)
+
+// PKCS11Supported reports whether the operator was built with PKCS#11 support
+const PKCS11Supported = false
+
+// PKCS11 is unavailable without the pkcs11 build tag and cgo
+type PKCS11 struct{}
+
+// NewPKCS11 fails: the operator was built without PKCS#11 support
+func NewPKCS11(_, _, _, _ string) (*PKCS11, error) {
+	return nil, fmt.Errorf("the operator was built without PKCS#11 support; rebuild it with -tags pkcs11")
+}
+
+// Derive fails: the operator was built without PKCS#11 support
+func (p *PKCS11) Derive(_ context.Context, _ []byte) ([]byte, error) {
+	return nil, fmt.Errorf("the operator was built without PKCS#11 support")
+}
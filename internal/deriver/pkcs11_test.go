@@ -0,0 +1,65 @@
+//go:build pkcs11 && cgo
+// +build pkcs11,cgo
+
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deriver
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/oleksiyp/derived-secret-operator/internal/crypto"
+)
+
+// TestPKCS11 derives with a SoftHSM token, set up with
+//
+//	softhsm2-util --init-token --free --label derived --pin 1234 --so-pin 5678
+//	pkcs11-tool --module $PKCS11_MODULE --login --pin 1234 --token-label derived \
+//	  --keygen --key-type GENERIC:32 --label derived-key --usage-sign
+func TestPKCS11(t *testing.T) {
+	module := os.Getenv("PKCS11_MODULE")
+	if module == "" {
+		t.Skip("PKCS11_MODULE is not set")
+	}
+	deriver, err := NewPKCS11(module, "derived", "derived-key", "1234")
+	if err != nil {
+		t.Fatalf("NewPKCS11() error = %v", err)
+	}
+
+	ctx := context.Background()
+	first, err := crypto.DeriveSecretWith(ctx, deriver, "namespace/name/key", 64)
+	if err != nil {
+		t.Fatalf("DeriveSecretWith() error = %v", err)
+	}
+	second, err := crypto.DeriveSecretWith(ctx, deriver, "namespace/name/key", 64)
+	if err != nil || second != first {
+		t.Errorf("DeriveSecretWith() = %q, %v, want the same secret %q", second, err, first)
+	}
+
+	if _, err := NewPKCS11(module, "missing", "derived-key", "1234"); err == nil {
+		t.Errorf("NewPKCS11() found a token that does not exist")
+	}
+	missing, err := NewPKCS11(module, "derived", "missing-key", "1234")
+	if err != nil {
+		t.Fatalf("NewPKCS11() error = %v", err)
+	}
+	if _, err := missing.Derive(ctx, []byte("input")); err == nil {
+		t.Errorf("Derive() with a key that does not exist succeeded")
+	}
+}
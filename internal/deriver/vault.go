@@ -0,0 +1,162 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package deriver implements crypto.Deriver with keys held by external backends, so secrets
+// are derived without the key ever reaching the operator.
+package deriver
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// vaultTimeout bounds every request to Vault
+	vaultTimeout = 10 * time.Second
+
+	// maxVaultResponseSize bounds the responses read from Vault
+	maxVaultResponseSize = 1 << 20
+)
+
+// VaultTransitConfig configures a VaultTransit deriver
+type VaultTransitConfig struct {
+	// Address is the URL of the Vault server
+	Address string
+	// Mount is the path the Transit secrets engine is mounted at
+	Mount string
+	// Key is the name of the Transit key
+	Key string
+	// KeyVersion pins the version of the key; 0 uses the latest one
+	KeyVersion int
+	// Namespace is the Vault Enterprise namespace, if any
+	Namespace string
+	// Token authenticates the requests
+	Token string
+	// CABundle is the PEM encoded CA bundle verifying the server; empty uses the system roots
+	CABundle string
+}
+
+// VaultTransit derives secrets with HMAC-SHA256 of a HashiCorp Vault Transit key
+type VaultTransit struct {
+	config   VaultTransitConfig
+	endpoint string
+	client   *http.Client
+}
+
+// NewVaultTransit returns a deriver computing HMACs with the configured Transit key
+func NewVaultTransit(config VaultTransitConfig) (*VaultTransit, error) {
+	if _, err := url.Parse(config.Address); err != nil {
+		return nil, fmt.Errorf("invalid Vault address %q: %w", config.Address, err)
+	}
+	if config.Mount == "" {
+		config.Mount = "transit"
+	}
+	if config.Key == "" || config.Token == "" {
+		return nil, fmt.Errorf("a Transit key and a Vault token are required")
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.CABundle != "" {
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM([]byte(config.CABundle)) {
+			return nil, fmt.Errorf("the CA bundle holds no PEM certificates")
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+	}
+
+	return &VaultTransit{
+		config: config,
+		endpoint: fmt.Sprintf("%s/v1/%s/hmac/%s/sha2-256", strings.TrimRight(config.Address, "/"),
+			strings.Trim(config.Mount, "/"), url.PathEscape(config.Key)),
+		client: &http.Client{Transport: transport, Timeout: vaultTimeout},
+	}, nil
+}
+
+// vaultHMACRequest is the body of a Transit HMAC request
+type vaultHMACRequest struct {
+	Input      string `json:"input"`
+	KeyVersion int    `json:"key_version,omitempty"`
+}
+
+// vaultHMACResponse is the body of a Transit HMAC response, or of a Vault error
+type vaultHMACResponse struct {
+	Data struct {
+		HMAC string `json:"hmac"`
+	} `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+// Derive returns the HMAC-SHA256 of input with the Transit key
+func (v *VaultTransit) Derive(ctx context.Context, input []byte) ([]byte, error) {
+	body, err := json.Marshal(vaultHMACRequest{
+		Input:      base64.StdEncoding.EncodeToString(input),
+		KeyVersion: v.config.KeyVersion,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Vault request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Vault-Token", v.config.Token)
+	if v.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.config.Namespace)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault request failed: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	var result vaultHMACResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxVaultResponseSize)).Decode(&result); err != nil &&
+		resp.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("failed to decode Vault response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault answered %s: %s", resp.Status, strings.Join(result.Errors, "; "))
+	}
+
+	// HMACs are prefixed with the key version: vault:v<version>:<base64>
+	parts := strings.SplitN(result.Data.HMAC, ":", 3)
+	if len(parts) != 3 || parts[0] != "vault" {
+		return nil, fmt.Errorf("unexpected HMAC format from Vault")
+	}
+	hmac, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode HMAC from Vault: %w", err)
+	}
+	return hmac, nil
+}
+
+// Close closes the idle connections to Vault. The deriver may still be used afterwards
+func (v *VaultTransit) Close() error {
+	v.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deriver
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/oleksiyp/derived-secret-operator/internal/crypto"
+)
+
+// newFakeTransit serves the Transit HMAC endpoint of the "derived" key on the "transit" mount,
+// keyed by one secret per key version, for the "test-token" token
+func newFakeTransit(t *testing.T) *httptest.Server {
+	t.Helper()
+	versions := map[int][]byte{1: []byte("transit-key-v1"), 2: []byte("transit-key-v2")}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("X-Vault-Token") != "test-token" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		if req.Method != http.MethodPost || req.URL.Path != "/v1/transit/hmac/derived/sha2-256" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":["no handler for route"]}`))
+			return
+		}
+		var body vaultHMACRequest
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		input, err := base64.StdEncoding.DecodeString(body.Input)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		version := body.KeyVersion
+		if version == 0 {
+			version = len(versions)
+		}
+		mac := hmac.New(sha256.New, versions[version])
+		mac.Write(input)
+		_, _ = fmt.Fprintf(w, `{"data":{"hmac":"vault:v%d:%s"}}`, version,
+			base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestVaultTransit(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeTransit(t)
+	newDeriver := func(config VaultTransitConfig) *VaultTransit {
+		t.Helper()
+		config.Address = server.URL
+		config.Key = "derived"
+		deriver, err := NewVaultTransit(config)
+		if err != nil {
+			t.Fatalf("NewVaultTransit() error = %v", err)
+		}
+		return deriver
+	}
+
+	latest := newDeriver(VaultTransitConfig{Token: "test-token"})
+	value, err := crypto.DeriveSecretWith(ctx, latest, "namespace/name/key", 64)
+	if err != nil {
+		t.Fatalf("DeriveSecretWith() error = %v", err)
+	}
+	again, err := crypto.DeriveSecretWith(ctx, latest, "namespace/name/key", 64)
+	if err != nil || again != value {
+		t.Errorf("DeriveSecretWith() = %q, %v, want the same secret %q", again, err, value)
+	}
+	other, err := crypto.DeriveSecretWith(ctx, latest, "namespace/name/other", 64)
+	if err != nil || other == value {
+		t.Errorf("DeriveSecretWith() of another context = %q, %v, want another secret", other, err)
+	}
+
+	// Pinning a key version keeps the secrets derived before the key was rotated
+	pinned, err := crypto.DeriveSecretWith(ctx, newDeriver(VaultTransitConfig{Token: "test-token", KeyVersion: 1}),
+		"namespace/name/key", 64)
+	if err != nil || pinned == value {
+		t.Errorf("DeriveSecretWith() with key version 1 = %q, %v, want another secret", pinned, err)
+	}
+
+	input := []byte("namespace/name/key")
+	mac := hmac.New(sha256.New, []byte("transit-key-v2"))
+	mac.Write(input)
+	if output, err := latest.Derive(ctx, input); err != nil || !bytes.Equal(output, mac.Sum(nil)) {
+		t.Errorf("Derive() = %x, %v, want the HMAC of the Transit key", output, err)
+	}
+
+	if _, err := newDeriver(VaultTransitConfig{Token: "stolen"}).Derive(ctx, input); err == nil {
+		t.Errorf("Derive() with an invalid token succeeded")
+	}
+	if _, err := newDeriver(VaultTransitConfig{Token: "test-token", Mount: "other"}).Derive(ctx, input); err == nil {
+		t.Errorf("Derive() on another mount succeeded")
+	}
+	if _, err := NewVaultTransit(VaultTransitConfig{Address: server.URL, Key: "derived", Token: "test-token",
+		CABundle: "not a certificate"}); err == nil {
+		t.Errorf("NewVaultTransit() accepted a CA bundle without certificates")
+	}
+}
+
+// TestVaultTransitDevServer derives against a real Vault, such as `vault server -dev` with
+// `vault secrets enable transit && vault write -f transit/keys/derived type=hmac`
+func TestVaultTransitDevServer(t *testing.T) {
+	address, token := os.Getenv("VAULT_ADDR"), os.Getenv("VAULT_TOKEN")
+	if address == "" || token == "" {
+		t.Skip("VAULT_ADDR and VAULT_TOKEN are not set")
+	}
+	deriver, err := NewVaultTransit(VaultTransitConfig{Address: address, Key: "derived", Token: token})
+	if err != nil {
+		t.Fatalf("NewVaultTransit() error = %v", err)
+	}
+	first, err := crypto.DeriveSecretWith(context.Background(), deriver, "namespace/name/key", 64)
+	if err != nil {
+		t.Fatalf("DeriveSecretWith() error = %v", err)
+	}
+	second, err := crypto.DeriveSecretWith(context.Background(), deriver, "namespace/name/key", 64)
+	if err != nil || second != first {
+		t.Errorf("DeriveSecretWith() = %q, %v, want the same secret %q", second, err, first)
+	}
+}
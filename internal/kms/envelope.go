@@ -0,0 +1,184 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kms encrypts master passwords into envelopes whose data encryption key is encrypted by
+// a key management service, so a master password Secret is useless without access to the KMS.
+package kms
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/kms/pkg/service"
+)
+
+const (
+	// envelopePrefix starts every envelope, telling envelopes apart from plain text master passwords
+	envelopePrefix = "dso:kms:v1:"
+
+	// dataKeySize is the size of the AES-256 data encryption key of an envelope
+	dataKeySize = 32
+)
+
+// Service is a key management service holding the key encryption key of envelopes. It is the
+// service interface of Kubernetes KMS v2 plugins
+type Service = service.Service
+
+// envelope is a master password encrypted with a data encryption key, which is encrypted by a
+// Service
+type envelope struct {
+	KeyID        string            `json:"keyID"`
+	EncryptedKey []byte            `json:"encryptedKey"`
+	Annotations  map[string][]byte `json:"annotations,omitempty"`
+	Ciphertext   []byte            `json:"ciphertext"`
+}
+
+// IsEnvelope reports whether a secret value is an envelope rather than a plain text master password
+func IsEnvelope(value []byte) bool {
+	return bytes.HasPrefix(value, []byte(envelopePrefix))
+}
+
+// Client encrypts master passwords into envelopes through a Service and decrypts them, keeping
+// decrypted envelopes in memory for a short time so reconciles do not call the KMS every time
+type Client struct {
+	service Service
+	ttl     time.Duration
+	now     func() time.Time
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]cached
+}
+
+// cached is a decrypted envelope and when it must be decrypted again
+type cached struct {
+	plaintext []byte
+	expires   time.Time
+}
+
+// NewClient returns a Client of the Service caching decrypted envelopes for ttl
+func NewClient(svc Service, ttl time.Duration) *Client {
+	return &Client{
+		service: svc,
+		ttl:     ttl,
+		now:     time.Now,
+		cache:   make(map[[sha256.Size]byte]cached),
+	}
+}
+
+// Encrypt encrypts plaintext into an envelope with a new data encryption key
+func (c *Client) Encrypt(ctx context.Context, plaintext []byte) ([]byte, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data encryption key: %w", err)
+	}
+	response, err := c.service.Encrypt(ctx, string(uuid.NewUUID()), dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt data encryption key with the KMS: %w", err)
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	data, err := json.Marshal(envelope{
+		KeyID:        response.KeyID,
+		EncryptedKey: response.Ciphertext,
+		Annotations:  response.Annotations,
+		Ciphertext:   aead.Seal(nonce, nonce, plaintext, []byte(envelopePrefix)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode envelope: %w", err)
+	}
+	return append([]byte(envelopePrefix), data...), nil
+}
+
+// Decrypt decrypts an envelope written by Encrypt, from the cache if it was decrypted recently
+func (c *Client) Decrypt(ctx context.Context, value []byte) ([]byte, error) {
+	if !IsEnvelope(value) {
+		return nil, fmt.Errorf("value is not a KMS envelope")
+	}
+	digest := sha256.Sum256(value)
+	now := c.now()
+
+	c.mu.Lock()
+	entry, ok := c.cache[digest]
+	for key, e := range c.cache {
+		if !now.Before(e.expires) {
+			delete(c.cache, key)
+		}
+	}
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.plaintext, nil
+	}
+
+	var env envelope
+	if err := json.Unmarshal(value[len(envelopePrefix):], &env); err != nil {
+		return nil, fmt.Errorf("failed to decode envelope: %w", err)
+	}
+	dataKey, err := c.service.Decrypt(ctx, string(uuid.NewUUID()), &service.DecryptRequest{
+		Ciphertext:  env.EncryptedKey,
+		KeyID:       env.KeyID,
+		Annotations: env.Annotations,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data encryption key with the KMS: %w", err)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Ciphertext) < aead.NonceSize() {
+		return nil, fmt.Errorf("envelope ciphertext is truncated")
+	}
+	nonce, ciphertext := env.Ciphertext[:aead.NonceSize()], env.Ciphertext[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(envelopePrefix))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt envelope: %w", err)
+	}
+
+	if c.ttl > 0 {
+		c.mu.Lock()
+		c.cache[digest] = cached{plaintext: plaintext, expires: now.Add(c.ttl)}
+		c.mu.Unlock()
+	}
+	return plaintext, nil
+}
+
+// newAEAD returns AES-256-GCM with the data encryption key
+func newAEAD(dataKey []byte) (cipher.AEAD, error) {
+	if len(dataKey) != dataKeySize {
+		return nil, fmt.Errorf("data encryption key has %d bytes, want %d", len(dataKey), dataKeySize)
+	}
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kms
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"k8s.io/kms/pkg/service"
+)
+
+func newTestLocalService(t *testing.T) *LocalService {
+	t.Helper()
+	key, err := GenerateLocalKey()
+	if err != nil {
+		t.Fatalf("GenerateLocalKey() error = %v", err)
+	}
+	svc, err := NewLocalService(key)
+	if err != nil {
+		t.Fatalf("NewLocalService() error = %v", err)
+	}
+	return svc
+}
+
+// countingService counts the decryptions of the data encryption keys
+type countingService struct {
+	Service
+	decrypts int
+}
+
+func (s *countingService) Decrypt(ctx context.Context, uid string, req *service.DecryptRequest) ([]byte, error) {
+	s.decrypts++
+	return s.Service.Decrypt(ctx, uid, req)
+}
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	svc := &countingService{Service: newTestLocalService(t)}
+	client := NewClient(svc, time.Minute)
+	now := time.Now()
+	client.now = func() time.Time { return now }
+
+	value, err := client.Encrypt(ctx, []byte("kms-master-password"))
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	if !IsEnvelope(value) || bytes.Contains(value, []byte("kms-master-password")) {
+		t.Fatalf("Encrypt() = %q, want an envelope hiding the master password", value)
+	}
+	if IsEnvelope([]byte("kms-master-password")) {
+		t.Errorf("IsEnvelope() reported a plain text master password as an envelope")
+	}
+
+	for range 3 {
+		plaintext, err := client.Decrypt(ctx, value)
+		if err != nil {
+			t.Fatalf("Decrypt() error = %v", err)
+		}
+		if string(plaintext) != "kms-master-password" {
+			t.Errorf("Decrypt() = %q, want the encrypted master password", plaintext)
+		}
+	}
+	if svc.decrypts != 1 {
+		t.Errorf("KMS decrypted %d times, want 1 while the envelope is cached", svc.decrypts)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := client.Decrypt(ctx, value); err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+	if svc.decrypts != 2 {
+		t.Errorf("KMS decrypted %d times, want 2 after the cache expired", svc.decrypts)
+	}
+}
+
+func TestEnvelopeRejectsOtherKeysAndTampering(t *testing.T) {
+	ctx := context.Background()
+	value, err := NewClient(newTestLocalService(t), 0).Encrypt(ctx, []byte("kms-master-password"))
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+
+	if _, err := NewClient(newTestLocalService(t), 0).Decrypt(ctx, value); err == nil {
+		t.Errorf("Decrypt() with another key succeeded")
+	}
+
+	client := NewClient(newTestLocalService(t), 0)
+	value, err = client.Encrypt(ctx, []byte("kms-master-password"))
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	tampered := bytes.Replace(value, []byte(`"ciphertext":"`), []byte(`"ciphertext":"AAAA`), 1)
+	if _, err := client.Decrypt(ctx, tampered); err == nil {
+		t.Errorf("Decrypt() of a tampered envelope succeeded")
+	}
+	if _, err := client.Decrypt(ctx, []byte("kms-master-password")); err == nil {
+		t.Errorf("Decrypt() of a plain text master password succeeded")
+	}
+}
+
+func TestGRPCService(t *testing.T) {
+	ctx := context.Background()
+	socket := filepath.Join(t.TempDir(), "kms.sock")
+	plugin := service.NewGRPCService(socket, 5*time.Second, newTestLocalService(t))
+	go plugin.ListenAndServe() //nolint:errcheck
+	t.Cleanup(plugin.Shutdown)
+
+	svc, err := NewGRPCService("unix://"+socket, 5*time.Second)
+	if err != nil {
+		t.Fatalf("NewGRPCService() error = %v", err)
+	}
+	t.Cleanup(func() { _ = svc.Close() })
+
+	// The plugin starts listening in the background
+	var status *service.StatusResponse
+	for range 50 {
+		if status, err = svc.Status(ctx); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil || status.Healthz != "ok" {
+		t.Fatalf("Status() = %+v, %v, want a healthy plugin", status, err)
+	}
+
+	client := NewClient(svc, 0)
+	value, err := client.Encrypt(ctx, []byte("kms-master-password"))
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	plaintext, err := client.Decrypt(ctx, value)
+	if err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+	if string(plaintext) != "kms-master-password" {
+		t.Errorf("Decrypt() = %q, want the encrypted master password", plaintext)
+	}
+
+	if _, err := NewGRPCService("tcp://localhost:1234", time.Second); err == nil {
+		t.Errorf("NewGRPCService() accepted an endpoint that is not a unix socket")
+	}
+}
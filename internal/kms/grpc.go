@@ -0,0 +1,103 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kms
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	kmsapi "k8s.io/kms/apis/v2"
+	"k8s.io/kms/pkg/service"
+)
+
+// GRPCService is a Service backed by a Kubernetes KMS v2 plugin listening on a unix socket
+type GRPCService struct {
+	conn    *grpc.ClientConn
+	client  kmsapi.KeyManagementServiceClient
+	timeout time.Duration
+}
+
+var _ Service = &GRPCService{}
+
+// NewGRPCService connects to the KMS v2 plugin at endpoint, a unix socket path with or without
+// the unix:// scheme. Every call is bounded by timeout
+func NewGRPCService(endpoint string, timeout time.Duration) (*GRPCService, error) {
+	socket := strings.TrimPrefix(endpoint, "unix://")
+	if socket == "" || strings.Contains(socket, "://") {
+		return nil, fmt.Errorf("KMS endpoint must be a unix socket, got %q", endpoint)
+	}
+	conn, err := grpc.NewClient("unix://"+socket, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to KMS plugin at %s: %w", endpoint, err)
+	}
+	return &GRPCService{conn: conn, client: kmsapi.NewKeyManagementServiceClient(conn), timeout: timeout}, nil
+}
+
+// Close closes the connection to the plugin
+func (s *GRPCService) Close() error {
+	return s.conn.Close()
+}
+
+// Encrypt encrypts data with the current key of the plugin
+func (s *GRPCService) Encrypt(ctx context.Context, uid string, data []byte) (*service.EncryptResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	response, err := s.client.Encrypt(ctx, &kmsapi.EncryptRequest{Plaintext: data, Uid: uid})
+	if err != nil {
+		return nil, err
+	}
+	return &service.EncryptResponse{
+		Ciphertext:  response.Ciphertext,
+		KeyID:       response.KeyId,
+		Annotations: response.Annotations,
+	}, nil
+}
+
+// Decrypt decrypts data with the key it was encrypted with
+func (s *GRPCService) Decrypt(ctx context.Context, uid string, req *service.DecryptRequest) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	response, err := s.client.Decrypt(ctx, &kmsapi.DecryptRequest{
+		Ciphertext:  req.Ciphertext,
+		Uid:         uid,
+		KeyId:       req.KeyID,
+		Annotations: req.Annotations,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response.Plaintext, nil
+}
+
+// Status reports the health and the current key of the plugin
+func (s *GRPCService) Status(ctx context.Context) (*service.StatusResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	response, err := s.client.Status(ctx, &kmsapi.StatusRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return &service.StatusResponse{
+		Version: response.Version,
+		Healthz: response.Healthz,
+		KeyID:   response.KeyId,
+	}, nil
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package policy checks master passwords provided by users before the operator uses them, so
+// the controllers and the unseal endpoint accept the same master passwords.
+package policy
+
+import (
+	secretsv1beta1 "github.com/oleksiyp/derived-secret-operator/api/v1beta1"
+	"github.com/oleksiyp/derived-secret-operator/internal/crypto"
+)
+
+const (
+	// ReasonInvalidMnemonic reports a mnemonic master password with an unknown word or a wrong
+	// checksum
+	ReasonInvalidMnemonic = "InvalidMnemonic"
+
+	// ReasonWeakMasterPassword reports a master password that does not meet the validation policy
+	// of its MasterPassword
+	ReasonWeakMasterPassword = "WeakMasterPassword"
+)
+
+// Error reports a master password rejected by CheckMasterPassword
+type Error struct {
+	// Reason is ReasonInvalidMnemonic or ReasonWeakMasterPassword
+	Reason string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// CheckMasterPassword checks a master password before it is used and returns it in canonical
+// form. The master password of a mnemonic MasterPassword must be a mnemonic with a valid
+// checksum, and is normalized so it derives the same secrets however it was typed. If the
+// MasterPassword has a validation policy, the master password must meet it
+func CheckMasterPassword(spec *secretsv1beta1.MasterPasswordSpec, password string) (string, error) {
+	if spec.IsMnemonic() {
+		normalized, err := crypto.NormalizeMnemonic(password)
+		if err != nil {
+			return "", &Error{Reason: ReasonInvalidMnemonic, Err: err}
+		}
+		password = normalized
+	}
+
+	if v := spec.Validation; v != nil {
+		policy := crypto.StrengthPolicy{MinLength: v.MinLength, MinEntropyBits: v.MinEntropyBits, Banned: v.BannedPasswords}
+		if err := policy.Check(password); err != nil {
+			return "", &Error{Reason: ReasonWeakMasterPassword, Err: err}
+		}
+	}
+	return password, nil
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	secretsv1beta1 "github.com/oleksiyp/derived-secret-operator/api/v1beta1"
+	"github.com/oleksiyp/derived-secret-operator/internal/crypto"
+)
+
+func TestCheckMasterPassword(t *testing.T) {
+	mnemonic, err := crypto.GenerateMnemonic()
+	if err != nil {
+		t.Fatalf("GenerateMnemonic() error = %v", err)
+	}
+	mnemonicSpec := &secretsv1beta1.MasterPasswordSpec{Format: secretsv1beta1.MasterPasswordFormatMnemonic}
+	validatedSpec := &secretsv1beta1.MasterPasswordSpec{
+		Validation: &secretsv1beta1.MasterPasswordValidation{MinLength: 16, MinEntropyBits: 64},
+	}
+
+	tests := []struct {
+		name       string
+		spec       *secretsv1beta1.MasterPasswordSpec
+		password   string
+		want       string
+		wantReason string
+	}{
+		{name: "no policy", spec: &secretsv1beta1.MasterPasswordSpec{}, password: "short", want: "short"},
+		{name: "typed mnemonic", spec: mnemonicSpec, password: "  " + strings.ToUpper(mnemonic), want: mnemonic},
+		{name: "mistyped mnemonic", spec: mnemonicSpec, password: mnemonic + "s", wantReason: ReasonInvalidMnemonic},
+		{name: "weak", spec: validatedSpec, password: "short", wantReason: ReasonWeakMasterPassword},
+		{name: "strong", spec: validatedSpec, password: "kV9#tq2!Lm8@xR4$wZ", want: "kV9#tq2!Lm8@xR4$wZ"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CheckMasterPassword(tt.spec, tt.password)
+			if tt.wantReason != "" {
+				var rejected *Error
+				if !errors.As(err, &rejected) || rejected.Reason != tt.wantReason {
+					t.Fatalf("CheckMasterPassword() error = %v, want reason %s", err, tt.wantReason)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CheckMasterPassword() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("CheckMasterPassword() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package unseal
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	secretsv1beta1 "github.com/oleksiyp/derived-secret-operator/api/v1beta1"
+	"github.com/oleksiyp/derived-secret-operator/internal/crypto"
+	"github.com/oleksiyp/derived-secret-operator/internal/policy"
+)
+
+const (
+	// Path is the path prefix of the unseal endpoint. The MasterPassword name follows it
+	Path = "/unseal/"
+
+	// unsealVerb is the verb on a MasterPassword that allows unsealing it
+	unsealVerb = "unseal"
+
+	// maxPasswordSize bounds the request body holding the master password
+	maxPasswordSize = 4096
+
+	// verifierLength is the length of the derived unseal verifier
+	verifierLength = 43
+
+	// unsealVerifierContext prefixes the derivation context of unseal verifiers. It is reserved,
+	// so no DerivedSecret can publish the verifier of a MasterPassword
+	unsealVerifierContext = crypto.ReservedContextPrefix + "unseal-verifier:"
+)
+
+var log = logf.Log.WithName("unseal")
+
+// +kubebuilder:rbac:groups=authentication.k8s.io,resources=tokenreviews,verbs=create
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+// Handler serves POST /unseal/<name> with the master password of the named MasterPassword as
+// the request body. Callers authenticate with a bearer token and need the unseal verb on the
+// MasterPassword. The first unseal records a verifier in the MasterPassword status; later
+// unseals, for example after a restart or a leader change, must match it
+type Handler struct {
+	Client client.Client
+	Vault  *Vault
+	// Peers forwards unseals to the other replicas; nil unseals this replica only
+	Peers *Peers
+}
+
+// ServeHTTP unseals a master password into the Vault
+func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(req.URL.Path, Path)
+	if name == "" || strings.Contains(name, "/") {
+		http.Error(w, "the path must be "+Path+"<masterpassword>", http.StatusNotFound)
+		return
+	}
+
+	user, status, err := h.authenticate(ctx, req)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	allowed, err := h.authorize(ctx, user, name)
+	if err != nil {
+		log.Error(err, "Failed to authorize unseal", "masterPassword", name)
+		http.Error(w, "failed to authorize the request", http.StatusInternalServerError)
+		return
+	}
+	if !allowed {
+		http.Error(w, fmt.Sprintf("user %s may not unseal MasterPassword %s", user.Username, name),
+			http.StatusForbidden)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxPasswordSize+1))
+	if err != nil {
+		http.Error(w, "failed to read the master password", http.StatusBadRequest)
+		return
+	}
+	password := string(bytes.TrimRight(body, "\r\n"))
+	if password == "" || len(body) > maxPasswordSize {
+		http.Error(w, "the request body must hold the master password", http.StatusBadRequest)
+		return
+	}
+
+	mp, status, err := h.getMasterPassword(ctx, name)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	// A mistyped mnemonic or a weak master password is rejected before its verifier is recorded
+	if password, err = policy.CheckMasterPassword(&mp.Spec, password); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if status, err := h.verify(ctx, mp, password); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	h.Vault.Unseal(name, mp.UID, password)
+	log.Info("Unsealed master password", "masterPassword", name, "user", user.Username)
+
+	// Unseals posted by a client reach one replica only, so they are passed on to the others
+	if h.Peers != nil && req.Header.Get(ForwardedHeader) == "" {
+		token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if err := h.Peers.Forward(ctx, name, token, password); err != nil {
+			log.Error(err, "Failed to unseal every replica", "masterPassword", name)
+			http.Error(w, "unsealed this replica, but not every other one: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authenticate returns the user of the bearer token of the request
+func (h *Handler) authenticate(
+	ctx context.Context,
+	req *http.Request,
+) (authenticationv1.UserInfo, int, error) {
+	token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return authenticationv1.UserInfo{}, http.StatusUnauthorized, fmt.Errorf("a bearer token is required")
+	}
+
+	review := &authenticationv1.TokenReview{Spec: authenticationv1.TokenReviewSpec{Token: token}}
+	if err := h.Client.Create(ctx, review); err != nil {
+		log.Error(err, "Failed to create TokenReview")
+		return authenticationv1.UserInfo{}, http.StatusInternalServerError,
+			fmt.Errorf("failed to authenticate the request")
+	}
+	if !review.Status.Authenticated {
+		return authenticationv1.UserInfo{}, http.StatusUnauthorized, fmt.Errorf("invalid bearer token")
+	}
+	return review.Status.User, http.StatusOK, nil
+}
+
+// authorize reports whether the user may unseal the named MasterPassword
+func (h *Handler) authorize(ctx context.Context, user authenticationv1.UserInfo, name string) (bool, error) {
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for k, val := range user.Extra {
+		extra[k] = authorizationv1.ExtraValue(val)
+	}
+
+	sar := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   user.Username,
+			UID:    user.UID,
+			Groups: user.Groups,
+			Extra:  extra,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Group:    secretsv1beta1.GroupVersion.Group,
+				Resource: "masterpasswords",
+				Name:     name,
+				Verb:     unsealVerb,
+			},
+		},
+	}
+	if err := h.Client.Create(ctx, sar); err != nil {
+		return false, fmt.Errorf("failed to create SubjectAccessReview: %w", err)
+	}
+	return sar.Status.Allowed, nil
+}
+
+// getMasterPassword returns the named MasterPassword if it uses Memory storage, or the HTTP
+// status of a failed lookup
+func (h *Handler) getMasterPassword(ctx context.Context, name string) (*secretsv1beta1.MasterPassword, int, error) {
+	mp := &secretsv1beta1.MasterPassword{}
+	if err := h.Client.Get(ctx, types.NamespacedName{Name: name}, mp); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, http.StatusNotFound, fmt.Errorf("MasterPassword %s does not exist", name)
+		}
+		log.Error(err, "Failed to get MasterPassword", "masterPassword", name)
+		return nil, http.StatusInternalServerError, fmt.Errorf("failed to get MasterPassword %s", name)
+	}
+	if !mp.Spec.InMemory() {
+		return nil, http.StatusConflict, fmt.Errorf("MasterPassword %s does not use Memory storage", name)
+	}
+	return mp, http.StatusOK, nil
+}
+
+// verify checks the master password against the verifier of the MasterPassword, recording the
+// verifier on the first unseal. It returns the HTTP status of a failed check
+func (h *Handler) verify(ctx context.Context, mp *secretsv1beta1.MasterPassword, password string) (int, error) {
+	verifier, err := unsealVerifier(mp.Name, password)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if mp.Status.UnsealVerifier != "" {
+		if subtle.ConstantTimeCompare([]byte(verifier), []byte(mp.Status.UnsealVerifier)) != 1 {
+			return http.StatusForbidden, fmt.Errorf("the master password does not match MasterPassword %s", mp.Name)
+		}
+		return http.StatusOK, nil
+	}
+
+	// Concurrent first unseals conflict here, so only one master password is ever accepted
+	mp.Status.UnsealVerifier = verifier
+	if err := h.Client.Status().Update(ctx, mp); err != nil {
+		if apierrors.IsConflict(err) {
+			return http.StatusConflict, fmt.Errorf("MasterPassword %s changed, retry the unseal", mp.Name)
+		}
+		log.Error(err, "Failed to record unseal verifier", "masterPassword", mp.Name)
+		return http.StatusInternalServerError, fmt.Errorf("failed to record the unseal verifier")
+	}
+	return http.StatusOK, nil
+}
+
+// unsealVerifier derives the value recorded to verify later unseals of the named MasterPassword.
+// It is derived like a secret, so it reveals nothing about the master password
+func unsealVerifier(name, password string) (string, error) {
+	verifier, err := crypto.DeriveSecret(password, unsealVerifierContext+name, verifierLength)
+	if err != nil {
+		return "", fmt.Errorf("failed to derive unseal verifier: %w", err)
+	}
+	return verifier, nil
+}
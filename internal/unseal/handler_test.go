@@ -0,0 +1,244 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package unseal
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	secretsv1beta1 "github.com/oleksiyp/derived-secret-operator/api/v1beta1"
+)
+
+// newTestHandler returns a Handler whose API server knows the tokens of alice and bob, and
+// allows only alice to unseal the "memory" MasterPassword
+func newTestHandler(t *testing.T) (*Handler, client.Client) {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := secretsv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatalf("AddToScheme() error = %v", err)
+	}
+
+	users := map[string]string{"alice-token": "alice", "bob-token": "bob"}
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithStatusSubresource(&secretsv1beta1.MasterPassword{}).
+		WithObjects(
+			&secretsv1beta1.MasterPassword{
+				ObjectMeta: metav1.ObjectMeta{Name: "memory", UID: types.UID("memory-uid")},
+				Spec:       secretsv1beta1.MasterPasswordSpec{Storage: secretsv1beta1.MasterPasswordStorageMemory},
+			},
+			&secretsv1beta1.MasterPassword{
+				ObjectMeta: metav1.ObjectMeta{Name: "stored"},
+			},
+			&secretsv1beta1.MasterPassword{
+				ObjectMeta: metav1.ObjectMeta{Name: "validated"},
+				Spec: secretsv1beta1.MasterPasswordSpec{
+					Storage:    secretsv1beta1.MasterPasswordStorageMemory,
+					Validation: &secretsv1beta1.MasterPasswordValidation{MinLength: 16, MinEntropyBits: 64},
+				},
+			},
+			&secretsv1beta1.MasterPassword{
+				ObjectMeta: metav1.ObjectMeta{Name: "mnemonic", UID: types.UID("mnemonic-uid")},
+				Spec: secretsv1beta1.MasterPasswordSpec{
+					Storage: secretsv1beta1.MasterPasswordStorageMemory,
+					Format:  secretsv1beta1.MasterPasswordFormatMnemonic,
+				},
+			},
+		).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, o client.Object, opts ...client.CreateOption) error {
+				switch review := o.(type) {
+				case *authenticationv1.TokenReview:
+					user, ok := users[review.Spec.Token]
+					review.Status.Authenticated = ok
+					review.Status.User = authenticationv1.UserInfo{Username: user}
+					return nil
+				case *authorizationv1.SubjectAccessReview:
+					attrs := review.Spec.ResourceAttributes
+					review.Status.Allowed = review.Spec.User == "alice" && attrs.Verb == unsealVerb &&
+						attrs.Resource == "masterpasswords"
+					return nil
+				}
+				return c.Create(ctx, o, opts...)
+			},
+		}).Build()
+	return &Handler{Client: c, Vault: NewVault()}, c
+}
+
+// unsealRequest posts password to the unseal endpoint of the named MasterPassword with token
+func unsealRequest(h *Handler, name, token, password string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, Path+name, strings.NewReader(password))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestHandlerUnseals(t *testing.T) {
+	h, c := newTestHandler(t)
+
+	if w := unsealRequest(h, "memory", "alice-token", "memory-master-password\n"); w.Code != http.StatusNoContent {
+		t.Fatalf("unseal status = %d (%s), want %d", w.Code, w.Body, http.StatusNoContent)
+	}
+	password, ok := h.Vault.Get("memory", types.UID("memory-uid"))
+	if !ok || password != "memory-master-password" {
+		t.Errorf("Vault.Get() = %q, %v, want the unsealed master password", password, ok)
+	}
+	if _, ok := h.Vault.Get("memory", types.UID("recreated-uid")); ok {
+		t.Errorf("Vault.Get() served the master password to a recreated MasterPassword")
+	}
+
+	mp := &secretsv1beta1.MasterPassword{}
+	if err := c.Get(context.Background(), types.NamespacedName{Name: "memory"}, mp); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if mp.Status.UnsealVerifier == "" || strings.Contains(mp.Status.UnsealVerifier, "memory-master-password") {
+		t.Errorf("status.unsealVerifier = %q, want a verifier derived from the master password",
+			mp.Status.UnsealVerifier)
+	}
+
+	// Later unseals, as after a restart, must match the first one
+	h.Vault = NewVault()
+	if w := unsealRequest(h, "memory", "alice-token", "another-master-password"); w.Code != http.StatusForbidden {
+		t.Errorf("unseal with another password status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if _, ok := h.Vault.Get("memory", types.UID("memory-uid")); ok {
+		t.Errorf("Vault.Get() returned a master password that does not match the verifier")
+	}
+	if w := unsealRequest(h, "memory", "alice-token", "memory-master-password"); w.Code != http.StatusNoContent {
+		t.Errorf("unseal after restart status = %d (%s), want %d", w.Code, w.Body, http.StatusNoContent)
+	}
+}
+
+func TestHandlerForwardsToPeers(t *testing.T) {
+	peer, _ := newTestHandler(t)
+	// Forwarded unseals are not forwarded again, so the peer never looks up its own peers
+	peer.Peers = &Peers{LookupHost: func(context.Context, string) ([]string, error) {
+		return nil, fmt.Errorf("forwarded unseal was forwarded again")
+	}}
+	server := httptest.NewTLSServer(peer)
+	t.Cleanup(server.Close)
+	host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("SplitHostPort() error = %v", err)
+	}
+	portNumber, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatalf("Atoi() error = %v", err)
+	}
+
+	h, _ := newTestHandler(t)
+	h.Peers = &Peers{
+		Service: "operator-peers",
+		Port:    portNumber,
+		Self:    "10.0.0.1",
+		Client:  server.Client(),
+		LookupHost: func(_ context.Context, service string) ([]string, error) {
+			if service != "operator-peers" {
+				return nil, fmt.Errorf("unexpected service %s", service)
+			}
+			return []string{"10.0.0.1", host}, nil
+		},
+	}
+
+	if w := unsealRequest(h, "memory", "alice-token", "memory-master-password"); w.Code != http.StatusNoContent {
+		t.Fatalf("unseal status = %d (%s), want %d", w.Code, w.Body, http.StatusNoContent)
+	}
+	for replica, vault := range map[string]*Vault{"this": h.Vault, "peer": peer.Vault} {
+		if password, ok := vault.Get("memory", types.UID("memory-uid")); !ok || password != "memory-master-password" {
+			t.Errorf("Vault.Get() on the %s replica = %q, %v, want the unsealed master password", replica, password, ok)
+		}
+	}
+
+	// A replica that cannot be reached is reported
+	h.Peers.Client = http.DefaultClient
+	w := unsealRequest(h, "mnemonic", "alice-token", strings.Repeat("abandon ", 23)+"art")
+	if w.Code != http.StatusBadGateway || !strings.Contains(w.Body.String(), "replica "+host) {
+		t.Errorf("unseal with an unreachable peer status = %d (%s), want %d", w.Code, w.Body, http.StatusBadGateway)
+	}
+}
+
+func TestHandlerValidatesMnemonics(t *testing.T) {
+	h, _ := newTestHandler(t)
+	mnemonic := strings.Repeat("abandon ", 23) + "art"
+
+	typo := strings.Repeat("abandon ", 23) + "arm"
+	if w := unsealRequest(h, "mnemonic", "alice-token", typo); w.Code != http.StatusBadRequest {
+		t.Errorf("unseal with a mistyped mnemonic status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if w := unsealRequest(h, "mnemonic", "alice-token", strings.ToUpper(mnemonic)+"\n"); w.Code != http.StatusNoContent {
+		t.Fatalf("unseal status = %d (%s), want %d", w.Code, w.Body, http.StatusNoContent)
+	}
+	if password, ok := h.Vault.Get("mnemonic", types.UID("mnemonic-uid")); !ok || password != mnemonic {
+		t.Errorf("Vault.Get() = %q, %v, want the normalized mnemonic", password, ok)
+	}
+}
+
+func TestHandlerRejects(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	tests := []struct {
+		name     string
+		mpName   string
+		token    string
+		password string
+		want     int
+	}{
+		{name: "missing token", mpName: "memory", password: "pw", want: http.StatusUnauthorized},
+		{name: "invalid token", mpName: "memory", token: "stolen", password: "pw", want: http.StatusUnauthorized},
+		{name: "without the unseal verb", mpName: "memory", token: "bob-token", password: "pw",
+			want: http.StatusForbidden},
+		{name: "empty password", mpName: "memory", token: "alice-token", want: http.StatusBadRequest},
+		{name: "secret storage", mpName: "stored", token: "alice-token", password: "pw", want: http.StatusConflict},
+		{name: "weak master password", mpName: "validated", token: "alice-token", password: "password",
+			want: http.StatusBadRequest},
+		{name: "unknown MasterPassword", mpName: "missing", token: "alice-token", password: "pw",
+			want: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := unsealRequest(h, tt.mpName, tt.token, tt.password); w.Code != tt.want {
+				t.Errorf("status = %d (%s), want %d", w.Code, w.Body, tt.want)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, Path+"memory", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET status = %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+	if _, ok := h.Vault.Get("memory", types.UID("memory-uid")); ok {
+		t.Errorf("Vault.Get() returned a master password after rejected unseals")
+	}
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package unseal
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// ForwardedHeader marks an unseal forwarded by another replica, which is not forwarded again
+	ForwardedHeader = "X-Unseal-Forwarded"
+
+	// peerTimeout bounds a forwarded unseal
+	peerTimeout = 10 * time.Second
+)
+
+// Peers forwards unseals to the other replicas of the operator, so every replica holds the
+// master password whichever one the unseal was posted to
+type Peers struct {
+	// Service is the DNS name of a headless Service resolving to the address of every replica
+	Service string
+	// Port is the port of the webhook server of the replicas
+	Port int
+	// Self is the address of this replica, which is not forwarded to
+	Self string
+	// Client sends the forwarded unseals
+	Client *http.Client
+	// LookupHost resolves Service; net.DefaultResolver.LookupHost if nil
+	LookupHost func(ctx context.Context, host string) ([]string, error)
+}
+
+// NewPeerClient returns a client for the webhook servers of the replicas. Their serving
+// certificate is verified with the CA bundle in caFile and must be valid for serverName, as the
+// replicas are reached by address
+func NewPeerClient(caFile, serverName string) (*http.Client, error) {
+	bundle, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the CA bundle of the replicas: %w", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(bundle) {
+		return nil, fmt.Errorf("the CA bundle %s holds no PEM certificates", caFile)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: roots, ServerName: serverName, MinVersion: tls.VersionTLS12}
+	return &http.Client{Transport: transport, Timeout: peerTimeout}, nil
+}
+
+// Forward posts the unseal of the named MasterPassword to every other replica, authenticated
+// with the bearer token of the original request. Each replica checks the token, the unseal verb
+// and the verifier itself. It returns the errors of the replicas that were not unsealed
+func (p *Peers) Forward(ctx context.Context, name, token, password string) error {
+	lookup := p.LookupHost
+	if lookup == nil {
+		lookup = net.DefaultResolver.LookupHost
+	}
+	addresses, err := lookup(ctx, p.Service)
+	if err != nil {
+		return fmt.Errorf("failed to look up the replicas: %w", err)
+	}
+
+	var errs []error
+	for _, address := range addresses {
+		if address == p.Self {
+			continue
+		}
+		if err := p.forward(ctx, address, name, token, password); err != nil {
+			errs = append(errs, fmt.Errorf("replica %s: %w", address, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// forward posts the unseal to the replica at address
+func (p *Peers) forward(ctx context.Context, address, name, token, password string) error {
+	endpoint := url.URL{
+		Scheme: "https",
+		Host:   net.JoinHostPort(address, strconv.Itoa(p.Port)),
+		Path:   Path + name,
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.String(), strings.NewReader(password))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set(ForwardedHeader, "true")
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode != http.StatusNoContent {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxPasswordSize))
+		return fmt.Errorf("answered %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
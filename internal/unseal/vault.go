@@ -0,0 +1,92 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package unseal keeps master passwords that are never stored in Kubernetes in process memory,
+// and serves the endpoint they are unsealed through.
+package unseal
+
+import (
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	secretsv1beta1 "github.com/oleksiyp/derived-secret-operator/api/v1beta1"
+)
+
+// eventBufferSize bounds the unseal notifications waiting for the MasterPassword controller
+const eventBufferSize = 128
+
+// Vault holds the master passwords of MasterPasswords with Memory storage. It starts sealed:
+// every master password is unsealed again after the operator restarts. A nil Vault is sealed
+type Vault struct {
+	mu        sync.RWMutex
+	passwords map[string]unsealed
+	events    chan event.GenericEvent
+}
+
+// unsealed is a master password unsealed for the MasterPassword with the given UID, so it is
+// not served to a MasterPassword recreated under the same name
+type unsealed struct {
+	uid      types.UID
+	password string
+}
+
+// NewVault returns a sealed Vault
+func NewVault() *Vault {
+	return &Vault{
+		passwords: make(map[string]unsealed),
+		events:    make(chan event.GenericEvent, eventBufferSize),
+	}
+}
+
+// Get returns the unsealed master password of the MasterPassword with the given name and UID
+func (v *Vault) Get(name string, uid types.UID) (string, bool) {
+	if v == nil {
+		return "", false
+	}
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	entry, ok := v.passwords[name]
+	if !ok || entry.uid != uid {
+		return "", false
+	}
+	return entry.password, true
+}
+
+// Unseal keeps the master password of the MasterPassword with the given name and UID in memory
+// and notifies the MasterPassword controller
+func (v *Vault) Unseal(name string, uid types.UID, password string) {
+	v.mu.Lock()
+	v.passwords[name] = unsealed{uid: uid, password: password}
+	v.mu.Unlock()
+
+	// Only the leader runs the controller; other replicas keep the password for a leader change
+	select {
+	case v.events <- event.GenericEvent{Object: &secretsv1beta1.MasterPassword{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+	}}:
+	default:
+	}
+}
+
+// Source returns a source of reconcile requests for the MasterPasswords unsealed in the Vault
+func (v *Vault) Source() source.Source {
+	return source.Channel(v.events, &handler.EnqueueRequestForObject{})
+}
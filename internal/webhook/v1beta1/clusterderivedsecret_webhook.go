@@ -0,0 +1,220 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	secretsv1beta1 "github.com/oleksiyp/derived-secret-operator/api/v1beta1"
+	"github.com/oleksiyp/derived-secret-operator/internal/crypto"
+)
+
+// clusterderivedsecretlog is for logging in this package.
+var clusterderivedsecretlog = logf.Log.WithName("clusterderivedsecret-resource")
+
+// clusterDerivedSecretsResource is the resource reported in admission errors
+var clusterDerivedSecretsResource = secretsv1beta1.GroupVersion.WithResource("clusterderivedsecrets").GroupResource()
+
+// SetupClusterDerivedSecretWebhookWithManager registers the webhook for ClusterDerivedSecret in the manager.
+func SetupClusterDerivedSecretWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&secretsv1beta1.ClusterDerivedSecret{}).
+		WithValidator(&ClusterDerivedSecretCustomValidator{Client: mgr.GetClient()}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-secrets-oleksiyp-dev-v1beta1-clusterderivedsecret,mutating=false,failurePolicy=fail,sideEffects=None,groups=secrets.oleksiyp.dev,resources=clusterderivedsecrets,verbs=create;update,versions=v1beta1,name=vclusterderivedsecret-v1beta1.kb.io,admissionReviewVersions=v1
+
+// ClusterDerivedSecretCustomValidator validates ClusterDerivedSecrets when they are created or updated.
+// Since they write secrets into many namespaces, the requesting user must be allowed to "use"
+// every referenced MasterPassword, and NamespaceMasterPasswords in all namespaces.
+type ClusterDerivedSecretCustomValidator struct {
+	Client client.Client
+}
+
+var _ webhook.CustomValidator = &ClusterDerivedSecretCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type ClusterDerivedSecret.
+func (v *ClusterDerivedSecretCustomValidator) ValidateCreate(
+	ctx context.Context,
+	obj runtime.Object,
+) (admission.Warnings, error) {
+	cds, ok := obj.(*secretsv1beta1.ClusterDerivedSecret)
+	if !ok {
+		return nil, fmt.Errorf("expected a ClusterDerivedSecret object but got %T", obj)
+	}
+	clusterderivedsecretlog.Info("Validation for ClusterDerivedSecret upon creation", "name", cds.GetName())
+
+	if err := validateNamespaceSelector(cds); err != nil {
+		return nil, err
+	}
+	if err := validateNoStagedRotation(cds); err != nil {
+		return nil, err
+	}
+	if err := v.validateContexts(ctx, cds); err != nil {
+		return nil, err
+	}
+	return nil, v.validateUsePermission(ctx, cds)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type ClusterDerivedSecret.
+func (v *ClusterDerivedSecretCustomValidator) ValidateUpdate(
+	ctx context.Context,
+	oldObj, newObj runtime.Object,
+) (admission.Warnings, error) {
+	cds, ok := newObj.(*secretsv1beta1.ClusterDerivedSecret)
+	if !ok {
+		return nil, fmt.Errorf("expected a ClusterDerivedSecret object for the newObj but got %T", newObj)
+	}
+	old, ok := oldObj.(*secretsv1beta1.ClusterDerivedSecret)
+	if !ok {
+		return nil, fmt.Errorf("expected a ClusterDerivedSecret object for the oldObj but got %T", oldObj)
+	}
+	clusterderivedsecretlog.Info("Validation for ClusterDerivedSecret upon update", "name", cds.GetName())
+
+	if err := validateNamespaceSelector(cds); err != nil {
+		return nil, err
+	}
+	if err := validateNoStagedRotation(cds); err != nil {
+		return nil, err
+	}
+	if err := v.validateContexts(ctx, cds); err != nil {
+		return nil, err
+	}
+
+	// Metadata-only updates need no "use" permission
+	if apiequality.Semantic.DeepEqual(old.Spec, cds.Spec) {
+		return nil, nil
+	}
+	return nil, v.validateUsePermission(ctx, cds)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type ClusterDerivedSecret.
+func (v *ClusterDerivedSecretCustomValidator) ValidateDelete(
+	ctx context.Context,
+	obj runtime.Object,
+) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validateNoStagedRotation rejects the staged rotation annotation. A ClusterDerivedSecret has no
+// per-namespace spec to promote the rotated key versions into
+func validateNoStagedRotation(cds *secretsv1beta1.ClusterDerivedSecret) error {
+	if _, ok := cds.Annotations[secretsv1beta1.RotationAnnotation]; !ok {
+		return nil
+	}
+	return apierrors.NewInvalid(secretsv1beta1.GroupVersion.WithKind("ClusterDerivedSecret").GroupKind(),
+		cds.Name, field.ErrorList{
+			field.Forbidden(field.NewPath("metadata", "annotations").Key(secretsv1beta1.RotationAnnotation),
+				"staged rotations are not supported for ClusterDerivedSecrets; bump the key versions instead"),
+		})
+}
+
+// validateNamespaceSelector checks that the namespaceSelector can be parsed
+func validateNamespaceSelector(cds *secretsv1beta1.ClusterDerivedSecret) error {
+	if _, err := metav1.LabelSelectorAsSelector(&cds.Spec.NamespaceSelector); err != nil {
+		return apierrors.NewInvalid(secretsv1beta1.GroupVersion.WithKind("ClusterDerivedSecret").GroupKind(),
+			cds.Name, field.ErrorList{
+				field.Invalid(field.NewPath("spec", "namespaceSelector"), cds.Spec.NamespaceSelector, err.Error()),
+			})
+	}
+	return nil
+}
+
+// validateContexts checks that the template only overrides derivation contexts, which derives
+// the same value in every selected namespace, if the MasterPassword allows it
+func (v *ClusterDerivedSecretCustomValidator) validateContexts(
+	ctx context.Context,
+	cds *secretsv1beta1.ClusterDerivedSecret,
+) error {
+	template := &cds.Spec.Template
+	names := make([]string, 0, len(template.Keys))
+	for name := range template.Keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		keySpec := template.Keys[name]
+		if keySpec.ContextOverride == "" {
+			continue
+		}
+		if strings.HasPrefix(keySpec.ContextOverride, crypto.ReservedContextPrefix) {
+			return apierrors.NewForbidden(clusterDerivedSecretsResource, cds.Name, reservedContextError(name))
+		}
+		allowed, err := crossNamespaceContextsAllowed(ctx, v.Client, template.MasterPasswordRefFor(keySpec))
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return apierrors.NewForbidden(clusterDerivedSecretsResource, cds.Name,
+				fmt.Errorf("key %s: context override derives the same value in every namespace and its "+
+					"MasterPassword does not allow cross-namespace contexts", name))
+		}
+	}
+	return nil
+}
+
+// validateUsePermission checks with a SubjectAccessReview that the requesting user may
+// "use" every MasterPassword referenced by the template, and every NamespaceMasterPassword
+// of that name in all namespaces.
+func (v *ClusterDerivedSecretCustomValidator) validateUsePermission(
+	ctx context.Context,
+	cds *secretsv1beta1.ClusterDerivedSecret,
+) error {
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	user := req.UserInfo
+
+	for _, ref := range referencedMasterPasswords(&cds.Spec.Template) {
+		attrs := &authorizationv1.ResourceAttributes{
+			Verb:     useVerb,
+			Group:    secretsv1beta1.GroupVersion.Group,
+			Resource: "masterpasswords",
+			Name:     ref.Name,
+		}
+		if ref.Kind == secretsv1beta1.KindNamespaceMasterPassword {
+			attrs.Resource = "namespacemasterpasswords"
+		}
+
+		allowed, err := canUse(ctx, v.Client, user, attrs)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return apierrors.NewForbidden(clusterDerivedSecretsResource, cds.Name,
+				fmt.Errorf("user %s cannot %s %s %s", user.Username, useVerb, ref.Kind, ref.Name))
+		}
+	}
+	return nil
+}
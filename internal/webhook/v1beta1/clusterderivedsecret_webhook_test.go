@@ -0,0 +1,146 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	secretsv1beta1 "github.com/oleksiyp/derived-secret-operator/api/v1beta1"
+)
+
+var _ = Describe("ClusterDerivedSecret Webhook", func() {
+	const user = "alice"
+
+	var (
+		validator ClusterDerivedSecretCustomValidator
+		obj       *secretsv1beta1.ClusterDerivedSecret
+		// usable holds the <resource>/<namespace>/<name> master passwords the user may "use"
+		usable map[string]bool
+		ctx    context.Context
+	)
+
+	BeforeEach(func() {
+		usable = map[string]bool{}
+		validator.Client = fake.NewClientBuilder().WithScheme(scheme).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Create: func(ctx context.Context, c client.WithWatch, o client.Object, opts ...client.CreateOption) error {
+					sar, ok := o.(*authorizationv1.SubjectAccessReview)
+					if !ok {
+						return c.Create(ctx, o, opts...)
+					}
+					attrs := sar.Spec.ResourceAttributes
+					sar.Status.Allowed = sar.Spec.User == user && attrs.Verb == "use" &&
+						usable[attrs.Resource+"/"+attrs.Namespace+"/"+attrs.Name]
+					return nil
+				},
+			}).Build()
+		ctx = admission.NewContextWithRequest(context.Background(), admission.Request{
+			AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: admissionv1.Create,
+				UserInfo:  authenticationv1.UserInfo{Username: user},
+			},
+		})
+
+		obj = &secretsv1beta1.ClusterDerivedSecret{
+			ObjectMeta: metav1.ObjectMeta{Name: "metrics-token"},
+			Spec: secretsv1beta1.ClusterDerivedSecretSpec{
+				NamespaceSelector: metav1.LabelSelector{MatchLabels: map[string]string{"metrics": "enabled"}},
+				Template: secretsv1beta1.DerivedSecretSpec{
+					MasterPassword: "default",
+					Keys: map[string]secretsv1beta1.DerivedKeySpec{
+						"token": {Type: secretsv1beta1.SecretTypePassword},
+					},
+				},
+			},
+		}
+	})
+
+	Context("When creating a ClusterDerivedSecret", func() {
+		It("Should deny creation without use permission on the MasterPassword", func() {
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsForbidden(err)).To(BeTrue())
+		})
+
+		It("Should admit creation with use permission on the MasterPassword", func() {
+			usable["masterpasswords//default"] = true
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should require use permission on NamespaceMasterPasswords in all namespaces", func() {
+			obj.Spec.Template.MasterPasswordKind = secretsv1beta1.KindNamespaceMasterPassword
+			usable["namespacemasterpasswords/team-a/default"] = true
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsForbidden(err)).To(BeTrue())
+
+			usable["namespacemasterpasswords//default"] = true
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny a context override unless the MasterPassword allows it", func() {
+			usable["masterpasswords//default"] = true
+			obj.Spec.Template.Keys["token"] = secretsv1beta1.DerivedKeySpec{
+				Type:            secretsv1beta1.SecretTypePassword,
+				ContextOverride: "shared/token",
+			}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsForbidden(err)).To(BeTrue())
+
+			Expect(validator.Client.Create(ctx, &secretsv1beta1.MasterPassword{
+				ObjectMeta: metav1.ObjectMeta{Name: "default"},
+				Spec:       secretsv1beta1.MasterPasswordSpec{AllowCrossNamespaceContexts: true},
+			})).To(Succeed())
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny a staged rotation", func() {
+			usable["masterpasswords//default"] = true
+			obj.Annotations = map[string]string{secretsv1beta1.RotationAnnotation: "stage"}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		})
+
+		It("Should deny an invalid namespaceSelector", func() {
+			usable["masterpasswords//default"] = true
+			obj.Spec.NamespaceSelector = metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "metrics", Operator: "Bogus"},
+			}}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		})
+	})
+
+	Context("When updating a ClusterDerivedSecret", func() {
+		It("Should not check permissions for metadata-only updates", func() {
+			updated := obj.DeepCopy()
+			updated.Labels = map[string]string{"team": "platform"}
+			Expect(validator.ValidateUpdate(ctx, obj, updated)).Error().NotTo(HaveOccurred())
+		})
+	})
+})
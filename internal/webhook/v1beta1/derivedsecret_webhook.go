@@ -0,0 +1,399 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	secretsv1beta1 "github.com/oleksiyp/derived-secret-operator/api/v1beta1"
+	"github.com/oleksiyp/derived-secret-operator/internal/crypto"
+)
+
+// useVerb is the RBAC verb required on masterpasswords/<name> to derive secrets from it
+const useVerb = "use"
+
+// derivedsecretlog is for logging in this package.
+var derivedsecretlog = logf.Log.WithName("derivedsecret-resource")
+
+// derivedSecretsResource is the resource reported in admission errors
+var derivedSecretsResource = secretsv1beta1.GroupVersion.WithResource("derivedsecrets").GroupResource()
+
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+// SetupDerivedSecretWebhookWithManager registers the webhook for DerivedSecret in the manager.
+// v1beta1 is the conversion hub, so this also serves conversion from v1alpha1.
+func SetupDerivedSecretWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&secretsv1beta1.DerivedSecret{}).
+		WithDefaulter(&DerivedSecretCustomDefaulter{}).
+		WithValidator(&DerivedSecretCustomValidator{Client: mgr.GetClient()}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-secrets-oleksiyp-dev-v1beta1-derivedsecret,mutating=true,failurePolicy=fail,sideEffects=None,groups=secrets.oleksiyp.dev,resources=derivedsecrets,verbs=create;update,versions=v1beta1,name=mderivedsecret-v1beta1.kb.io,admissionReviewVersions=v1
+
+// DerivedSecretCustomDefaulter records the requesting user in the AuthorizedUserAnnotation
+// when a DerivedSecret is created or its spec changes.
+type DerivedSecretCustomDefaulter struct{}
+
+var _ webhook.CustomDefaulter = &DerivedSecretCustomDefaulter{}
+
+// Default implements webhook.CustomDefaulter so a webhook will be registered for the type DerivedSecret.
+func (d *DerivedSecretCustomDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	derivedsecret, ok := obj.(*secretsv1beta1.DerivedSecret)
+	if !ok {
+		return fmt.Errorf("expected a DerivedSecret object but got %T", obj)
+	}
+
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	user := req.UserInfo.Username
+	if req.Operation == admissionv1.Update {
+		old, err := decodeOldDerivedSecret(req)
+		if err != nil {
+			return err
+		}
+		// Metadata-only updates keep the user who authorized the current spec
+		if sameAuthorizedSpec(&old.Spec, &derivedsecret.Spec) {
+			user = old.Annotations[secretsv1beta1.AuthorizedUserAnnotation]
+		}
+	}
+
+	annotations := derivedsecret.GetAnnotations()
+	if user == "" {
+		delete(annotations, secretsv1beta1.AuthorizedUserAnnotation)
+	} else {
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[secretsv1beta1.AuthorizedUserAnnotation] = user
+	}
+	derivedsecret.SetAnnotations(annotations)
+	return nil
+}
+
+// +kubebuilder:webhook:path=/validate-secrets-oleksiyp-dev-v1beta1-derivedsecret,mutating=false,failurePolicy=fail,sideEffects=None,groups=secrets.oleksiyp.dev,resources=derivedsecrets,verbs=create;update,versions=v1beta1,name=vderivedsecret-v1beta1.kb.io,admissionReviewVersions=v1
+
+// DerivedSecretCustomValidator validates DerivedSecrets when they are created or updated.
+// It rejects references to MasterPasswords that do not allow the DerivedSecret's namespace
+// or that the requesting user may not "use".
+type DerivedSecretCustomValidator struct {
+	Client client.Client
+}
+
+var _ webhook.CustomValidator = &DerivedSecretCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type DerivedSecret.
+func (v *DerivedSecretCustomValidator) ValidateCreate(
+	ctx context.Context,
+	obj runtime.Object,
+) (admission.Warnings, error) {
+	derivedsecret, ok := obj.(*secretsv1beta1.DerivedSecret)
+	if !ok {
+		return nil, fmt.Errorf("expected a DerivedSecret object but got %T", obj)
+	}
+	derivedsecretlog.Info("Validation for DerivedSecret upon creation", "name", derivedsecret.GetName())
+
+	if err := v.validateMasterPasswords(ctx, derivedsecret); err != nil {
+		return nil, err
+	}
+	if err := v.validateContexts(ctx, derivedsecret); err != nil {
+		return nil, err
+	}
+	return nil, v.validateUsePermission(ctx, derivedsecret)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type DerivedSecret.
+func (v *DerivedSecretCustomValidator) ValidateUpdate(
+	ctx context.Context,
+	oldObj, newObj runtime.Object,
+) (admission.Warnings, error) {
+	derivedsecret, ok := newObj.(*secretsv1beta1.DerivedSecret)
+	if !ok {
+		return nil, fmt.Errorf("expected a DerivedSecret object for the newObj but got %T", newObj)
+	}
+	old, ok := oldObj.(*secretsv1beta1.DerivedSecret)
+	if !ok {
+		return nil, fmt.Errorf("expected a DerivedSecret object for the oldObj but got %T", oldObj)
+	}
+	derivedsecretlog.Info("Validation for DerivedSecret upon update", "name", derivedsecret.GetName())
+
+	if err := v.validateMasterPasswords(ctx, derivedsecret); err != nil {
+		return nil, err
+	}
+	if err := v.validateContexts(ctx, derivedsecret); err != nil {
+		return nil, err
+	}
+
+	// Metadata-only updates, such as labels added by other controllers, need no "use" permission
+	if sameAuthorizedSpec(&old.Spec, &derivedsecret.Spec) {
+		if old.Annotations[secretsv1beta1.AuthorizedUserAnnotation] !=
+			derivedsecret.Annotations[secretsv1beta1.AuthorizedUserAnnotation] {
+			return nil, apierrors.NewForbidden(derivedSecretsResource, derivedsecret.Name,
+				fmt.Errorf("annotation %s cannot be changed", secretsv1beta1.AuthorizedUserAnnotation))
+		}
+		return nil, nil
+	}
+	return nil, v.validateUsePermission(ctx, derivedsecret)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type DerivedSecret.
+func (v *DerivedSecretCustomValidator) ValidateDelete(
+	ctx context.Context,
+	obj runtime.Object,
+) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validateMasterPasswords checks that every MasterPassword referenced by ds allows its namespace.
+// MasterPasswords that do not exist yet are left to the controller.
+func (v *DerivedSecretCustomValidator) validateMasterPasswords(
+	ctx context.Context,
+	ds *secretsv1beta1.DerivedSecret,
+) error {
+	var namespace *corev1.Namespace
+	for _, ref := range referencedMasterPasswords(&ds.Spec) {
+		// NamespaceMasterPasswords are always resolved in the DerivedSecret's namespace
+		if ref.Kind != secretsv1beta1.KindMasterPassword {
+			continue
+		}
+		name := ref.Name
+		mp := &secretsv1beta1.MasterPassword{}
+		if err := v.Client.Get(ctx, types.NamespacedName{Name: name}, mp); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return fmt.Errorf("failed to get MasterPassword %s: %w", name, err)
+		}
+		if mp.Spec.AllowedNamespaces == nil {
+			continue
+		}
+
+		if namespace == nil {
+			namespace = &corev1.Namespace{}
+			if err := v.Client.Get(ctx, types.NamespacedName{Name: ds.Namespace}, namespace); err != nil {
+				return fmt.Errorf("failed to get namespace %s: %w", ds.Namespace, err)
+			}
+		}
+		allowed, err := mp.Spec.AllowedNamespaces.Allows(namespace)
+		if err != nil {
+			return fmt.Errorf("MasterPassword %s: %w", name, err)
+		}
+		if !allowed {
+			return apierrors.NewForbidden(derivedSecretsResource, ds.Name,
+				fmt.Errorf("namespace %s is not allowed to use MasterPassword %s", ds.Namespace, name))
+		}
+	}
+	return nil
+}
+
+// validateContexts checks that keys only override their derivation context with a context of
+// another namespace if their MasterPassword allows it, so that a namespace cannot claim the
+// values derived for another one
+func (v *DerivedSecretCustomValidator) validateContexts(
+	ctx context.Context,
+	ds *secretsv1beta1.DerivedSecret,
+) error {
+	names := make([]string, 0, len(ds.Spec.Keys))
+	for name := range ds.Spec.Keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		keySpec := ds.Spec.Keys[name]
+		if strings.HasPrefix(keySpec.ContextOverride, crypto.ReservedContextPrefix) {
+			return apierrors.NewForbidden(derivedSecretsResource, ds.Name, reservedContextError(name))
+		}
+		// Contexts of ClusterDerivedSecrets in the namespace belong to the cluster, not the namespace
+		clusterContext := strings.HasPrefix(keySpec.ContextOverride,
+			ds.Namespace+"/"+secretsv1beta1.ClusterDerivationPrefix)
+		if keySpec.ContextOverride == "" ||
+			(strings.HasPrefix(keySpec.ContextOverride, ds.Namespace+"/") && !clusterContext) {
+			continue
+		}
+		allowed, err := crossNamespaceContextsAllowed(ctx, v.Client, ds.Spec.MasterPasswordRefFor(keySpec))
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return apierrors.NewForbidden(derivedSecretsResource, ds.Name,
+				fmt.Errorf("key %s: context %q is outside namespace %s or belongs to a ClusterDerivedSecret, and "+
+					"its MasterPassword does not allow cross-namespace contexts", name, keySpec.ContextOverride,
+					ds.Namespace))
+		}
+	}
+	return nil
+}
+
+// reservedContextError is returned for a context override the operator derives for itself, such
+// as the key of the fingerprints in the status.
+func reservedContextError(key string) error {
+	return fmt.Errorf("key %s: contexts starting with %q are reserved", key, crypto.ReservedContextPrefix)
+}
+
+// crossNamespaceContextsAllowed reports whether the referenced master password may derive the
+// same context in several namespaces. A NamespaceMasterPassword is only used by its own
+// namespace, so its contexts never collide with another namespace's values
+func crossNamespaceContextsAllowed(
+	ctx context.Context,
+	c client.Client,
+	ref secretsv1beta1.MasterPasswordReference,
+) (bool, error) {
+	if ref.Kind == secretsv1beta1.KindNamespaceMasterPassword {
+		return true, nil
+	}
+	mp := &secretsv1beta1.MasterPassword{}
+	if err := c.Get(ctx, types.NamespacedName{Name: ref.Name}, mp); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get MasterPassword %s: %w", ref.Name, err)
+	}
+	return mp.Spec.AllowCrossNamespaceContexts, nil
+}
+
+// validateUsePermission checks with a SubjectAccessReview that the requesting user may
+// "use" every MasterPassword and NamespaceMasterPassword referenced by ds, and that the
+// defaulter recorded that user.
+func (v *DerivedSecretCustomValidator) validateUsePermission(
+	ctx context.Context,
+	ds *secretsv1beta1.DerivedSecret,
+) error {
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	user := req.UserInfo
+
+	if ds.Annotations[secretsv1beta1.AuthorizedUserAnnotation] != user.Username {
+		return apierrors.NewForbidden(derivedSecretsResource, ds.Name,
+			fmt.Errorf("annotation %s must be %q", secretsv1beta1.AuthorizedUserAnnotation, user.Username))
+	}
+
+	for _, ref := range referencedMasterPasswords(&ds.Spec) {
+		attrs := &authorizationv1.ResourceAttributes{
+			Verb:     useVerb,
+			Group:    secretsv1beta1.GroupVersion.Group,
+			Resource: "masterpasswords",
+			Name:     ref.Name,
+		}
+		if ref.Kind == secretsv1beta1.KindNamespaceMasterPassword {
+			attrs.Resource = "namespacemasterpasswords"
+			attrs.Namespace = ds.Namespace
+		}
+
+		allowed, err := canUse(ctx, v.Client, user, attrs)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return apierrors.NewForbidden(derivedSecretsResource, ds.Name,
+				fmt.Errorf("user %s cannot %s %s %s", user.Username, useVerb, ref.Kind, ref.Name))
+		}
+	}
+	return nil
+}
+
+// canUse checks with a SubjectAccessReview whether user is allowed the given resource attributes
+func canUse(
+	ctx context.Context,
+	c client.Client,
+	user authenticationv1.UserInfo,
+	attrs *authorizationv1.ResourceAttributes,
+) (bool, error) {
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for k, val := range user.Extra {
+		extra[k] = authorizationv1.ExtraValue(val)
+	}
+
+	sar := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:               user.Username,
+			UID:                user.UID,
+			Groups:             user.Groups,
+			Extra:              extra,
+			ResourceAttributes: attrs,
+		},
+	}
+	if err := c.Create(ctx, sar); err != nil {
+		return false, fmt.Errorf("failed to create SubjectAccessReview: %w", err)
+	}
+	return sar.Status.Allowed, nil
+}
+
+// sameAuthorizedSpec reports whether spec differs from old at most in key versions. Version
+// bumps, such as a promoted staged rotation, keep the authorized derivation contexts and only
+// change their version suffix, so they need no new "use" permission
+func sameAuthorizedSpec(old, spec *secretsv1beta1.DerivedSecretSpec) bool {
+	unversioned := spec.DeepCopy()
+	for name, key := range unversioned.Keys {
+		if oldKey, ok := old.Keys[name]; ok {
+			key.Version = oldKey.Version
+			unversioned.Keys[name] = key
+		}
+	}
+	return apiequality.Semantic.DeepEqual(*old, *unversioned)
+}
+
+// referencedMasterPasswords returns the master passwords used by spec, sorted by kind and name
+func referencedMasterPasswords(spec *secretsv1beta1.DerivedSecretSpec) []secretsv1beta1.MasterPasswordReference {
+	refs := make(map[secretsv1beta1.MasterPasswordReference]struct{})
+	for _, keySpec := range spec.Keys {
+		refs[spec.MasterPasswordRefFor(keySpec)] = struct{}{}
+	}
+	sorted := make([]secretsv1beta1.MasterPasswordReference, 0, len(refs))
+	for ref := range refs {
+		sorted = append(sorted, ref)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Kind != sorted[j].Kind {
+			return sorted[i].Kind < sorted[j].Kind
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+// decodeOldDerivedSecret decodes the object being replaced by an update request
+func decodeOldDerivedSecret(req admission.Request) (*secretsv1beta1.DerivedSecret, error) {
+	old := &secretsv1beta1.DerivedSecret{}
+	if err := json.Unmarshal(req.OldObject.Raw, old); err != nil {
+		return nil, fmt.Errorf("failed to decode old DerivedSecret: %w", err)
+	}
+	return old, nil
+}
@@ -0,0 +1,318 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	secretsv1beta1 "github.com/oleksiyp/derived-secret-operator/api/v1beta1"
+)
+
+var _ = Describe("DerivedSecret Webhook", func() {
+	const user = "alice"
+
+	var (
+		validator DerivedSecretCustomValidator
+		defaulter DerivedSecretCustomDefaulter
+		obj       *secretsv1beta1.DerivedSecret
+		// usable holds the <resource>/<namespace>/<name> master passwords the user may "use"
+		usable map[string]bool
+	)
+
+	withObjects := func(objs ...client.Object) {
+		validator.Client = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Create: func(ctx context.Context, c client.WithWatch, o client.Object, opts ...client.CreateOption) error {
+					sar, ok := o.(*authorizationv1.SubjectAccessReview)
+					if !ok {
+						return c.Create(ctx, o, opts...)
+					}
+					attrs := sar.Spec.ResourceAttributes
+					sar.Status.Allowed = sar.Spec.User == user && attrs.Verb == "use" &&
+						usable[attrs.Resource+"/"+attrs.Namespace+"/"+attrs.Name]
+					return nil
+				},
+			}).Build()
+	}
+
+	requestContext := func(op admissionv1.Operation, old runtime.Object) context.Context {
+		req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: op,
+			UserInfo:  authenticationv1.UserInfo{Username: user},
+		}}
+		if old != nil {
+			raw, err := json.Marshal(old)
+			Expect(err).NotTo(HaveOccurred())
+			req.OldObject = runtime.RawExtension{Raw: raw}
+		}
+		return admission.NewContextWithRequest(context.Background(), req)
+	}
+
+	namespace := func(name string, labels map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+
+	masterPassword := func(name string, allowed *secretsv1beta1.AllowedNamespaces) *secretsv1beta1.MasterPassword {
+		return &secretsv1beta1.MasterPassword{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       secretsv1beta1.MasterPasswordSpec{Length: 86, AllowedNamespaces: allowed},
+		}
+	}
+
+	BeforeEach(func() {
+		validator = DerivedSecretCustomValidator{}
+		defaulter = DerivedSecretCustomDefaulter{}
+		usable = map[string]bool{"masterpasswords//default": true}
+		obj = &secretsv1beta1.DerivedSecret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "app",
+				Namespace:   "team-a",
+				Annotations: map[string]string{secretsv1beta1.AuthorizedUserAnnotation: user},
+			},
+			Spec: secretsv1beta1.DerivedSecretSpec{
+				MasterPassword: "default",
+				Keys: map[string]secretsv1beta1.DerivedKeySpec{
+					"password": {Type: secretsv1beta1.SecretTypePassword},
+				},
+			},
+		}
+	})
+
+	Context("When creating or updating DerivedSecret under Validating Webhook", func() {
+		var ctx context.Context
+
+		BeforeEach(func() {
+			ctx = requestContext(admissionv1.Create, nil)
+		})
+
+		It("Should admit when the MasterPassword has no namespace restriction", func() {
+			withObjects(namespace("team-a", nil), masterPassword("default", nil))
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should admit when the MasterPassword does not exist yet", func() {
+			withObjects(namespace("team-a", nil))
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should admit a namespace listed by name", func() {
+			withObjects(namespace("team-a", nil), masterPassword("default", &secretsv1beta1.AllowedNamespaces{
+				Names: []string{"team-a"},
+			}))
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should admit a namespace matching the selector", func() {
+			withObjects(namespace("team-a", map[string]string{"team": "a"}),
+				masterPassword("default", &secretsv1beta1.AllowedNamespaces{
+					NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}},
+				}))
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny a namespace that is neither listed nor selected", func() {
+			withObjects(namespace("team-a", map[string]string{"team": "b"}),
+				masterPassword("default", &secretsv1beta1.AllowedNamespaces{
+					Names:             []string{"team-b"},
+					NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}},
+				}))
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsForbidden(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("namespace team-a is not allowed to use MasterPassword default"))
+		})
+
+		It("Should deny an update adding a key override to a restricted MasterPassword", func() {
+			withObjects(namespace("team-a", nil), masterPassword("default", nil),
+				masterPassword("restricted", &secretsv1beta1.AllowedNamespaces{Names: []string{"team-b"}}))
+			usable["masterpasswords//restricted"] = true
+			updated := obj.DeepCopy()
+			updated.Spec.Keys["token"] = secretsv1beta1.DerivedKeySpec{
+				Type:           secretsv1beta1.SecretTypeEncryptionKey,
+				MasterPassword: "restricted",
+			}
+			_, err := validator.ValidateUpdate(requestContext(admissionv1.Update, obj), obj, updated)
+			Expect(apierrors.IsForbidden(err)).To(BeTrue())
+		})
+
+		It("Should admit a context override within the namespace", func() {
+			withObjects(namespace("team-a", nil), masterPassword("default", nil))
+			obj.Spec.Keys["password"] = secretsv1beta1.DerivedKeySpec{
+				Type:            secretsv1beta1.SecretTypePassword,
+				ContextOverride: "team-a/old-app/password",
+			}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny a context override of another namespace unless the MasterPassword allows it", func() {
+			shared := masterPassword("default", nil)
+			withObjects(namespace("team-a", nil), shared)
+			obj.Spec.Keys["password"] = secretsv1beta1.DerivedKeySpec{
+				Type:            secretsv1beta1.SecretTypePassword,
+				ContextOverride: "team-b/app/password",
+			}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsForbidden(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("outside namespace team-a"))
+
+			shared.Spec.AllowCrossNamespaceContexts = true
+			withObjects(namespace("team-a", nil), shared)
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny a context override of a ClusterDerivedSecret in the namespace", func() {
+			withObjects(namespace("team-a", nil), masterPassword("default", nil))
+			obj.Spec.Keys["password"] = secretsv1beta1.DerivedKeySpec{
+				Type:            secretsv1beta1.SecretTypePassword,
+				ContextOverride: "team-a/cluster/shared/password",
+			}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsForbidden(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("belongs to a ClusterDerivedSecret"))
+		})
+
+		It("Should deny a context override the operator derives for itself", func() {
+			shared := masterPassword("default", nil)
+			shared.Spec.AllowCrossNamespaceContexts = true
+			withObjects(namespace("team-a", nil), shared)
+			obj.Spec.Keys["password"] = secretsv1beta1.DerivedKeySpec{
+				Type:            secretsv1beta1.SecretTypePassword,
+				ContextOverride: "derived-secret-operator:fingerprint",
+			}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsForbidden(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("are reserved"))
+		})
+
+		It("Should deny a user without the use permission", func() {
+			withObjects(namespace("team-a", nil))
+			obj.Spec.MasterPassword = "other"
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsForbidden(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("user alice cannot use MasterPassword other"))
+		})
+
+		It("Should check the use permission for key overrides", func() {
+			withObjects(namespace("team-a", nil))
+			obj.Spec.Keys["token"] = secretsv1beta1.DerivedKeySpec{
+				Type:           secretsv1beta1.SecretTypeEncryptionKey,
+				MasterPassword: "other",
+			}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+			usable["masterpasswords//other"] = true
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should check the use permission on NamespaceMasterPasswords in the same namespace", func() {
+			withObjects(namespace("team-a", nil))
+			obj.Spec.MasterPasswordKind = secretsv1beta1.KindNamespaceMasterPassword
+			obj.Spec.MasterPassword = "tenant"
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsForbidden(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("user alice cannot use NamespaceMasterPassword tenant"))
+
+			usable["namespacemasterpasswords/team-a/tenant"] = true
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should not apply MasterPassword namespace restrictions to NamespaceMasterPasswords", func() {
+			withObjects(namespace("team-a", nil),
+				masterPassword("default", &secretsv1beta1.AllowedNamespaces{Names: []string{"team-b"}}))
+			usable["namespacemasterpasswords/team-a/default"] = true
+			obj.Spec.MasterPasswordKind = secretsv1beta1.KindNamespaceMasterPassword
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny a forged authorized user annotation", func() {
+			withObjects(namespace("team-a", nil))
+			obj.Annotations[secretsv1beta1.AuthorizedUserAnnotation] = "bob"
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsForbidden(err)).To(BeTrue())
+		})
+
+		It("Should skip the use permission on metadata-only updates", func() {
+			withObjects(namespace("team-a", nil))
+			obj.Spec.MasterPassword = "other"
+			obj.Annotations[secretsv1beta1.AuthorizedUserAnnotation] = "bob"
+			updated := obj.DeepCopy()
+			updated.Labels = map[string]string{"app": "test"}
+			Expect(validator.ValidateUpdate(requestContext(admissionv1.Update, obj), obj, updated)).
+				Error().NotTo(HaveOccurred())
+
+			delete(updated.Annotations, secretsv1beta1.AuthorizedUserAnnotation)
+			_, err := validator.ValidateUpdate(requestContext(admissionv1.Update, obj), obj, updated)
+			Expect(apierrors.IsForbidden(err)).To(BeTrue())
+		})
+
+		It("Should skip the use permission on key version bumps", func() {
+			withObjects(namespace("team-a", nil))
+			obj.Spec.MasterPassword = "other"
+			obj.Annotations[secretsv1beta1.AuthorizedUserAnnotation] = "bob"
+			updated := obj.DeepCopy()
+			key := updated.Spec.Keys["password"]
+			key.Version++
+			updated.Spec.Keys["password"] = key
+			Expect(validator.ValidateUpdate(requestContext(admissionv1.Update, obj), obj, updated)).
+				Error().NotTo(HaveOccurred())
+
+			key.ContextOverride = "team-a/other/password"
+			updated.Spec.Keys["password"] = key
+			_, err := validator.ValidateUpdate(requestContext(admissionv1.Update, obj), obj, updated)
+			Expect(apierrors.IsForbidden(err)).To(BeTrue())
+		})
+	})
+
+	Context("When creating or updating DerivedSecret under Defaulting Webhook", func() {
+		It("Should record the requesting user on create", func() {
+			obj.Annotations = nil
+			Expect(defaulter.Default(requestContext(admissionv1.Create, nil), obj)).To(Succeed())
+			Expect(obj.Annotations).To(HaveKeyWithValue(secretsv1beta1.AuthorizedUserAnnotation, user))
+		})
+
+		It("Should overwrite the annotation when the spec changes", func() {
+			old := obj.DeepCopy()
+			old.Annotations[secretsv1beta1.AuthorizedUserAnnotation] = "bob"
+			obj.Spec.MasterPassword = "other"
+			obj.Annotations[secretsv1beta1.AuthorizedUserAnnotation] = "bob"
+			Expect(defaulter.Default(requestContext(admissionv1.Update, old), obj)).To(Succeed())
+			Expect(obj.Annotations).To(HaveKeyWithValue(secretsv1beta1.AuthorizedUserAnnotation, user))
+		})
+
+		It("Should keep the previous user on metadata-only updates", func() {
+			old := obj.DeepCopy()
+			old.Annotations[secretsv1beta1.AuthorizedUserAnnotation] = "bob"
+			obj.Labels = map[string]string{"app": "test"}
+			Expect(defaulter.Default(requestContext(admissionv1.Update, old), obj)).To(Succeed())
+			Expect(obj.Annotations).To(HaveKeyWithValue(secretsv1beta1.AuthorizedUserAnnotation, "bob"))
+		})
+	})
+})
@@ -0,0 +1,254 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	secretsv1beta1 "github.com/oleksiyp/derived-secret-operator/api/v1beta1"
+)
+
+// approveVerb is the RBAC verb required on rotationrequests/<name> to approve a rotation
+const approveVerb = "approve"
+
+// rotationrequestlog is for logging in this package.
+var rotationrequestlog = logf.Log.WithName("rotationrequest-resource")
+
+// rotationRequestsResource is the resource reported in admission errors
+var rotationRequestsResource = secretsv1beta1.GroupVersion.WithResource("rotationrequests").GroupResource()
+
+// SetupRotationRequestWebhookWithManager registers the webhook for RotationRequest in the manager.
+func SetupRotationRequestWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&secretsv1beta1.RotationRequest{}).
+		WithDefaulter(&RotationRequestCustomDefaulter{}).
+		WithValidator(&RotationRequestCustomValidator{Client: mgr.GetClient()}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-secrets-oleksiyp-dev-v1beta1-rotationrequest,mutating=true,failurePolicy=fail,sideEffects=None,groups=secrets.oleksiyp.dev,resources=rotationrequests;rotationrequests/status,verbs=create;update,versions=v1beta1,name=mrotationrequest-v1beta1.kb.io,admissionReviewVersions=v1
+
+// RotationRequestCustomDefaulter records the requesting user of a RotationRequest on create,
+// and the approving user when its Approved condition becomes true.
+type RotationRequestCustomDefaulter struct{}
+
+var _ webhook.CustomDefaulter = &RotationRequestCustomDefaulter{}
+
+// Default implements webhook.CustomDefaulter so a webhook will be registered for the type RotationRequest.
+func (d *RotationRequestCustomDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	rr, ok := obj.(*secretsv1beta1.RotationRequest)
+	if !ok {
+		return fmt.Errorf("expected a RotationRequest object but got %T", obj)
+	}
+
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	annotations := rr.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	if req.Operation != admissionv1.Update {
+		annotations[secretsv1beta1.RequestedByAnnotation] = req.UserInfo.Username
+		rr.SetAnnotations(annotations)
+		return nil
+	}
+
+	old, err := decodeOldRotationRequest(req)
+	if err != nil {
+		return err
+	}
+	annotations[secretsv1beta1.RequestedByAnnotation] = old.Annotations[secretsv1beta1.RequestedByAnnotation]
+	rr.SetAnnotations(annotations)
+
+	switch {
+	case !rr.Approved():
+		rr.Status.ApprovedBy = ""
+		rr.Status.ApprovedAt = nil
+	case !old.Approved():
+		now := metav1.Now()
+		rr.Status.ApprovedBy = req.UserInfo.Username
+		rr.Status.ApprovedAt = &now
+	default:
+		rr.Status.ApprovedBy = old.Status.ApprovedBy
+		rr.Status.ApprovedAt = old.Status.ApprovedAt
+	}
+	return nil
+}
+
+// +kubebuilder:webhook:path=/validate-secrets-oleksiyp-dev-v1beta1-rotationrequest,mutating=false,failurePolicy=fail,sideEffects=None,groups=secrets.oleksiyp.dev,resources=rotationrequests;rotationrequests/status,verbs=create;update,versions=v1beta1,name=vrotationrequest-v1beta1.kb.io,admissionReviewVersions=v1
+
+// RotationRequestCustomValidator validates RotationRequests when they are created or updated.
+// The requesting user must be allowed to update the rotated object, and the approving user
+// must differ from the requesting one and be allowed to "approve" the RotationRequest.
+type RotationRequestCustomValidator struct {
+	Client client.Client
+}
+
+var _ webhook.CustomValidator = &RotationRequestCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type RotationRequest.
+func (v *RotationRequestCustomValidator) ValidateCreate(
+	ctx context.Context,
+	obj runtime.Object,
+) (admission.Warnings, error) {
+	rr, ok := obj.(*secretsv1beta1.RotationRequest)
+	if !ok {
+		return nil, fmt.Errorf("expected a RotationRequest object but got %T", obj)
+	}
+	rotationrequestlog.Info("Validation for RotationRequest upon creation", "name", rr.GetName())
+
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	user := req.UserInfo
+
+	if rr.Annotations[secretsv1beta1.RequestedByAnnotation] != user.Username {
+		return nil, apierrors.NewForbidden(rotationRequestsResource, rr.Name,
+			fmt.Errorf("annotation %s must be %q", secretsv1beta1.RequestedByAnnotation, user.Username))
+	}
+
+	attrs := &authorizationv1.ResourceAttributes{
+		Verb:     "update",
+		Group:    secretsv1beta1.GroupVersion.Group,
+		Resource: "derivedsecrets",
+		Name:     rr.Spec.Name,
+	}
+	if rr.Spec.Kind == secretsv1beta1.RotationTargetMasterPassword {
+		attrs.Resource = "masterpasswords"
+	} else {
+		attrs.Namespace = rr.Namespace
+	}
+	allowed, err := canUse(ctx, v.Client, user, attrs)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, apierrors.NewForbidden(rotationRequestsResource, rr.Name,
+			fmt.Errorf("user %s cannot update %s %s", user.Username, rr.Spec.Kind, rr.Spec.Name))
+	}
+	return nil, nil
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type RotationRequest.
+func (v *RotationRequestCustomValidator) ValidateUpdate(
+	ctx context.Context,
+	oldObj, newObj runtime.Object,
+) (admission.Warnings, error) {
+	rr, ok := newObj.(*secretsv1beta1.RotationRequest)
+	if !ok {
+		return nil, fmt.Errorf("expected a RotationRequest object for the newObj but got %T", newObj)
+	}
+	old, ok := oldObj.(*secretsv1beta1.RotationRequest)
+	if !ok {
+		return nil, fmt.Errorf("expected a RotationRequest object for the oldObj but got %T", oldObj)
+	}
+	rotationrequestlog.Info("Validation for RotationRequest upon update", "name", rr.GetName())
+
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	user := req.UserInfo
+
+	requestedBy := old.Annotations[secretsv1beta1.RequestedByAnnotation]
+	if rr.Annotations[secretsv1beta1.RequestedByAnnotation] != requestedBy {
+		return nil, apierrors.NewForbidden(rotationRequestsResource, rr.Name,
+			fmt.Errorf("annotation %s cannot be changed", secretsv1beta1.RequestedByAnnotation))
+	}
+
+	oldApproval := meta.FindStatusCondition(old.Status.Conditions, "Approved")
+	newApproval := meta.FindStatusCondition(rr.Status.Conditions, "Approved")
+	if old.Status.CompletedAt != nil && !sameApproval(oldApproval, newApproval) {
+		return nil, apierrors.NewForbidden(rotationRequestsResource, rr.Name,
+			fmt.Errorf("approval cannot be changed after the rotation completed"))
+	}
+
+	if !rr.Approved() || old.Approved() {
+		// Only a new approval records a new approver
+		if rr.Status.ApprovedBy != old.Status.ApprovedBy && rr.Approved() {
+			return nil, apierrors.NewForbidden(rotationRequestsResource, rr.Name,
+				fmt.Errorf("approvedBy cannot be changed"))
+		}
+		return nil, nil
+	}
+
+	if user.Username == requestedBy {
+		return nil, apierrors.NewForbidden(rotationRequestsResource, rr.Name,
+			fmt.Errorf("user %s requested the rotation and cannot approve it", user.Username))
+	}
+	if rr.Status.ApprovedBy != user.Username {
+		return nil, apierrors.NewForbidden(rotationRequestsResource, rr.Name,
+			fmt.Errorf("approvedBy must be %q", user.Username))
+	}
+	allowed, err := canUse(ctx, v.Client, user, &authorizationv1.ResourceAttributes{
+		Verb:      approveVerb,
+		Group:     secretsv1beta1.GroupVersion.Group,
+		Resource:  "rotationrequests",
+		Namespace: rr.Namespace,
+		Name:      rr.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, apierrors.NewForbidden(rotationRequestsResource, rr.Name,
+			fmt.Errorf("user %s cannot %s RotationRequest %s", user.Username, approveVerb, rr.Name))
+	}
+	return nil, nil
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type RotationRequest.
+func (v *RotationRequestCustomValidator) ValidateDelete(
+	ctx context.Context,
+	obj runtime.Object,
+) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// sameApproval reports whether two Approved conditions carry the same decision
+func sameApproval(a, b *metav1.Condition) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Status == b.Status
+}
+
+// decodeOldRotationRequest decodes the RotationRequest being replaced by an update request
+func decodeOldRotationRequest(req admission.Request) (*secretsv1beta1.RotationRequest, error) {
+	old := &secretsv1beta1.RotationRequest{}
+	if err := json.Unmarshal(req.OldObject.Raw, old); err != nil {
+		return nil, fmt.Errorf("failed to decode old RotationRequest: %w", err)
+	}
+	return old, nil
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	secretsv1beta1 "github.com/oleksiyp/derived-secret-operator/api/v1beta1"
+)
+
+var _ = Describe("RotationRequest Webhook", func() {
+	const requester = "alice"
+	const approver = "bob"
+
+	var (
+		validator RotationRequestCustomValidator
+		defaulter RotationRequestCustomDefaulter
+		obj       *secretsv1beta1.RotationRequest
+		// allowed holds the "<user> <verb> <resource>/<namespace>/<name>" permissions
+		allowed map[string]bool
+	)
+
+	requestContext := func(op admissionv1.Operation, user string, old runtime.Object) context.Context {
+		req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: op,
+			UserInfo:  authenticationv1.UserInfo{Username: user},
+		}}
+		if old != nil {
+			raw, err := json.Marshal(old)
+			Expect(err).NotTo(HaveOccurred())
+			req.OldObject = runtime.RawExtension{Raw: raw}
+		}
+		return admission.NewContextWithRequest(context.Background(), req)
+	}
+
+	// approve sets the Approved condition as user and runs it through admission
+	approve := func(user string) (*secretsv1beta1.RotationRequest, error) {
+		updated := obj.DeepCopy()
+		meta.SetStatusCondition(&updated.Status.Conditions, metav1.Condition{
+			Type:   "Approved",
+			Status: metav1.ConditionTrue,
+			Reason: "Approved",
+		})
+		ctx := requestContext(admissionv1.Update, user, obj)
+		Expect(defaulter.Default(ctx, updated)).To(Succeed())
+		_, err := validator.ValidateUpdate(ctx, obj, updated)
+		return updated, err
+	}
+
+	BeforeEach(func() {
+		allowed = map[string]bool{
+			requester + " update derivedsecrets/team-a/app":      true,
+			approver + " approve rotationrequests/team-a/rotate": true,
+		}
+		validator.Client = fake.NewClientBuilder().WithScheme(scheme).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Create: func(ctx context.Context, c client.WithWatch, o client.Object, opts ...client.CreateOption) error {
+					sar, ok := o.(*authorizationv1.SubjectAccessReview)
+					if !ok {
+						return c.Create(ctx, o, opts...)
+					}
+					attrs := sar.Spec.ResourceAttributes
+					sar.Status.Allowed = allowed[sar.Spec.User+" "+attrs.Verb+" "+
+						attrs.Resource+"/"+attrs.Namespace+"/"+attrs.Name]
+					return nil
+				},
+			}).Build()
+
+		obj = &secretsv1beta1.RotationRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "rotate",
+				Namespace:   "team-a",
+				Annotations: map[string]string{secretsv1beta1.RequestedByAnnotation: requester},
+			},
+			Spec: secretsv1beta1.RotationRequestSpec{
+				Kind: secretsv1beta1.RotationTargetDerivedSecret,
+				Name: "app",
+			},
+		}
+	})
+
+	Context("When creating a RotationRequest", func() {
+		It("Should record the requesting user", func() {
+			obj.Annotations[secretsv1beta1.RequestedByAnnotation] = approver
+			Expect(defaulter.Default(requestContext(admissionv1.Create, requester, nil), obj)).To(Succeed())
+			Expect(obj.Annotations).To(HaveKeyWithValue(secretsv1beta1.RequestedByAnnotation, requester))
+		})
+
+		It("Should require update permission on the target", func() {
+			ctx := requestContext(admissionv1.Create, requester, nil)
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+
+			obj.Spec.Kind = secretsv1beta1.RotationTargetMasterPassword
+			obj.Spec.Name = "default"
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsForbidden(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("cannot update MasterPassword default"))
+
+			allowed[requester+" update masterpasswords//default"] = true
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny a forged requesting user", func() {
+			obj.Annotations[secretsv1beta1.RequestedByAnnotation] = approver
+			_, err := validator.ValidateCreate(requestContext(admissionv1.Create, requester, nil), obj)
+			Expect(apierrors.IsForbidden(err)).To(BeTrue())
+		})
+	})
+
+	Context("When approving a RotationRequest", func() {
+		It("Should record the approving user", func() {
+			updated, err := approve(approver)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated.Status.ApprovedBy).To(Equal(approver))
+			Expect(updated.Status.ApprovedAt).NotTo(BeNil())
+		})
+
+		It("Should deny approval by the requesting user", func() {
+			allowed[requester+" approve rotationrequests/team-a/rotate"] = true
+			_, err := approve(requester)
+			Expect(apierrors.IsForbidden(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("cannot approve it"))
+		})
+
+		It("Should deny approval without the approve permission", func() {
+			_, err := approve("carol")
+			Expect(apierrors.IsForbidden(err)).To(BeTrue())
+		})
+
+		It("Should keep the approving user on later status updates", func() {
+			approved, err := approve(approver)
+			Expect(err).NotTo(HaveOccurred())
+			obj = approved
+
+			updated := obj.DeepCopy()
+			updated.Status.ApprovedBy = "carol"
+			ctx := requestContext(admissionv1.Update, "operator", obj)
+			Expect(defaulter.Default(ctx, updated)).To(Succeed())
+			Expect(updated.Status.ApprovedBy).To(Equal(approver))
+			Expect(validator.ValidateUpdate(ctx, obj, updated)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny changing the approval after the rotation completed", func() {
+			approved, err := approve(approver)
+			Expect(err).NotTo(HaveOccurred())
+			now := metav1.Now()
+			approved.Status.CompletedAt = &now
+			obj = approved
+
+			updated := obj.DeepCopy()
+			meta.RemoveStatusCondition(&updated.Status.Conditions, "Approved")
+			ctx := requestContext(admissionv1.Update, approver, obj)
+			Expect(defaulter.Default(ctx, updated)).To(Succeed())
+			_, err = validator.ValidateUpdate(ctx, obj, updated)
+			Expect(apierrors.IsForbidden(err)).To(BeTrue())
+		})
+	})
+})
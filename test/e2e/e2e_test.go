@@ -37,6 +37,9 @@ import (
 // namespace where the project is deployed in
 const namespace = "derived-secret-operator-system"
 
+// readyJSONPath selects the status of the Ready condition
+const readyJSONPath = `jsonpath={.status.conditions[?(@.type=="Ready")].status}`
+
 // serviceAccountName created for the project
 const serviceAccountName = "derived-secret-operator-controller-manager"
 
@@ -289,10 +292,10 @@ spec:
 			By("waiting for first master password to be ready")
 			verifyFirstMPReady := func(g Gomega) {
 				cmd := exec.Command("kubectl", "get", "masterpassword", firstMasterPasswordName,
-					"-o", "jsonpath={.status.ready}")
+					"-o", readyJSONPath)
 				output, err := utils.Run(cmd)
 				g.Expect(err).NotTo(HaveOccurred())
-				g.Expect(output).To(Equal("true"))
+				g.Expect(output).To(Equal("True"))
 			}
 			Eventually(verifyFirstMPReady, 30*time.Second).Should(Succeed())
 
@@ -319,10 +322,10 @@ spec:
 			verifyDerivedSecretReady := func(g Gomega) {
 				cmd := exec.Command("kubectl", "get", "derivedsecret", derivedSecretName,
 					"-n", testNamespace,
-					"-o", "jsonpath={.status.ready}")
+					"-o", readyJSONPath)
 				output, err := utils.Run(cmd)
 				g.Expect(err).NotTo(HaveOccurred())
-				g.Expect(output).To(Equal("true"))
+				g.Expect(output).To(Equal("True"))
 			}
 			Eventually(verifyDerivedSecretReady, 30*time.Second).Should(Succeed())
 
@@ -351,10 +354,10 @@ spec:
 			By("waiting for second master password to be ready")
 			verifySecondMPReady := func(g Gomega) {
 				cmd := exec.Command("kubectl", "get", "masterpassword", secondMasterPasswordName,
-					"-o", "jsonpath={.status.ready}")
+					"-o", readyJSONPath)
 				output, err := utils.Run(cmd)
 				g.Expect(err).NotTo(HaveOccurred())
-				g.Expect(output).To(Equal("true"))
+				g.Expect(output).To(Equal("True"))
 			}
 			Eventually(verifySecondMPReady, 30*time.Second).Should(Succeed())
 
@@ -444,10 +447,10 @@ spec:
 			verifyDerivedSecretReady := func(g Gomega) {
 				cmd := exec.Command("kubectl", "get", "derivedsecret", derivedSecretName,
 					"-n", testNamespace,
-					"-o", "jsonpath={.status.ready}")
+					"-o", readyJSONPath)
 				output, err := utils.Run(cmd)
 				g.Expect(err).NotTo(HaveOccurred())
-				g.Expect(output).To(Equal("true"))
+				g.Expect(output).To(Equal("True"))
 			}
 			Eventually(verifyDerivedSecretReady, 30*time.Second).Should(Succeed())
 
@@ -461,7 +464,7 @@ spec:
 
 			cmd = exec.Command("kubectl", "get", "derivedsecret", derivedSecretName,
 				"-n", testNamespace,
-				"-o", "jsonpath={.status.keyHashes.password}")
+				"-o", "jsonpath={.status.keyFingerprints.password}")
 			initialHash, err := utils.Run(cmd)
 			Expect(err).NotTo(HaveOccurred(), "Failed to get initial hash")
 			Expect(initialHash).NotTo(BeEmpty(), "Initial hash should not be empty")
@@ -487,7 +490,7 @@ spec:
 			By("verifying hash remains the same")
 			cmd = exec.Command("kubectl", "get", "derivedsecret", derivedSecretName,
 				"-n", testNamespace,
-				"-o", "jsonpath={.status.keyHashes.password}")
+				"-o", "jsonpath={.status.keyFingerprints.password}")
 			recreatedHash, err := utils.Run(cmd)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(recreatedHash).To(Equal(initialHash), "Hash should remain the same after recreation")
@@ -544,10 +547,10 @@ spec:
 			verifyDerivedSecretReady := func(g Gomega) {
 				cmd := exec.Command("kubectl", "get", "derivedsecret", derivedSecretName,
 					"-n", testNamespace,
-					"-o", "jsonpath={.status.ready}")
+					"-o", readyJSONPath)
 				output, err := utils.Run(cmd)
 				g.Expect(err).NotTo(HaveOccurred())
-				g.Expect(output).To(Equal("true"))
+				g.Expect(output).To(Equal("True"))
 			}
 			Eventually(verifyDerivedSecretReady, 30*time.Second).Should(Succeed())
 
@@ -561,7 +564,7 @@ spec:
 
 			cmd = exec.Command("kubectl", "get", "derivedsecret", derivedSecretName,
 				"-n", testNamespace,
-				"-o", "jsonpath={.status.keyHashes.password}")
+				"-o", "jsonpath={.status.keyFingerprints.password}")
 			initialHash, err := utils.Run(cmd)
 			Expect(err).NotTo(HaveOccurred(), "Failed to get initial hash")
 			Expect(initialHash).NotTo(BeEmpty(), "Initial hash should not be empty")
@@ -600,7 +603,7 @@ stringData:
 			verifyHashChanged := func(g Gomega) {
 				cmd := exec.Command("kubectl", "get", "derivedsecret", derivedSecretName,
 					"-n", testNamespace,
-					"-o", "jsonpath={.status.keyHashes.password}")
+					"-o", "jsonpath={.status.keyFingerprints.password}")
 				newHash, err := utils.Run(cmd)
 				g.Expect(err).NotTo(HaveOccurred())
 				g.Expect(newHash).NotTo(BeEmpty())
@@ -661,10 +664,10 @@ spec:
 			verifyDerivedSecretReady := func(g Gomega) {
 				cmd := exec.Command("kubectl", "get", "derivedsecret", derivedSecretName,
 					"-n", testNamespace,
-					"-o", "jsonpath={.status.ready}")
+					"-o", readyJSONPath)
 				output, err := utils.Run(cmd)
 				g.Expect(err).NotTo(HaveOccurred())
-				g.Expect(output).To(Equal("true"))
+				g.Expect(output).To(Equal("True"))
 			}
 			Eventually(verifyDerivedSecretReady, 30*time.Second).Should(Succeed())
 
@@ -686,7 +689,7 @@ spec:
 			verifyDerivedSecretNotReady := func(g Gomega) {
 				cmd := exec.Command("kubectl", "get", "derivedsecret", derivedSecretName,
 					"-n", testNamespace,
-					"-o", "jsonpath={.status.ready}")
+					"-o", readyJSONPath)
 				output, err := utils.Run(cmd)
 				g.Expect(err).NotTo(HaveOccurred())
 				g.Expect(output).To(Equal("false"), "DerivedSecret should not be ready when MasterPassword secret is missing")
@@ -798,10 +801,10 @@ func getMetricsOutput() (string, error) {
 func verifyMasterPasswordReady(name string) func(Gomega) {
 	return func(g Gomega) {
 		cmd := exec.Command("kubectl", "get", "masterpassword", name,
-			"-o", "jsonpath={.status.ready}")
+			"-o", readyJSONPath)
 		output, err := utils.Run(cmd)
 		g.Expect(err).NotTo(HaveOccurred())
-		g.Expect(output).To(Equal("true"))
+		g.Expect(output).To(Equal("True"))
 	}
 }
 